@@ -1,7 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
 	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/jobs"
 	"github.com/sounishnath003/customgo-mailer-service/internal/server"
 	"github.com/sounishnath003/customgo-mailer-service/internal/utils"
 	"github.com/sounishnath003/customgo-mailer-service/internal/workerpool"
@@ -9,6 +18,12 @@ import (
 
 func main() {
 
+	// LLM_MODEL names the model of any backend, GCP_VERTEX_AI_LLM is kept for the existing deployments.
+	modelName := utils.GetStringFromEnv("LLM_MODEL", "")
+	if modelName == "" {
+		modelName = utils.GetStringFromEnv("GCP_VERTEX_AI_LLM", "gemini-2.5-flash")
+	}
+
 	co := core.NewCore(&core.CoreOpts{
 		Port:          utils.GetNumberFromEnv("PORT", 3000),
 		SmtpAddr:      "smtp.gmail.com",
@@ -16,26 +31,100 @@ func main() {
 		MailSecret:    utils.GetStringFromEnv("MAIL_SECRET", "P@55w0Rd5!"),
 		MongoDbUri:    utils.GetStringFromEnv("MONGO_DB_URI", "localhost"),
 		PdfServiceUri: utils.GetStringFromEnv("PDF_SERVICE_URI", "http://0.0.0.0:3001"),
+		AppBaseUrl:    utils.GetStringFromEnv("APP_BASE_URL", "http://localhost:3000"),
 
 		GcpProjectID:     utils.GetStringFromEnv("GCP_PROJECT_ID", "sounish-cloud-workstation"),
 		GcpLocation:      utils.GetStringFromEnv("GCP_PROJECT_LOCATION", "asia-south1"),
-		ModelName:        utils.GetStringFromEnv("GCP_VERTEX_AI_LLM", "gemini-2.5-flash"),
+		ModelName:        modelName,
 		GcpStorageBucket: utils.GetStringFromEnv("GCP_STORAGE_BUCKET", "sounish-cloud-workstation"),
+
+		LLMBackend: utils.GetStringFromEnv("LLM_BACKEND", "vertex"),
+		LLMBaseUrl: utils.GetStringFromEnv("LLM_BASE_URL", ""),
+		LLMApiKey:  utils.GetStringFromEnv("LLM_API_KEY", ""),
+
+		LLMStructuredOutput: utils.GetOptionalBoolFromEnv("LLM_STRUCTURED_OUTPUT"),
+		LLMPdfInput:         utils.GetOptionalBoolFromEnv("LLM_PDF_INPUT"),
+
+		LLMCacheTTL:           time.Duration(utils.GetNumberFromEnv("LLM_CACHE_TTL_HOURS", 24*7)) * time.Hour,
+		LLMCacheMaxEntryBytes: utils.GetNumberFromEnv("LLM_CACHE_MAX_ENTRY_KB", 256) * 1024,
+		LLMCacheMaxBytes:      int64(utils.GetNumberFromEnv("LLM_CACHE_MAX_MB", 256)) * 1024 * 1024,
+
+		LLMDailyTokenQuota:      int64(utils.GetNumberFromEnv("LLM_DAILY_TOKEN_QUOTA", 200_000)),
+		LLMMonthlyTokenQuota:    int64(utils.GetNumberFromEnv("LLM_MONTHLY_TOKEN_QUOTA", 2_000_000)),
+		LLMInputCostPerMillion:  utils.GetFloatFromEnv("LLM_INPUT_COST_PER_MILLION", 0.30),
+		LLMOutputCostPerMillion: utils.GetFloatFromEnv("LLM_OUTPUT_COST_PER_MILLION", 2.50),
+
+		JobPostingCacheTTL: time.Duration(utils.GetNumberFromEnv("JOB_POSTING_CACHE_TTL_HOURS", 24)) * time.Hour,
+		JobPostingMaxBytes: int64(utils.GetNumberFromEnv("JOB_POSTING_MAX_KB", 2048)) * 1024,
+		JobPostingTimeout:  time.Duration(utils.GetNumberFromEnv("JOB_POSTING_TIMEOUT_SECONDS", 10)) * time.Second,
+
+		AdminEmails: strings.Split(utils.GetStringFromEnv("ADMIN_EMAILS", ""), ","),
 	})
 
 	// Initialize worker pool
-	// Buffer Queue = 10 x Concurrency
-	wp := workerpool.NewWorkerPool(co, 5)
+	wp := workerpool.NewWorkerPool(co, &workerpool.WorkerPoolOpts{
+		Concurrency:    utils.GetNumberFromEnv("WORKERPOOL_CONCURRENCY", 5),
+		MaxJobsPerUser: utils.GetNumberFromEnv("WORKERPOOL_MAX_JOBS_PER_USER", 2),
+		MaxAttempts:    utils.GetNumberFromEnv("WORKERPOOL_MAX_ATTEMPTS", 5),
+		RetryBaseDelay: time.Duration(utils.GetNumberFromEnv("WORKERPOOL_RETRY_BASE_DELAY_SECONDS", 30)) * time.Second,
+		RetryMaxDelay:  time.Duration(utils.GetNumberFromEnv("WORKERPOOL_RETRY_MAX_DELAY_SECONDS", 3600)) * time.Second,
+		LeaseDuration:  time.Duration(utils.GetNumberFromEnv("WORKERPOOL_LEASE_SECONDS", 120)) * time.Second,
+		DispatchMode:   workerpool.DispatchMode(strings.ToUpper(utils.GetStringFromEnv("WORKERPOOL_DISPATCH_MODE", "AUTO"))),
+		PollInterval:   time.Duration(utils.GetNumberFromEnv("WORKERPOOL_POLL_INTERVAL_SECONDS", 1)) * time.Second,
+	})
+	// Register the job kinds the workers know how to process
+	jobs.Register(wp.Registry(), co)
+
+	// Start worker pool
+	wp.StartWorkers()
+	// Attach the mongo db client
+	go wp.ListenForThePendingJobs()
+	// Hand the jobs of crashed replicas back to the queue
+	go wp.ReapExpiredLeases()
 
+	// Run the periodic tasks, a single replica runs each due task
+	scheduler := workerpool.NewScheduler(co)
+	jobs.RegisterTasks(scheduler, co)
+	if err := scheduler.Start(); err != nil {
+		panic(err)
+	}
+
+	server := server.NewServer(co)
 	go func() {
-		// Start worker pool
-		go wp.StartWorkers()
-		// Attach the mongo db client
-		wp.ListenForThePendingJobs()
-		// Wait for the execution
-		wp.Wait()
+		if err := server.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
 	}()
 
-	server := server.NewServer(co)
-	panic(server.Start())
+	// Wait for the termination signal
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	co.Lo.Info("shutting down, waiting for in-flight requests and jobs")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(utils.GetNumberFromEnv("SHUTDOWN_TIMEOUT_SECONDS", 30))*time.Second)
+	defer cancel()
+
+	// Stop claiming jobs, finish or release the ones held.
+	// Runs alongside the server shutdown, which may wait on the open event streams.
+	wpDone := make(chan struct{})
+	go func() {
+		defer close(wpDone)
+		if err := wp.Shutdown(shutdownCtx); err != nil {
+			co.Lo.Error("worker pool shutdown failed", "error", err)
+		}
+	}()
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		if err := scheduler.Shutdown(shutdownCtx); err != nil {
+			co.Lo.Error("scheduler shutdown failed", "error", err)
+		}
+	}()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		co.Lo.Error("server shutdown failed", "error", err)
+	}
+	<-wpDone
+	<-schedulerDone
+	co.Lo.Info("shutdown complete")
 }
@@ -0,0 +1,237 @@
+package ats
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// SKILL_WEIGHT weighs the technical skills, the tools and the languages.
+	SKILL_WEIGHT = 3.0
+	// PRACTICE_WEIGHT weighs the practices and the soft skills.
+	PRACTICE_WEIGHT = 2.0
+	// KEYWORD_WEIGHT weighs the other words the job description repeats.
+	KEYWORD_WEIGHT = 1.0
+)
+
+// skill is a known skill, every one of its aliases counts as the skill.
+type skill struct {
+	Name    string
+	Weight  float64
+	Aliases []string
+}
+
+var skills = []skill{
+	// Languages.
+	{"Go", SKILL_WEIGHT, []string{"golang"}},
+	{"Python", SKILL_WEIGHT, []string{"python", "python3"}},
+	{"Java", SKILL_WEIGHT, []string{"java"}},
+	{"JavaScript", SKILL_WEIGHT, []string{"javascript", "js", "ecmascript"}},
+	{"TypeScript", SKILL_WEIGHT, []string{"typescript", "ts"}},
+	{"C++", SKILL_WEIGHT, []string{"c++", "cpp"}},
+	{"C#", SKILL_WEIGHT, []string{"c#", "csharp"}},
+	{"Rust", SKILL_WEIGHT, []string{"rust"}},
+	{"Ruby", SKILL_WEIGHT, []string{"ruby"}},
+	{"Scala", SKILL_WEIGHT, []string{"scala"}},
+	{"Kotlin", SKILL_WEIGHT, []string{"kotlin"}},
+	{"Swift", SKILL_WEIGHT, []string{"swiftui"}},
+	{"PHP", SKILL_WEIGHT, []string{"php"}},
+	{"SQL", SKILL_WEIGHT, []string{"sql"}},
+	// Infrastructure.
+	{"Kubernetes", SKILL_WEIGHT, []string{"kubernetes", "k8s"}},
+	{"Docker", SKILL_WEIGHT, []string{"docker", "dockerfile"}},
+	{"Terraform", SKILL_WEIGHT, []string{"terraform"}},
+	{"Ansible", SKILL_WEIGHT, []string{"ansible"}},
+	{"Helm", SKILL_WEIGHT, []string{"helm"}},
+	{"AWS", SKILL_WEIGHT, []string{"aws", "amazon web services"}},
+	{"GCP", SKILL_WEIGHT, []string{"gcp", "google cloud", "google cloud platform"}},
+	{"Azure", SKILL_WEIGHT, []string{"azure", "microsoft azure"}},
+	{"Linux", SKILL_WEIGHT, []string{"linux", "unix"}},
+	{"CI/CD", SKILL_WEIGHT, []string{"ci cd", "cicd", "continuous integration", "continuous delivery", "continuous deployment"}},
+	{"Git", SKILL_WEIGHT, []string{"git", "github", "gitlab"}},
+	{"Jenkins", SKILL_WEIGHT, []string{"jenkins"}},
+	{"Prometheus", SKILL_WEIGHT, []string{"prometheus"}},
+	{"Grafana", SKILL_WEIGHT, []string{"grafana"}},
+	// Data.
+	{"PostgreSQL", SKILL_WEIGHT, []string{"postgresql", "postgres", "psql"}},
+	{"MySQL", SKILL_WEIGHT, []string{"mysql"}},
+	{"MongoDB", SKILL_WEIGHT, []string{"mongodb", "mongo"}},
+	{"Redis", SKILL_WEIGHT, []string{"redis"}},
+	{"Kafka", SKILL_WEIGHT, []string{"kafka", "apache kafka"}},
+	{"Spark", SKILL_WEIGHT, []string{"spark", "apache spark", "pyspark"}},
+	{"Airflow", SKILL_WEIGHT, []string{"airflow", "apache airflow"}},
+	{"BigQuery", SKILL_WEIGHT, []string{"bigquery", "big query"}},
+	{"Snowflake", SKILL_WEIGHT, []string{"snowflake"}},
+	{"Elasticsearch", SKILL_WEIGHT, []string{"elasticsearch", "elastic search", "opensearch"}},
+	{"Hadoop", SKILL_WEIGHT, []string{"hadoop", "hdfs"}},
+	{"Pandas", SKILL_WEIGHT, []string{"pandas"}},
+	{"NumPy", SKILL_WEIGHT, []string{"numpy"}},
+	// Web.
+	{"React", SKILL_WEIGHT, []string{"react", "react.js", "reactjs"}},
+	{"Angular", SKILL_WEIGHT, []string{"angular", "angularjs"}},
+	{"Vue", SKILL_WEIGHT, []string{"vue", "vue.js", "vuejs"}},
+	{"Node.js", SKILL_WEIGHT, []string{"node.js", "nodejs"}},
+	{"REST APIs", SKILL_WEIGHT, []string{"restful", "rest api", "rest apis", "restful api", "restful apis"}},
+	{"GraphQL", SKILL_WEIGHT, []string{"graphql"}},
+	{"gRPC", SKILL_WEIGHT, []string{"grpc", "protobuf"}},
+	{"Microservices", SKILL_WEIGHT, []string{"microservices", "microservice", "micro services", "micro service"}},
+	{"Django", SKILL_WEIGHT, []string{"django"}},
+	{"Flask", SKILL_WEIGHT, []string{"flask"}},
+	{"FastAPI", SKILL_WEIGHT, []string{"fastapi"}},
+	{"Spring Boot", SKILL_WEIGHT, []string{"spring boot", "springboot", "spring framework"}},
+	{".NET", SKILL_WEIGHT, []string{".net", "dotnet"}},
+	// Machine learning.
+	{"Machine Learning", SKILL_WEIGHT, []string{"machine learning", "ml"}},
+	{"Deep Learning", SKILL_WEIGHT, []string{"deep learning"}},
+	{"TensorFlow", SKILL_WEIGHT, []string{"tensorflow"}},
+	{"PyTorch", SKILL_WEIGHT, []string{"pytorch", "torch"}},
+	{"NLP", SKILL_WEIGHT, []string{"nlp", "natural language processing"}},
+	{"LLMs", SKILL_WEIGHT, []string{"llm", "llms", "large language model", "large language models"}},
+	{"Generative AI", SKILL_WEIGHT, []string{"generative ai", "genai", "gen ai"}},
+	{"Computer Vision", SKILL_WEIGHT, []string{"computer vision"}},
+	// Practices and soft skills.
+	{"Agile", PRACTICE_WEIGHT, []string{"agile"}},
+	{"Scrum", PRACTICE_WEIGHT, []string{"scrum"}},
+	{"System Design", PRACTICE_WEIGHT, []string{"system design", "systems design"}},
+	{"Distributed Systems", PRACTICE_WEIGHT, []string{"distributed systems", "distributed system", "distributed computing"}},
+	{"Cloud", PRACTICE_WEIGHT, []string{"cloud", "public cloud", "cloud computing", "cloud native"}},
+	{"Unit Testing", PRACTICE_WEIGHT, []string{"unit testing", "unit tests", "unit test"}},
+	{"TDD", PRACTICE_WEIGHT, []string{"tdd", "test driven development"}},
+	{"Object-Oriented Programming", PRACTICE_WEIGHT, []string{"oop", "object oriented", "object oriented programming"}},
+	{"Data Structures", PRACTICE_WEIGHT, []string{"data structures", "data structure"}},
+	{"Algorithms", PRACTICE_WEIGHT, []string{"algorithms", "algorithm"}},
+	{"Security", PRACTICE_WEIGHT, []string{"security", "application security"}},
+	{"Leadership", PRACTICE_WEIGHT, []string{"leadership", "technical leadership"}},
+	{"Mentoring", PRACTICE_WEIGHT, []string{"mentoring", "mentorship", "mentored", "mentor"}},
+	{"Communication", PRACTICE_WEIGHT, []string{"communication", "communication skills"}},
+	{"Stakeholder Management", PRACTICE_WEIGHT, []string{"stakeholder management", "stakeholders"}},
+}
+
+// maxAliasWords is the most words of an alias.
+const maxAliasWords = 3
+
+// skillIndex maps the words of every alias, joined by a blank, to its skill.
+var skillIndex = func() map[string]*skill {
+	index := map[string]*skill{}
+	for i := range skills {
+		for _, alias := range skills[i].Aliases {
+			index[strings.Join(tokenize(alias), " ")] = &skills[i]
+		}
+	}
+	return index
+}()
+
+// contextualAliases are the aliases which are common words as well: they only count as their skill next to
+// another skill or a word about programming, "Go and Python" but not "go-to-market".
+var contextualAliases = map[string]string{
+	"go":     "Go",
+	"swift":  "Swift",
+	"spring": "Spring Boot",
+}
+
+// programmingWords make the contextual aliases next to them count as skills, as in "Go developer".
+var programmingWords = map[string]bool{
+	"developer": true, "developers": true, "development": true, "programming": true, "programmer": true,
+	"language": true, "languages": true, "backend": true, "frontend": true, "framework": true, "frameworks": true,
+	"stack": true, "code": true, "coding": true, "ios": true, "app": true, "apps": true,
+}
+
+// contextWindow is how many words around a contextual alias are looked at.
+const contextWindow = 2
+
+// inSkillContext is true when a word of the window around words[i] is another skill, or a word about programming.
+func inSkillContext(words []string, i int) bool {
+	for j := max(0, i-contextWindow); j <= min(len(words)-1, i+contextWindow); j++ {
+		if j == i {
+			continue
+		}
+		if programmingWords[words[j]] || skillIndex[words[j]] != nil {
+			return true
+		}
+		if name, ok := contextualAliases[words[j]]; ok && name != contextualAliases[words[i]] {
+			return true
+		}
+	}
+	return false
+}
+
+var skillsByName = func() map[string]*skill {
+	byName := make(map[string]*skill, len(skills))
+	for i := range skills {
+		byName[skills[i].Name] = &skills[i]
+	}
+	return byName
+}()
+
+// wordPattern matches the words, keeping the symbols of c++, c# and the dots of node.js or .net.
+var wordPattern = regexp.MustCompile(`\.net\b|[a-z0-9][a-z0-9+#]*(?:\.[a-z0-9]+)*`)
+
+func tokenize(text string) []string {
+	return wordPattern.FindAllString(strings.ToLower(text), -1)
+}
+
+// stopwords are the words which say nothing about the fit, the filler of the job descriptions included.
+var stopwords = func() map[string]bool {
+	words := map[string]bool{}
+	for _, w := range strings.Fields(`a about above across after again against all also am an and any are as at be because been before being
+		below between both but by can could did do does doing down during each either etc e.g i.e few for from further had has have having
+		he her here hers him his how if in into is it its itself just may me might more most must my no nor not now of off on once only or
+		other our ours out over own per same shall she should so some such than that the their theirs them then there these they this those
+		through to too under until up upon us very via was we were what when where which while who whom why will with within without would
+		you your yours able ability across plus new well based like good great strong excellent solid proven
+		experience experienced years year work working team teams role roles job position candidate candidates company companies
+		responsibilities responsibility requirements required requirement preferred qualifications qualification skills skill knowledge
+		including include includes using use used understanding demonstrated hands practical relevant related applied apply one two three
+		environment environments opportunity opportunities day days looking join help make ensure across within`) {
+		words[w] = true
+	}
+	return words
+}()
+
+// keywordOf is the word counted as a keyword, with a light stemming of the plurals, empty for the words which aren't keywords.
+func keywordOf(word string) string {
+	if stopwords[word] || len(word) < 3 || strings.Trim(word, "0123456789+#.") == "" {
+		return ""
+	}
+	if len(word) > 4 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is") {
+		word = strings.TrimSuffix(word, "s")
+	}
+	return word
+}
+
+// termCounts counts the skills, by name, and the other keywords of the text.
+type termCounts struct {
+	Skills   map[string]int
+	Keywords map[string]int
+}
+
+// countTerms reads the text once, the longest alias matching at a word wins.
+func countTerms(text string) termCounts {
+	counts := termCounts{Skills: map[string]int{}, Keywords: map[string]int{}}
+	words := tokenize(text)
+	for i := 0; i < len(words); {
+		matched := 0
+		for n := min(maxAliasWords, len(words)-i); n > 0; n-- {
+			if s, ok := skillIndex[strings.Join(words[i:i+n], " ")]; ok {
+				counts.Skills[s.Name]++
+				matched = n
+				break
+			}
+		}
+		if matched == 0 {
+			if name, ok := contextualAliases[words[i]]; ok && inSkillContext(words, i) {
+				counts.Skills[name]++
+				matched = 1
+			}
+		}
+		if matched > 0 {
+			i += matched
+			continue
+		}
+		if keyword := keywordOf(words[i]); keyword != "" {
+			counts.Keywords[keyword]++
+		}
+		i++
+	}
+	return counts
+}
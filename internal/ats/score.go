@@ -0,0 +1,99 @@
+// Package ats scores how well a resume matches a job description, the way the applicant tracking systems screen them:
+// by the weighted keywords and skills of the job description found in the resume, the synonyms of a skill counted as one.
+package ats
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// maxKeywords is how many of the other words repeated by the job description are scored, the most repeated first.
+	maxKeywords = 15
+	// minKeywordCount is how often a word must appear in the job description to be a keyword.
+	minKeywordCount = 2
+	// overuseMinCount and overuseFactor flag a term the resume repeats at least that many times, and that many times more
+	// than the job description does.
+	overuseMinCount = 5
+	overuseFactor   = 3
+)
+
+// Term is a keyword or a skill of the job description.
+type Term struct {
+	Term   string  `json:"term"`
+	Weight float64 `json:"weight"`
+	// Skill is true for the known skills, whose synonyms are counted as one.
+	Skill       bool `json:"skill"`
+	JobCount    int  `json:"jobCount"`
+	ResumeCount int  `json:"resumeCount"`
+}
+
+// Report is the match of a resume against a job description.
+type Report struct {
+	// Score is the share of the weighted terms of the job description found in the resume, from 0 to 100.
+	Score    int    `json:"score"`
+	Matched  []Term `json:"matched"`
+	Missing  []Term `json:"missing"`
+	Overused []Term `json:"overused"`
+}
+
+// Analyze scores the resume against the job description. Every skill the job description names is weighted by its kind,
+// a bit more when it's repeated, and the words it repeats most are weighted as keywords.
+func Analyze(jobDescription, resume string) *Report {
+	job := countTerms(jobDescription)
+	cv := countTerms(resume)
+
+	var terms []Term
+	for name, count := range job.Skills {
+		s := skillsByName[name]
+		terms = append(terms, Term{Term: name, Weight: emphasized(s.Weight, count), Skill: true, JobCount: count, ResumeCount: cv.Skills[name]})
+	}
+
+	var keywords []Term
+	for word, count := range job.Keywords {
+		if count >= minKeywordCount {
+			keywords = append(keywords, Term{Term: word, Weight: KEYWORD_WEIGHT, JobCount: count, ResumeCount: cv.Keywords[word]})
+		}
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if keywords[i].JobCount != keywords[j].JobCount {
+			return keywords[i].JobCount > keywords[j].JobCount
+		}
+		return keywords[i].Term < keywords[j].Term
+	})
+	if len(keywords) > maxKeywords {
+		keywords = keywords[:maxKeywords]
+	}
+	terms = append(terms, keywords...)
+
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Weight != terms[j].Weight {
+			return terms[i].Weight > terms[j].Weight
+		}
+		return terms[i].Term < terms[j].Term
+	})
+
+	report := &Report{Matched: []Term{}, Missing: []Term{}, Overused: []Term{}}
+	var total, matched float64
+	for _, t := range terms {
+		total += t.Weight
+		if t.ResumeCount == 0 {
+			report.Missing = append(report.Missing, t)
+			continue
+		}
+		matched += t.Weight
+		report.Matched = append(report.Matched, t)
+		if t.ResumeCount >= overuseMinCount && t.ResumeCount > overuseFactor*t.JobCount {
+			report.Overused = append(report.Overused, t)
+		}
+	}
+	if total > 0 {
+		report.Score = int(math.Round(100 * matched / total))
+	}
+	return report
+}
+
+// emphasized raises the weight of a term by a quarter for every repetition, up to twice its weight.
+func emphasized(weight float64, count int) float64 {
+	return weight * (1 + 0.25*float64(min(count-1, 4)))
+}
@@ -0,0 +1,92 @@
+package ats
+
+import (
+	"strings"
+	"testing"
+)
+
+const jobDescription = `Backend Engineer. Build microservices in Golang deployed on Kubernetes.
+Operate PostgreSQL and Kafka pipelines. Kubernetes operators are a plus.
+Own the observability of the pipelines with Prometheus. Mentoring junior engineers.`
+
+func termNames(terms []Term) []string {
+	var names []string
+	for _, t := range terms {
+		names = append(names, t.Term)
+	}
+	return names
+}
+
+func findTerm(terms []Term, name string) *Term {
+	for i := range terms {
+		if terms[i].Term == name {
+			return &terms[i]
+		}
+	}
+	return nil
+}
+
+func TestAnalyzeNormalizesTheSynonyms(t *testing.T) {
+	resume := "Go developer. Ran micro-services on k8s with Postgres, mentored two engineers. Built data pipelines."
+
+	report := Analyze(jobDescription, resume)
+
+	for _, name := range []string{"Go", "Kubernetes", "Microservices", "PostgreSQL", "Mentoring", "pipeline"} {
+		if findTerm(report.Matched, name) == nil {
+			t.Errorf("expected %s to be matched, matched %v", name, termNames(report.Matched))
+		}
+	}
+	for _, name := range []string{"Kafka", "Prometheus"} {
+		if findTerm(report.Missing, name) == nil {
+			t.Errorf("expected %s to be missing, missing %v", name, termNames(report.Missing))
+		}
+	}
+	if k8s := findTerm(report.Matched, "Kubernetes"); k8s.JobCount != 2 || k8s.ResumeCount != 1 || k8s.Weight != SKILL_WEIGHT*1.25 {
+		t.Errorf("unexpected Kubernetes term %+v", k8s)
+	}
+	if report.Score <= 0 || report.Score >= 100 {
+		t.Errorf("expected a partial score, got %d", report.Score)
+	}
+	// The heaviest terms come first.
+	if report.Missing[0].Weight < report.Missing[len(report.Missing)-1].Weight {
+		t.Errorf("expected the missing terms by weight, got %+v", report.Missing)
+	}
+}
+
+func TestAnalyzeFlagsTheOverusedTerms(t *testing.T) {
+	resume := strings.Repeat("Kubernetes k8s. ", 4) + "Golang PostgreSQL Kafka Prometheus microservices mentoring pipelines engineer"
+
+	report := Analyze(jobDescription, resume)
+
+	if names := termNames(report.Overused); len(names) != 1 || names[0] != "Kubernetes" {
+		t.Errorf("expected Kubernetes to be overused, got %v", names)
+	}
+	if report.Score != 100 || len(report.Missing) != 0 {
+		t.Errorf("expected a full match, got %d missing %v", report.Score, termNames(report.Missing))
+	}
+}
+
+func TestAnalyzeReadsTheCommonWordsAsSkillsOnlyInContext(t *testing.T) {
+	jd := "Own the go-to-market of the platform, go above and beyond, lead generation and a spring launch."
+
+	report := Analyze(jd, "Go developer who led the go-to-market")
+	for _, name := range []string{"Go", "Leadership", "Spring Boot"} {
+		if findTerm(report.Matched, name) != nil || findTerm(report.Missing, name) != nil {
+			t.Errorf("expected no %s skill in %q, got %v and %v", name, jd, termNames(report.Matched), termNames(report.Missing))
+		}
+	}
+
+	report = Analyze("Backend services in Go and Python, Java with Spring.", "Golang, Python and Spring Boot")
+	for _, name := range []string{"Go", "Python", "Spring Boot"} {
+		if findTerm(report.Matched, name) == nil {
+			t.Errorf("expected %s to be matched, matched %v", name, termNames(report.Matched))
+		}
+	}
+}
+
+func TestAnalyzeWithoutKeywords(t *testing.T) {
+	report := Analyze("", "Go developer")
+	if report.Score != 0 || len(report.Matched) != 0 || report.Missing == nil {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -13,10 +14,15 @@ import (
 
 	"cloud.google.com/go/storage"
 
+	"github.com/sounishnath003/customgo-mailer-service/internal/events"
+	"github.com/sounishnath003/customgo-mailer-service/internal/jobposting"
+	"github.com/sounishnath003/customgo-mailer-service/internal/llm"
 	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
-	"google.golang.org/genai"
 )
 
+// ErrStorageUnavailable is returned by the storage operations when the GCS client couldn't be created.
+var ErrStorageUnavailable = errors.New("GCS storage is not configured")
+
 type CoreOpts struct {
 	Port          int
 	MailAddr      string
@@ -24,11 +30,44 @@ type CoreOpts struct {
 	SmtpAddr      string
 	MongoDbUri    string
 	PdfServiceUri string
+	// AppBaseUrl is where the web app is served, used for the links in the notifications.
+	AppBaseUrl string
+
+	// LLMBackend is the llm backend, `vertex` (default), `openai` for any OpenAI-compatible endpoint, or `fake`.
+	LLMBackend string
+	// LLMBaseUrl and LLMApiKey configure the OpenAI-compatible backend, e.g. `http://localhost:11434/v1` for Ollama.
+	LLMBaseUrl string
+	LLMApiKey  string
+	// LLMStructuredOutput and LLMPdfInput tell whether the OpenAI-compatible endpoint enforces a JSON schema and reads PDF files,
+	// nil assumes both for OpenAI itself and neither for the self-hosted servers.
+	LLMStructuredOutput *bool
+	LLMPdfInput         *bool
+	// LLMProvider overrides the backend, for the tests.
+	LLMProvider llm.Provider
+	// LLMCacheTTL is how long the drafts and tailored resumes are answered from the cache, zero disables the cache.
+	LLMCacheTTL time.Duration
+	// LLMCacheMaxEntryBytes skips caching the larger answers, LLMCacheMaxBytes caps the whole cache.
+	LLMCacheMaxEntryBytes int
+	LLMCacheMaxBytes      int64
+	// LLMDailyTokenQuota and LLMMonthlyTokenQuota cap the tokens a user consumes per UTC day and month, zero is unlimited.
+	LLMDailyTokenQuota   int64
+	LLMMonthlyTokenQuota int64
+	// LLMInputCostPerMillion and LLMOutputCostPerMillion price the tokens, in USD, to estimate the cost of the calls.
+	LLMInputCostPerMillion  float64
+	LLMOutputCostPerMillion float64
+	// JobPostingCacheTTL is how long a fetched job posting is reused, zero disables the cache.
+	JobPostingCacheTTL time.Duration
+	// JobPostingMaxBytes and JobPostingTimeout bound the download of a job posting.
+	JobPostingMaxBytes int64
+	JobPostingTimeout  time.Duration
 
 	ModelName        string
 	GcpProjectID     string
 	GcpLocation      string
 	GcpStorageBucket string
+
+	// AdminEmails are the users allowed on the admin endpoints.
+	AdminEmails []string
 }
 
 // Core defines the core construct of the service.
@@ -36,12 +75,14 @@ type Core struct {
 	Port          int
 	DB            *repository.MongoDBClient
 	Lo            *slog.Logger
+	Events        *events.Bus
 	PdfServiceUri string
 
 	opts          *CoreOpts
 	smtpAuth      smtp.Auth
 	storageClient *storage.Client
-	llm           *genai.Client
+	llm           llm.Provider
+	jobPostings   *jobposting.Fetcher
 }
 
 // configureIndexesDB helps to configure database level constraints and checks.
@@ -54,6 +95,35 @@ func (co *Core) configureIndexesDB() {
 	co.createIndexHelper("ai_email_drafts", "userEmailAddress", false)
 	co.createIndexHelper("ai_email_drafts", "from", false)
 	co.createIndexHelper("ai_email_drafts", "companyName", false)
+	co.createTTLIndexHelper("idempotency_keys", "expiresAt")
+	co.createIndexHelper("outreach_policies", "userEmail", true)
+	co.createIndexHelper("resume_information", "email", true)
+	co.createCompoundIndexHelper("bulk_email_jobs", "userEmail", "_id")
+	co.createCompoundIndexHelper("bulk_email_jobs", "userEmail", "status", "createdAt")
+	co.createCompoundIndexHelper("job_queues", "status", "nextRunAt", "createdAt")
+	co.createCompoundIndexHelper("job_queues", "status", "priority", "runAt")
+	co.createCompoundIndexHelper("job_queues", "status", "leaseExpiresAt")
+	co.createIndexHelper("dead_jobs", "kind", false)
+	co.createCompoundIndexHelper("job_queues", "userEmailAddress", "kind", "createdAt")
+	co.createCompoundIndexHelper("dead_jobs", "userEmailAddress", "kind", "createdAt")
+	co.createCompoundIndexHelper("notifications_log", "jobId", "kind")
+	co.createCompoundIndexHelper("notifications_log", "userEmail", "createdAt")
+	co.createUniqueCompoundIndexHelper("prompt_versions", "promptId", "version")
+	co.createUniqueCompoundIndexHelper("prompt_activations", "promptId", "ownerEmail")
+	co.createTTLIndexHelper("llm_cache", "expiresAt")
+	co.createIndexHelper("llm_cache", "createdAt", false)
+	co.createCompoundIndexHelper("llm_usage", "userEmail", "createdAt")
+	co.createIndexHelper("llm_usage", "createdAt", false)
+	co.createIndexHelper("ai_email_drafts", "groupId", false)
+	co.createTTLIndexHelper("job_postings", "expiresAt")
+	co.createCompoundIndexHelper("tailored_resumes", "userId", "atsScore")
+
+	// The jobs queued before job kinds existed are looked up by their kind too.
+	if n, err := co.DB.BackfillLegacyJobKinds(); err != nil {
+		co.Lo.Error("failed to backfill the kind of the legacy jobs", "error", err)
+	} else if n > 0 {
+		co.Lo.Info("backfilled the kind of the legacy jobs", "count", n)
+	}
 }
 
 func NewCore(opts *CoreOpts) *Core {
@@ -62,6 +132,7 @@ func NewCore(opts *CoreOpts) *Core {
 		opts:          opts,
 		Port:          opts.Port,
 		Lo:            slog.Default(),
+		Events:        events.NewBus(),
 		PdfServiceUri: opts.PdfServiceUri,
 	}
 
@@ -86,20 +157,30 @@ func NewCore(opts *CoreOpts) *Core {
 	// Configure indexes managements
 	go co.configureIndexesDB()
 
-	// Initialize LLM model (Gemini).
-	if err := co.initializeLLM(); err != nil {
-		panic(err)
-	}
+	// Initialize the LLM provider.
+	co.initializeLLM()
+
+	co.jobPostings = jobposting.NewFetcher(jobposting.Options{MaxBytes: opts.JobPostingMaxBytes, Timeout: opts.JobPostingTimeout})
 
 	// Initialize Storage Client (GCS Bucket).
+	// Without Google Cloud credentials the service still starts, the resume uploads fail.
 	if err := co.initializeGCSClient(); err != nil {
 		co.Lo.Error("error occured during GCS storage client inisialization:", "error", fmt.Errorf("unable to create GCS storage client: %w", err))
-		panic(err)
 	}
 
 	return co
 }
 
+// IsAdmin tells whether the user is allowed on the admin endpoints.
+func (co *Core) IsAdmin(email string) bool {
+	for _, admin := range co.opts.AdminEmails {
+		if strings.EqualFold(strings.TrimSpace(admin), email) {
+			return true
+		}
+	}
+	return false
+}
+
 // initializeGCSClient initializes a new Google Cloud Storage (GCS) client and assigns it to the Core struct.
 // It sets a context with a timeout of 10 seconds for the client creation process.
 // If the client creation fails, it returns an error with a descriptive message.
@@ -138,6 +219,9 @@ func (co *Core) initializeGCSClient() error {
 //	}
 //	fmt.Printf("File uploaded to: %s\n", url)
 func (co *Core) UploadFileToGCSBucket(file *multipart.FileHeader) (string, error) {
+	if co.storageClient == nil {
+		return "", ErrStorageUnavailable
+	}
 
 	src, err := file.Open()
 	if err != nil {
@@ -166,6 +250,9 @@ func (co *Core) UploadFileToGCSBucket(file *multipart.FileHeader) (string, error
 // DownloadObjectFromGCSBucket downloads an object from GCS and stores it in the `storage` directory.
 // It returns the local file path of the downloaded object.
 func (co *Core) DownloadObjectFromGCSBucket(objectAddress string) (string, error) {
+	if co.storageClient == nil {
+		return "", ErrStorageUnavailable
+	}
 	// Parse the object address to get the bucket name and object name
 	bucketName, objectName, err := parseGCSObjectAddress(objectAddress)
 	if err != nil {
@@ -220,29 +307,3 @@ func parseGCSObjectAddress(objectAddress string) (string, string, error) {
 
 	return parts[0], parts[1], nil
 }
-
-func (co *Core) SubmitResumeToJobQueue(userEmailAddress, resumeGCSPath string) error {
-	// Get context.
-	ctx, cancel := getContextWithTimeout(10)
-	defer cancel()
-
-	collection := co.DB.Database("referrer").Collection("job_queues")
-
-	// Create a job
-	job := repository.JobQueue{
-		UserEmailAddress: userEmailAddress,
-		JobType:          repository.EXTRACT_CONTENT,
-		Status:           "PENDING",
-		CreatedAt:        time.Now(),
-		UpdatedAt:        time.Now(),
-		Payload: repository.Payload{
-			ResumeURL: resumeGCSPath,
-		},
-	}
-	_, err := collection.InsertOne(ctx, job)
-	if err != nil {
-		return fmt.Errorf("not able to process resume: %w", err)
-	}
-
-	return nil
-}
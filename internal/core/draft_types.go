@@ -0,0 +1,62 @@
+package core
+
+import "fmt"
+
+// DraftType is the kind of email drafted, each one has its own prompt.
+type DraftType string
+
+const (
+	// DRAFT_TYPE_REFERRAL asks an employee of the company for a referral.
+	DRAFT_TYPE_REFERRAL DraftType = "referral"
+	// DRAFT_TYPE_RECRUITER_OUTREACH cold mails a recruiter for an interview, the default.
+	DRAFT_TYPE_RECRUITER_OUTREACH DraftType = "recruiter-outreach"
+	// DRAFT_TYPE_FOLLOW_UP follows up on an email left unanswered.
+	DRAFT_TYPE_FOLLOW_UP DraftType = "follow-up"
+	// DRAFT_TYPE_THANK_YOU thanks the interviewer after an interview.
+	DRAFT_TYPE_THANK_YOU DraftType = "thank-you"
+	// DRAFT_TYPE_INFORMATIONAL_CHAT asks for a short informational chat about the team or the role.
+	DRAFT_TYPE_INFORMATIONAL_CHAT DraftType = "informational-chat"
+)
+
+// draftTypeSpecs are the prompts of the draft types, and the way their subject line is put together
+// from the drafted subject and the company name.
+var draftTypeSpecs = []struct {
+	Type          DraftType
+	Prompt        PromptID
+	SubjectFormat string
+}{
+	{DRAFT_TYPE_REFERRAL, PROMPT_REFERRAL_REQUEST_DRAFT, "Referral request for %s - %s"},
+	{DRAFT_TYPE_RECRUITER_OUTREACH, PROMPT_COLD_EMAIL_DRAFT, "Interested for %s - %s"},
+	{DRAFT_TYPE_FOLLOW_UP, PROMPT_FOLLOW_UP_DRAFT, "Following up on %s - %s"},
+	{DRAFT_TYPE_THANK_YOU, PROMPT_THANK_YOU_DRAFT, "Thank you - %s - %s"},
+	{DRAFT_TYPE_INFORMATIONAL_CHAT, PROMPT_INFORMATIONAL_CHAT_DRAFT, "Quick chat about %s - %s"},
+}
+
+// DraftTypes lists the draft types.
+func DraftTypes() []DraftType {
+	types := make([]DraftType, len(draftTypeSpecs))
+	for i, spec := range draftTypeSpecs {
+		types[i] = spec.Type
+	}
+	return types
+}
+
+// ParseDraftType checks the draft type is a known one.
+func ParseDraftType(draftType string) (DraftType, error) {
+	for _, spec := range draftTypeSpecs {
+		if string(spec.Type) == draftType {
+			return spec.Type, nil
+		}
+	}
+	return "", fmt.Errorf("unknown draft type %q, expected one of %v", draftType, DraftTypes())
+}
+
+// draftTypeSpec returns the spec of the draft type, a recruiter outreach when none is given.
+func draftTypeSpec(draftType DraftType) (PromptID, string) {
+	for _, spec := range draftTypeSpecs {
+		if spec.Type == draftType {
+			return spec.Prompt, spec.SubjectFormat
+		}
+	}
+	return PROMPT_COLD_EMAIL_DRAFT, "Interested for %s - %s"
+}
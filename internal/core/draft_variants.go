@@ -0,0 +1,136 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DraftTone is the tone a draft variant is written in.
+type DraftTone string
+
+const (
+	DRAFT_TONE_CONCISE   DraftTone = "concise"
+	DRAFT_TONE_WARM      DraftTone = "warm"
+	DRAFT_TONE_TECHNICAL DraftTone = "technical"
+	DRAFT_TONE_EXECUTIVE DraftTone = "executive"
+)
+
+// MAX_DRAFT_VARIANTS is the most variants drafted for a single request.
+const MAX_DRAFT_VARIANTS = 5
+
+// maxConcurrentDraftVariants bounds the variants drafted at the same time, not to burst the LLM backend.
+const maxConcurrentDraftVariants = 3
+
+// draftToneInstructions are handed over to the prompt, in the order the tones are picked.
+var draftToneInstructions = []struct {
+	Tone        DraftTone
+	Instruction string
+}{
+	{DRAFT_TONE_CONCISE, "Keep it short and to the point: three short paragraphs at most, no filler, every sentence earns its place."},
+	{DRAFT_TONE_WARM, "Be warm and personable: show genuine interest in the company and the people, while staying professional."},
+	{DRAFT_TONE_TECHNICAL, "Lead with the technical depth: name the relevant technologies, systems and measurable engineering results."},
+	{DRAFT_TONE_EXECUTIVE, "Write for a busy decision maker: lead with the business impact and the outcomes delivered, skip the implementation details."},
+}
+
+// DraftTones lists the tones, in the order they're picked for the variants.
+func DraftTones() []DraftTone {
+	tones := make([]DraftTone, len(draftToneInstructions))
+	for i, t := range draftToneInstructions {
+		tones[i] = t.Tone
+	}
+	return tones
+}
+
+// ParseDraftTone checks the tone is a known one.
+func ParseDraftTone(tone string) (DraftTone, error) {
+	for _, t := range draftToneInstructions {
+		if string(t.Tone) == tone {
+			return t.Tone, nil
+		}
+	}
+	return "", fmt.Errorf("unknown tone %q, expected one of %v", tone, DraftTones())
+}
+
+// toneInstruction is the prompt instruction of the tone, empty for no tone.
+func toneInstruction(tone DraftTone) string {
+	for _, t := range draftToneInstructions {
+		if t.Tone == tone {
+			return t.Instruction
+		}
+	}
+	return ""
+}
+
+// PickDraftTones returns the tones of `n` variants: the `requested` ones first, then the unused tones,
+// cycling through the tones again past their number.
+func PickDraftTones(n int, requested []DraftTone) ([]DraftTone, error) {
+	if n < 1 || n > MAX_DRAFT_VARIANTS {
+		return nil, fmt.Errorf("variants must be between 1 and %d", MAX_DRAFT_VARIANTS)
+	}
+	if len(requested) > n {
+		return nil, fmt.Errorf("%d tones asked for %d variants", len(requested), n)
+	}
+
+	tones := append([]DraftTone{}, requested...)
+	used := map[DraftTone]bool{}
+	for _, t := range requested {
+		used[t] = true
+	}
+	for _, t := range DraftTones() {
+		if len(tones) == n {
+			break
+		}
+		if !used[t] {
+			tones = append(tones, t)
+		}
+	}
+	for i := 0; len(tones) < n; i++ {
+		tones = append(tones, tones[i])
+	}
+	return tones, nil
+}
+
+// DraftColdEmailVariantsLLM drafts one variant of the email per tone, a few at a time.
+// The variants come back in the order of `tones`, numbered from 1. A tone asked again is drafted
+// with a higher temperature so it reads differently. Failed variants are left out, and the error is
+// only returned when none could be drafted.
+func (co *Core) DraftColdEmailVariantsLLM(ctx context.Context, in ColdEmailDraftInput, tones []DraftTone) ([]*ColdEmailDraft, error) {
+	drafts := make([]*ColdEmailDraft, len(tones))
+	errs := make([]error, len(tones))
+
+	sem := make(chan struct{}, maxConcurrentDraftVariants)
+	var wg sync.WaitGroup
+	seen := map[DraftTone]int{}
+	for i, tone := range tones {
+		variant := in
+		variant.Tone = tone
+		variant.Variant = i + 1
+		variant.Retake = seen[tone]
+		seen[tone]++
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			drafts[i], errs[i] = co.DraftColdEmailMessageLLM(ctx, variant)
+		}()
+	}
+	wg.Wait()
+
+	var variants []*ColdEmailDraft
+	for i, draft := range drafts {
+		if errs[i] != nil {
+			co.Lo.Warn("unable to draft the variant", "from", in.From, "tone", tones[i], "error", errs[i])
+			continue
+		}
+		variants = append(variants, draft)
+	}
+	if len(variants) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return variants, nil
+}
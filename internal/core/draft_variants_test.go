@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/llm"
+)
+
+func TestPickDraftTones(t *testing.T) {
+	tones, err := PickDraftTones(5, []DraftTone{DRAFT_TONE_TECHNICAL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DraftTone{DRAFT_TONE_TECHNICAL, DRAFT_TONE_CONCISE, DRAFT_TONE_WARM, DRAFT_TONE_EXECUTIVE, DRAFT_TONE_TECHNICAL}
+	if !slices.Equal(tones, want) {
+		t.Errorf("expected %v, got %v", want, tones)
+	}
+
+	for _, n := range []int{0, 6} {
+		if _, err := PickDraftTones(n, nil); err == nil {
+			t.Errorf("expected %d variants to be refused", n)
+		}
+	}
+	if _, err := PickDraftTones(1, []DraftTone{DRAFT_TONE_WARM, DRAFT_TONE_CONCISE}); err == nil {
+		t.Error("expected more tones than variants to be refused")
+	}
+	if _, err := ParseDraftTone("sarcastic"); err == nil {
+		t.Error("expected an unknown tone to be refused")
+	}
+}
+
+func TestDraftColdEmailVariants(t *testing.T) {
+	fake := &llm.Fake{Respond: func(req llm.Request) llm.FakeReply {
+		// The subject prompts hold no tone.
+		prompt := req.Parts[0].Text
+		if !strings.Contains(prompt, "Variant Tone") {
+			return llm.FakeReply{Text: "Engineer"}
+		}
+		for _, tone := range draftToneInstructions {
+			if strings.Contains(prompt, tone.Instruction) {
+				return llm.FakeReply{Text: string(tone.Tone) + " body"}
+			}
+		}
+		return llm.FakeReply{Err: errors.New("no tone in the prompt")}
+	}}
+	co := newTestCore(fake)
+
+	tones := []DraftTone{DRAFT_TONE_WARM, DRAFT_TONE_CONCISE, DRAFT_TONE_WARM}
+	drafts, err := co.DraftColdEmailVariantsLLM(context.Background(), testDraftInput, tones)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drafts) != len(tones) {
+		t.Fatalf("expected %d variants, got %d", len(tones), len(drafts))
+	}
+	for i, draft := range drafts {
+		if draft.Tone != tones[i] || draft.Variant != i+1 || draft.Body != string(tones[i])+" body" {
+			t.Errorf("unexpected variant %d: %+v", i+1, draft)
+		}
+	}
+
+	// The warm tone asked twice is drafted the second time with a higher temperature.
+	var retakes int
+	for _, call := range fake.Calls() {
+		if call.Temperature != nil && *call.Temperature > 1 {
+			retakes++
+		}
+	}
+	if retakes != 1 {
+		t.Errorf("expected a single retake, got %d", retakes)
+	}
+}
+
+func TestDraftColdEmailVariantsFailsWhenNoneIsDrafted(t *testing.T) {
+	unavailable := errors.New("model unavailable")
+	co := newTestCore(&llm.Fake{Respond: func(llm.Request) llm.FakeReply { return llm.FakeReply{Err: unavailable} }})
+
+	_, err := co.DraftColdEmailVariantsLLM(context.Background(), testDraftInput, []DraftTone{DRAFT_TONE_WARM, DRAFT_TONE_CONCISE})
+	if !errors.Is(err, unavailable) {
+		t.Errorf("expected the drafting error, got %v", err)
+	}
+}
@@ -10,10 +10,25 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/metrics"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
 )
 
+// sendMail hands the message to the SMTP server, recording the outcome and latency of the `kind` of email.
+func (co *Core) sendMail(kind, from string, to []string, msg []byte) error {
+	start := time.Now()
+	err := smtp.SendMail(fmt.Sprintf("%s:587", co.opts.SmtpAddr), co.smtpAuth, from, to, msg)
+
+	metrics.Since(metrics.SMTPSendDuration.WithLabelValues(kind), start)
+	metrics.SMTPSendsTotal.WithLabelValues(kind, metrics.Outcome(err)).Inc()
+	return err
+}
+
 // InvokeSendMail invokes Gmail SMTP configuration to send an email with an optional attachment.
-func (co *Core) InvokeSendMailWithAttachment(from string, to []string, subject, body, tailoredResumeID, attachmentPath string) error {
+// The AI draft the email was written from, if any, is stored along with the sent email.
+func (co *Core) InvokeSendMailWithAttachment(from string, to []string, subject, body, tailoredResumeID, attachmentPath string, draft *repository.SentDraft) error {
 	// Create a new multipart writer
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
@@ -65,19 +80,13 @@ func (co *Core) InvokeSendMailWithAttachment(from string, to []string, subject,
 	writer.Close()
 
 	// Send the email
-	err = smtp.SendMail(
-		fmt.Sprintf("%s:587", co.opts.SmtpAddr),
-		co.smtpAuth,
-		from,
-		to,
-		buf.Bytes(),
-	)
+	err = co.sendMail("referral", from, to, buf.Bytes())
 	if err != nil {
 		return err
 	}
 
 	// Store the email into the database
-	err = co.DB.CreateEmailInMailbox(from, to, subject, body, tailoredResumeID)
+	err = co.DB.CreateEmailInMailbox(from, to, subject, body, tailoredResumeID, draft)
 	if err != nil {
 		co.Lo.Error("error saving referral email into mailbox", "error", err)
 		return err
@@ -95,20 +104,14 @@ func (co *Core) InvokeSendMail(from string, to []string, subject, body, tailored
 	mailBody := fmt.Sprintf("To: %s\nSubject: %s\n%s\n\n%s\n\n", strings.Join(to, ","), subject, headers, body)
 
 	// // Sending email.
-	err := smtp.SendMail(
-		fmt.Sprintf("%s:587", co.opts.SmtpAddr),
-		co.smtpAuth,
-		from,
-		to,
-		[]byte(mailBody),
-	)
+	err := co.sendMail("referral", from, to, []byte(mailBody))
 
 	if err != nil {
 		return err
 	}
 
 	// Store the email into database.
-	err = co.DB.CreateEmailInMailbox(from, to, subject, body, tailoredResumeID)
+	err = co.DB.CreateEmailInMailbox(from, to, subject, body, tailoredResumeID, nil)
 	if err != nil {
 		co.Lo.Error("error saving referral email into mailbox", "error", err)
 		return err
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sweptFileGlobs are the local files the service leaves behind: the downloaded and uploaded resumes,
+// and the resume PDFs rendered for the attachments.
+var sweptFileGlobs = []string{
+	filepath.Join("storage", "*"),
+	filepath.Join(os.TempDir(), "Sounish_Naths_Resume_*.pdf"),
+}
+
+// SweepStaleFiles deletes the local files of the service not modified for `maxAge`.
+// Files still in use are recent, they are never old enough to be swept.
+func (co *Core) SweepStaleFiles(maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	var errs []error
+
+	for _, pattern := range sweptFileGlobs {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					errs = append(errs, err)
+				}
+				continue
+			}
+			if !info.Mode().IsRegular() || info.ModTime().After(cutoff) {
+				continue
+			}
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+				continue
+			}
+			removed++
+		}
+	}
+	return removed, errors.Join(errs...)
+}
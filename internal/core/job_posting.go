@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/jobposting"
+	"github.com/sounishnath003/customgo-mailer-service/internal/metrics"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+// MAX_FETCHED_JOB_URLS is how many of the job URLs of a request are fetched.
+const MAX_FETCHED_JOB_URLS = 3
+
+// FetchJobPosting returns the job posting at `url`, from the cache when it was fetched lately.
+// The cache never fails a lookup, its errors are only logged.
+func (co *Core) FetchJobPosting(ctx context.Context, url string) (*jobposting.Posting, error) {
+	url = strings.TrimSpace(url)
+	cacheEnabled := co.opts.JobPostingCacheTTL > 0 && co.DB != nil
+
+	if cacheEnabled {
+		entry, err := co.DB.GetJobPostingCacheEntry(url)
+		if err != nil {
+			co.Lo.Warn("unable to read the job posting cache", "url", url, "error", err)
+		}
+		if entry != nil {
+			metrics.JobPostingLookupsTotal.WithLabelValues("hit").Inc()
+			return &jobposting.Posting{
+				URL:         entry.FinalURL,
+				Title:       entry.Title,
+				Company:     entry.Company,
+				Location:    entry.Location,
+				Description: entry.Description,
+				Structured:  entry.Structured,
+				Truncated:   entry.Truncated,
+			}, nil
+		}
+	}
+
+	posting, err := co.jobPostings.Fetch(ctx, url)
+	if err != nil {
+		metrics.JobPostingLookupsTotal.WithLabelValues("error").Inc()
+		return nil, err
+	}
+	metrics.JobPostingLookupsTotal.WithLabelValues("fetched").Inc()
+
+	if cacheEnabled {
+		err := co.DB.PutJobPostingCacheEntry(&repository.JobPostingCacheEntry{
+			URL:         url,
+			FinalURL:    posting.URL,
+			Title:       posting.Title,
+			Company:     posting.Company,
+			Location:    posting.Location,
+			Description: posting.Description,
+			Structured:  posting.Structured,
+			Truncated:   posting.Truncated,
+			ExpiresAt:   time.Now().Add(co.opts.JobPostingCacheTTL),
+		})
+		if err != nil {
+			co.Lo.Warn("unable to cache the job posting", "url", url, "error", err)
+		}
+	}
+	return posting, nil
+}
+
+// JobDescriptionFromUrls fetches the first MAX_FETCHED_JOB_URLS job URLs side by side, and joins their postings
+// into a job description. The postings which couldn't be fetched are left out, an error is only returned when none could.
+func (co *Core) JobDescriptionFromUrls(ctx context.Context, urls []string) (string, error) {
+	var targets []string
+	for _, url := range urls {
+		if url = strings.TrimSpace(url); url != "" {
+			targets = append(targets, url)
+		}
+	}
+	if len(targets) > MAX_FETCHED_JOB_URLS {
+		targets = targets[:MAX_FETCHED_JOB_URLS]
+	}
+	if len(targets) == 0 {
+		return "", jobposting.ErrNoDescription
+	}
+
+	postings := make([]*jobposting.Posting, len(targets))
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, url := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			postings[i], errs[i] = co.FetchJobPosting(ctx, url)
+		}()
+	}
+	wg.Wait()
+
+	var texts []string
+	for i, posting := range postings {
+		if errs[i] != nil {
+			co.Lo.Warn("unable to fetch the job posting", "url", targets[i], "error", errs[i])
+			continue
+		}
+		texts = append(texts, posting.Text())
+	}
+	if len(texts) == 0 {
+		return "", errors.Join(errs...)
+	}
+	return strings.Join(texts, "\n\n---\n\n"), nil
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/jobposting"
+	"github.com/sounishnath003/customgo-mailer-service/internal/llm"
+)
+
+func TestJobDescriptionFromUrlsSkipsTheFailedPostings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jobs/1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Backend Engineer</title></head><body><main><p>Go and Kubernetes.</p></main></body></html>`))
+	}))
+	defer srv.Close()
+
+	co := newTestCore(llm.NewEchoFake())
+	co.jobPostings = jobposting.NewFetcher(jobposting.Options{AllowPrivateHosts: true})
+
+	jd, err := co.JobDescriptionFromUrls(context.Background(), []string{" ", srv.URL + "/gone", srv.URL + "/jobs/1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jd != "Backend Engineer\n\nGo and Kubernetes." {
+		t.Errorf("unexpected job description %q", jd)
+	}
+
+	if _, err := co.JobDescriptionFromUrls(context.Background(), []string{srv.URL + "/gone"}); err == nil {
+		t.Error("expected an error when no posting could be fetched")
+	}
+}
@@ -1,314 +1,306 @@
 package core
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"net/http"
+	"os"
+	"strings"
 	"time"
 
-	"google.golang.org/genai"
+	"github.com/sounishnath003/customgo-mailer-service/internal/llm"
+	"github.com/sounishnath003/customgo-mailer-service/internal/metrics"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
 )
 
-// initializeLLM initializes the LLM (Large Language Model) client for the Core instance.
-func (co *Core) initializeLLM() error {
+// initializeLLM initializes the LLM provider of the configured backend.
+// A backend failing to initialize doesn't prevent the service from starting, the LLM features fail until it's fixed.
+func (co *Core) initializeLLM() {
+	if co.opts.LLMProvider != nil {
+		co.llm = co.opts.LLMProvider
+		return
+	}
+
 	ctx, cancel := getContextWithTimeout(5)
 	defer cancel()
 
-	// Correct client initialization for Vertex AI
-	timeDuration := 60 * time.Second
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		HTTPOptions: genai.HTTPOptions{
-			APIVersion: "v1",
-			Timeout:    &timeDuration,
-			Headers: http.Header{
-				"X-Vertex-AI-LLM-Request-Type": []string{"shared"},
-			},
-		},
-		Project: co.opts.GcpProjectID, Location: co.opts.GcpLocation, Backend: genai.BackendVertexAI},
-	)
+	provider, err := llm.New(ctx, llm.Config{
+		Backend:      co.opts.LLMBackend,
+		Model:        co.opts.ModelName,
+		GcpProjectID: co.opts.GcpProjectID,
+		GcpLocation:  co.opts.GcpLocation,
+		BaseURL:      co.opts.LLMBaseUrl,
+		APIKey:       co.opts.LLMApiKey,
+
+		StructuredOutput: co.opts.LLMStructuredOutput,
+		FileInput:        co.opts.LLMPdfInput,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create genai client: %w", err)
+		co.Lo.Error("unable to initialize the llm backend, the AI features are unavailable", "backend", co.opts.LLMBackend, "error", err)
+		co.llm = llm.Unavailable(err)
+		return
 	}
-	co.llm = client
+	co.Lo.Info("llm backend initialized", "backend", provider.Name(), "model", co.opts.ModelName)
+	co.llm = provider
+}
+
+// generateContent calls the model with a plain prompt, see generate.
+func (co *Core) generateContent(ctx context.Context, userEmail, method string, parts ...llm.Part) (*llm.Response, error) {
+	return co.generate(ctx, userEmail, method, llm.Request{Parts: parts})
+}
+
+// generate calls the model on behalf of the user, once their token quotas are checked,
+// recording the latency, outcome and token usage of the core `method`.
+func (co *Core) generate(ctx context.Context, userEmail, method string, req llm.Request) (*llm.Response, error) {
+	return co.generateStream(ctx, userEmail, method, req, nil)
+}
 
-	return nil
+// generateStream is generate handing the answer over to `onChunk` as it's produced, when set.
+func (co *Core) generateStream(ctx context.Context, userEmail, method string, req llm.Request, onChunk llm.StreamFunc) (*llm.Response, error) {
+	if err := co.CheckLLMQuota(userEmail); err != nil {
+		metrics.LLMRequestsTotal.WithLabelValues(method, "quota_exceeded").Inc()
+		return nil, err
+	}
+
+	start := time.Now()
+	var res *llm.Response
+	var err error
+	var streamed strings.Builder
+	if onChunk != nil {
+		res, err = llm.GenerateStream(ctx, co.llm, req, func(chunk string) error {
+			streamed.WriteString(chunk)
+			return onChunk(chunk)
+		})
+	} else {
+		res, err = co.llm.Generate(ctx, req)
+	}
+
+	metrics.Since(metrics.LLMRequestDuration.WithLabelValues(method), start)
+	metrics.LLMRequestsTotal.WithLabelValues(method, metrics.Outcome(err)).Inc()
+	estimated := false
+	if err != nil && onChunk != nil && (streamed.Len() > 0 || ctx.Err() != nil) {
+		// A stream stopped midway, most often by the client, used tokens all the same but comes without its usage.
+		res = &llm.Response{Text: streamed.String(), Model: co.opts.ModelName, Usage: estimatedLLMUsage(req, streamed.String())}
+		estimated = true
+	}
+	if res != nil {
+		metrics.LLMTokensTotal.WithLabelValues(method, "prompt").Add(float64(res.Usage.PromptTokens))
+		metrics.LLMTokensTotal.WithLabelValues(method, "completion").Add(float64(res.Usage.CompletionTokens))
+		co.recordLLMUsage(userEmail, method, res, time.Since(start), estimated)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
 }
 
-func printResponse(res *genai.GenerateContentResponse) string {
-	var result string
-	for _, cand := range res.Candidates {
-		if cand.Content != nil {
-			for _, part := range cand.Content.Parts {
-				result += fmt.Sprint(part.Text)
-			}
+// resumeFilePart is the resume as a prompt part. The backends not fetching the remote files get its content inlined.
+func (co *Core) resumeFilePart(resumePath string) (llm.Part, error) {
+	caps := co.llm.Capabilities()
+	if caps.FileURIs {
+		return llm.FileURI(resumePath, "application/pdf"), nil
+	}
+	if !caps.FileInput {
+		return llm.Part{}, fmt.Errorf("%w: the %s backend isn't configured to read the resume PDF, see LLM_PDF_INPUT", llm.ErrUnsupportedPart, co.llm.Name())
+	}
+
+	localPath := resumePath
+	if strings.HasPrefix(resumePath, "gs://") {
+		downloaded, err := co.DownloadObjectFromGCSBucket(resumePath)
+		if err != nil {
+			return llm.Part{}, err
 		}
+		defer os.Remove(downloaded)
+		localPath = downloaded
 	}
-	return result
+
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return llm.Part{}, fmt.Errorf("unable to read the resume: %w", err)
+	}
+	return llm.InlineFile(data, "application/pdf"), nil
 }
 
-func (co *Core) ExtractResumeContentLLM(resumePath string) (string, error) {
+// ExtractResumeContentLLM extracts the content of the resume PDF as markdown.
+func (co *Core) ExtractResumeContentLLM(ctx context.Context, userEmail, resumePath string) (string, error) {
 	co.Lo.Info("started extracting content", "resume", resumePath)
 
-	ctx, cancel := getContextWithTimeout(30)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	res, err := co.llm.Models.GenerateContent(ctx, co.opts.ModelName, []*genai.Content{{
-		Role: "user",
-		Parts: []*genai.Part{
-			{FileData: &genai.FileData{MIMEType: "application/pdf", FileURI: resumePath}},
-			{Text: `
-		[Role]: You are an expert HR Tech Specialist responsible for parsing and structuring resume data.
-
-		[Task]:
-		Extract all key information from the provided resume and structure it for a database.
-
-		[Instructions]:
-		1.  **Candidate Name**: Start with "## Candidate Name". Do not add any text before it.
-		2.  **Contact Information**: Extract email, phone number, and personal website/portfolio.
-		3.  **Social Links**: Extract all social media links (e.g., LinkedIn, GitHub, Twitter).
-		4.  **Skillsets**:
-			-   Categorize skills into: 'Programming Languages', 'Frameworks & Libraries', 'Databases', 'Cloud & DevOps', and 'Tools'.
-			-   List skills under their respective categories.
-		5.  **Work Experience**:
-			-   Detail all work experiences in reverse chronological order.
-			-   For each role, include: 'Job Title', 'Company', 'Location', 'Dates of Employment', and 3-5 bullet points describing key responsibilities and quantifiable achievements (e.g., "Increased API response time by 30%").
-		6.  **Projects**:
-			-   List all personal or professional projects.
-			-   For each project, include: 'Project Name', 'Technologies Used', and links to demos or source code (e.g., GitHub, live URL).
-		7.  **Achievements**: If any awards or recognitions are mentioned, extract them. If not, omit this section.
-		8.  **Education**:
-			-   Extract the most recent educational qualifications.
-			-   Include: 'University', 'Degree', 'Field of Study', and 'Graduation Year'.
-			-   Omit high school details.
-
-		[Output Format]:
-		The output must be a well-structured markdown document.
-	`},
-		},
-	}}, nil)
+	resume, err := co.resumeFilePart(resumePath)
 	if err != nil {
-		return "", fmt.Errorf("unable to generate contents: %w", err)
+		return "", err
+	}
+	prompt, _, err := co.RenderPrompt(userEmail, PROMPT_RESUME_EXTRACTION, nil)
+	if err != nil {
+		return "", err
 	}
 
-	if len(res.Candidates) == 0 {
-		return "", errors.New("empty response from model")
+	res, err := co.generateContent(ctx, userEmail, "ExtractResumeContentLLM", resume, llm.Text(prompt))
+	if err != nil {
+		return "", fmt.Errorf("unable to generate contents: %w", err)
 	}
 
-	return printResponse(res), nil
+	return res.Text, nil
 }
 
 // GenerateProfileSummaryLLM generates a summary of a resume using an AI model.
-func (co *Core) GenerateProfileSummaryLLM(content string) (string, error) {
-	ctx, cancel := getContextWithTimeout(30)
+func (co *Core) GenerateProfileSummaryLLM(ctx context.Context, userEmail, content string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	res, err := co.llm.Models.GenerateContent(ctx, co.opts.ModelName, []*genai.Content{{
-		Role: "user",
-		Parts: []*genai.Part{
-			{Text: content},
-			{Text: `
-			[Role]: You are a Professional Content Generation Specialist.
-
-			[Task]: Summarize the given resume content into concise bullet points.
-
-			[Instructions]:
-			1.  Focus on professional work experience, skills, projects, and achievements.
-			2.  Ensure contact details (phone, email, LinkedIn, portfolio, etc.) are included in the summary.
-			3.  The entire output must be in Markdown format.
-	`},
-		},
-	}}, nil)
+	prompt, _, err := co.RenderPrompt(userEmail, PROMPT_PROFILE_SUMMARY, map[string]any{
+		"ResumeContent": content,
+	})
 	if err != nil {
-		return "", fmt.Errorf("unable to generate contents: %w", err)
+		return "", err
 	}
 
-	if len(res.Candidates) == 0 {
-		return "", errors.New("empty response from model")
+	res, err := co.generateContent(ctx, userEmail, "GenerateProfileSummaryLLM", llm.Text(prompt))
+	if err != nil {
+		return "", fmt.Errorf("unable to generate contents: %w", err)
 	}
 
-	return printResponse(res), nil
+	return res.Text, nil
 }
 
-func (co *Core) ConvertResumeToJSONStructLLM(content string) (string, error) {
-	ctx, cancel := getContextWithTimeout(10)
-	defer cancel()
-
-	res, err := co.llm.Models.GenerateContent(ctx, co.opts.ModelName, []*genai.Content{{
-		Role: "user",
-		Parts: []*genai.Part{
-			{Text: content},
-			{Text: `
-		[Role]: You are a Senior Data Entry Specialist.
-
-		[Task]: Convert the provided resume content into a structured JSON format.
-
-		[Instructions]:
-		1.  Adhere strictly to the JSON schema provided below.
-		2.  The JSON output must be a single line of text without any special characters or formatting.
-		3.  Do not include any explanatory text or markdown formatting in your response.
+// ColdEmailDraftInput is what a cold email is drafted from.
+type ColdEmailDraftInput struct {
+	From           string
+	To             string
+	CompanyName    string
+	JobRole        string
+	JobDescription string
+	ProfileSummary string
+	JobUrls        []string
+	// Type picks the prompt, a recruiter outreach by default.
+	Type DraftType
+	// PreviousEmail is the unanswered email a follow-up is about.
+	PreviousEmail string
+	// Tone is the tone of the variant, none by default.
+	Tone DraftTone
+	// Variant numbers the variant among its siblings, from 1.
+	Variant int
+	// Retake counts the variants of the same tone drafted before this one, they're told apart by a higher temperature.
+	Retake int
+}
 
-		[JSON Schema]:
-		'{"fullName":"string","email":"string","skills":{"programmingLanguages":["string"],"toolsAndTechnologies":["string"],"frameworks":["string"],"cloudPlatforms":["string"],"miscellenous":["string"]},"socialLinks":[{"type":"string","value":"string"}],"workExperiences":[{"organizationName":"string","location":"string","tenure":"string","experiences":"string"}],"personalProjects":[{"name":"string","projectDemos":[{"type":"string","value":"string"}],"features":"string"}],"educations":[{"institutionName":"string","marksObtained":"string"}],"achievements":[{"details":"string"}]}'
-	`},
-		},
-	}}, nil)
+// ColdEmailDraft is a drafted email, with the prompt versions which produced it.
+type ColdEmailDraft struct {
+	Subject       string
+	Body          string
+	Prompt        repository.PromptRef
+	SubjectPrompt repository.PromptRef
+	// Cached is true when both the body and the subject came from the LLM cache.
+	Cached  bool
+	Tone    DraftTone
+	Variant int
+}
 
+// DraftColdEmailMessageLLM drafts the body, then the subject line, of a cold email from the user `in.From`.
+// The answers are cached, see WithoutLLMCache to draft afresh.
+func (co *Core) DraftColdEmailMessageLLM(ctx context.Context, in ColdEmailDraftInput) (*ColdEmailDraft, error) {
+	draft, err := co.StreamColdEmailMessageLLM(ctx, in, nil)
 	if err != nil {
-		return "", fmt.Errorf("unable to generate contents: %w", err)
+		return nil, err
 	}
-
-	if len(res.Candidates) == 0 {
-		return "", errors.New("empty response from model")
-	}
-
-	return printResponse(res), nil
+	return draft, nil
 }
 
-// DraftColdEmailMessageLLM helps to generate a draft email and returns (mailSubject, mailBody, error).
-func (co *Core) DraftColdEmailMessageLLM(from, to, companyName, templateType, jobDescription, userProfileSummary string, jobUrls []string) (string, string, error) {
-	ctx, cancel := getContextWithTimeout(60)
+// StreamColdEmailMessageLLM is DraftColdEmailMessageLLM handing the body over to `onBodyChunk` as it's drafted.
+// The subject line is only drafted once the body is complete. On a failure, like a cancelled `ctx`,
+// the draft returned with the error holds what was drafted so far.
+func (co *Core) StreamColdEmailMessageLLM(ctx context.Context, in ColdEmailDraftInput, onBodyChunk llm.StreamFunc) (*ColdEmailDraft, error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 
-	res, err := co.llm.Models.GenerateContent(ctx, co.opts.ModelName, []*genai.Content{{
-		Role: "user",
-		Parts: []*genai.Part{
-			{Text: fmt.Sprintf(`
-				** JOB Opportunity Details:**
-
-				To: %s
-				CompanyName: %s
-				JOB URLs: %v,
-				JobDescription: %s
-				
-				** Candidate Profile:**
-
-				%s
-
-			`, to, companyName, jobUrls, jobDescription, userProfileSummary)},
-			{Text: fmt.Sprintf(`
-			[Role]: You are a professional career coach and expert cold-email copywriter drafting an email on behalf of a candidate.
-
-			[Task]: Write a compelling cold email to a recruiter to secure an interview for a job opportunity.
-
-			[Instructions]:
-			1.  **Tone and Style**: Write in the first person from the candidate's perspective. The tone must be professional, concise, and genuinely enthusiastic.
-			2.  **Structure**:
-				- **Opening**: Start with a strong opening that grabs the recruiter's attention.
-				- **Body**: Highlight the candidate's most relevant skills and experiences, directly aligning them with the job description. Use the STAR method (Situation, Task, Action, Result) to frame 1-2 key achievements (e.g., "Achieved X by doing Y, resulting in Z").
-				- **Call to Action**: End with a clear and confident call to action, suggesting a brief chat.
-				- **Signature**: Include a professional signature with the candidate's full contact details (phone, email, LinkedIn, portfolio).
-			3.  **Formatting**:
-				- Use Markdown for clear formatting.
-				- Emphasize key skills and achievements with bold keywords.
-				- List the "JOB URLs" as a bulleted list if applicable.
-			4.  **Constraints**:
-				- The email body must be under 200 words to ensure it gets read.
-				- Do not include the subject line in the output.
-		`, templateType)},
-		},
-	}}, nil)
-
+	draft := &ColdEmailDraft{Tone: in.Tone, Variant: in.Variant}
+
+	promptID, subjectFormat := draftTypeSpec(in.Type)
+	prompt, ref, err := co.RenderPrompt(in.From, promptID, map[string]any{
+		"To":             in.To,
+		"CompanyName":    in.CompanyName,
+		"JobRole":        in.JobRole,
+		"JobUrls":        in.JobUrls,
+		"JobDescription": in.JobDescription,
+		"ProfileSummary": in.ProfileSummary,
+		"TemplateType":   string(in.Type),
+		"PreviousEmail":  in.PreviousEmail,
+		"Tone":           toneInstruction(in.Tone),
+	})
 	if err != nil {
-		return "", "", fmt.Errorf("unable to generate mailbody contents: %w", err)
+		return nil, err
 	}
+	draft.Prompt = ref
 
-	if len(res.Candidates) == 0 {
-		return "", "", errors.New("empty response mailBody from model")
+	req := llm.Request{Parts: []llm.Part{llm.Text(prompt)}}
+	if in.Retake > 0 {
+		temperature := min(float32(1.0+0.2*float64(in.Retake)), 2)
+		req.Temperature = &temperature
 	}
 
-	mailBody := printResponse(res)
-
-	res, err = co.llm.Models.GenerateContent(ctx, co.opts.ModelName, []*genai.Content{{
-		Role: "user",
-		Parts: []*genai.Part{
-			{Text: mailBody},
-			{Text: fmt.Sprintf(`
-		[Role]: You are an expert copywriter specializing in email marketing.
-
-		[Task]: Generate a concise, professional, and attention-grabbing email subject line based on the provided email body and job details.
-
-		[Instructions]:
-		1.  The subject line must be tailored to the email content, job description, and company.
-		2.  It must explicitly mention the company name: "%s".
-		3.  The output should ONLY be the subject line itself, without any extra text, quotes, or formatting.
-	`, companyName, companyName)},
-		},
-	}}, nil)
+	var partialBody strings.Builder
+	var onChunk llm.StreamFunc
+	if onBodyChunk != nil {
+		onChunk = func(chunk string) error {
+			partialBody.WriteString(chunk)
+			return onBodyChunk(chunk)
+		}
+	}
+	res, bodyCached, err := co.cachedGenerateStream(ctx, in.From, "DraftColdEmailMessageLLM", ref, req, onChunk)
 	if err != nil {
-		return "", "", fmt.Errorf("unable to generate type.Of.Job contents: %w", err)
+		draft.Body = partialBody.String()
+		return draft, fmt.Errorf("unable to generate mailbody contents: %w", err)
 	}
+	draft.Body = res.Text
 
-	if len(res.Candidates) == 0 {
-		return "", "", errors.New("empty response type.Of.Job from model")
+	prompt, ref, err = co.RenderPrompt(in.From, PROMPT_EMAIL_SUBJECT, map[string]any{
+		"MailBody":    draft.Body,
+		"CompanyName": in.CompanyName,
+	})
+	if err != nil {
+		return draft, err
 	}
+	draft.SubjectPrompt = ref
 
-	mailSubjectContent := printResponse(res)
-	mailSubject := fmt.Sprintf("Interested for %s - %s", mailSubjectContent, companyName)
-
-	// Store the draft into DB
-	_, err = co.DB.CreateAiDraftEmail(from, to, companyName, templateType, jobDescription, userProfileSummary, mailSubject, mailBody, mailSubject, jobUrls)
-
+	res, subjectCached, err := co.cachedGenerate(ctx, in.From, "DraftColdEmailMessageLLM", ref, llm.Request{Parts: []llm.Part{llm.Text(prompt)}})
 	if err != nil {
-		return "", "", err
+		return draft, fmt.Errorf("unable to generate type.Of.Job contents: %w", err)
 	}
+	draft.Subject = fmt.Sprintf(subjectFormat, res.Text, in.CompanyName)
+	draft.Cached = bodyCached && subjectCached
+
+	return draft, nil
+}
 
-	return mailSubject, mailBody, nil
+// ResumeTailoring is a tailored resume, with the prompt version which produced it.
+type ResumeTailoring struct {
+	Markdown string
+	Prompt   repository.PromptRef
+	// Cached is true when the resume came from the LLM cache.
+	Cached bool
 }
 
 // TailorResumeWithJobDescriptionLLM generates a tailored, ATS-friendly resume in Markdown format.
-func (co *Core) TailorResumeWithJobDescriptionLLM(jobDescription, extractedContent, companyName, jobRole string) (string, error) {
-	ctx, cancel := getContextWithTimeout(30)
+// The answers are cached, see WithoutLLMCache to tailor afresh.
+func (co *Core) TailorResumeWithJobDescriptionLLM(ctx context.Context, userEmail, jobDescription, extractedContent, companyName, jobRole string) (*ResumeTailoring, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	res, err := co.llm.Models.GenerateContent(ctx, co.opts.ModelName, []*genai.Content{{
-		Role: "user",
-		Parts: []*genai.Part{
-			{Text: "[Job Description]:\n" + jobDescription + "\n[Company Name]:\n" + companyName + "\n[Job Role]:\n" + jobRole + "\n[Extracted Resume Content]:\n" + extractedContent},
-			{Text: `
-[Role]: You are an expert FAANG resume strategist and ATS optimization specialist.
-
-[Task]: Generate a concise, single-page, and strictly ATS-friendly Software Engineer resume in Markdown. The resume must be tailored to the provided job description, company, and role, with impeccable spelling and grammar.
-
-[Instructions]:
-1.  **ATS-Friendliness is Priority**: Use standard, single-column formatting. Avoid tables, columns, and images. Use standard bullet points (e.g., '-').
-2.  **Header**:
-    - Start with the candidate's name as an H1 heading.
-    - Follow with contact information (Email, LinkedIn, GitHub, Phone) on a single line, separated by '|'.
-    - LinkedIn and GitHub links in the contact section MUST be full URLs, not hyperlinks (e.g., https://linkedin.com/in/user).
-3.  **Professional Summary**:
-    - Write a brief, impactful summary (2-3 sentences) tailored to the job, highlighting key skills and years of experience.
-4.  **Skills**:
-    - Group skills into logical categories (e.g., Languages, Frameworks, Cloud/DevOps, Tools).
-    - List skills as bullet points, matching keywords from the job description where appropriate.
-5.  **Work Experience**:
-    - List up to 3 of the most relevant roles in reverse-chronological order.
-    - For each role, provide 2-4 bullet points using the STAR or XYZ method to quantify achievements (e.g., "Increased performance by 20% by implementing X").
-    - **Use strong, varied action verbs.** Do not repeat the same verbs across different bullet points. Proofread carefully for spelling and grammatical errors.
-6.  **Projects**:
-    - Include up to 2 of the most relevant personal projects.
-    - For each project, provide a title and 2-3 bullet points describing the project, technologies used, and its relevance to the job.
-    - Project demo URLs (GitHub, YouTube, etc.) MUST be in Markdown hyperlink format (e.g., [GitHub](https://github.com/user/project)).
-
-[Output Format]:
-- The entire output must be in standard Markdown.
-- Do not include any commentary or explanations outside of the resume content.
-
-[Constraints]:
-- **Strictly One Page**: The resume must be very concise and MUST NOT exceed one page (aim for under 600 words). Be aggressive in summarization.
-- **No Exaggeration**: Present skills and achievements accurately and honestly. Do not invent or overstate qualifications.
-- **Relevance is Key**: Omit any information not directly relevant to the target role.
-`},
-			{Text: "\n\nNote: Ensure the language is professional, achievements are quantified, and there are no spelling mistakes. The resume must be of the highest ATS standard."},
-		},
-	}}, nil)
-
+	prompt, ref, err := co.RenderPrompt(userEmail, PROMPT_RESUME_TAILORING, map[string]any{
+		"JobDescription": jobDescription,
+		"CompanyName":    companyName,
+		"JobRole":        jobRole,
+		"ResumeContent":  extractedContent,
+	})
 	if err != nil {
-		return "", fmt.Errorf("unable to generate tailored resume: %w", err)
+		return nil, err
 	}
 
-	if len(res.Candidates) == 0 {
-		return "", errors.New("empty response from model")
+	res, cached, err := co.cachedGenerate(ctx, userEmail, "TailorResumeWithJobDescriptionLLM", ref, llm.Request{Parts: []llm.Part{llm.Text(prompt)}})
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate tailored resume: %w", err)
 	}
 
-	return printResponse(res), nil
+	return &ResumeTailoring{Markdown: res.Text, Prompt: ref, Cached: cached}, nil
 }
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/llm"
+	"github.com/sounishnath003/customgo-mailer-service/internal/metrics"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+type llmCacheBypassKey struct{}
+
+// WithoutLLMCache makes the generations under `ctx` skip the cached answers, the fresh answers are still cached.
+func WithoutLLMCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, llmCacheBypassKey{}, true)
+}
+
+func llmCacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(llmCacheBypassKey{}).(bool)
+	return bypass
+}
+
+// llmCacheEnabled tells whether the answers are cached, the cache is off without a TTL.
+func (co *Core) llmCacheEnabled() bool {
+	return co.opts.LLMCacheTTL > 0 && co.DB != nil
+}
+
+// cachedGenerate answers from the cache when the same model was already asked the same rendered prompt version,
+// otherwise calls the model, see generate, and caches the answer. It tells whether the answer came from the cache.
+// The cache never fails a generation, its errors are only logged. The cached answers don't count against the quotas.
+func (co *Core) cachedGenerate(ctx context.Context, userEmail, method string, prompt repository.PromptRef, req llm.Request) (*llm.Response, bool, error) {
+	return co.cachedGenerateStream(ctx, userEmail, method, prompt, req, nil)
+}
+
+// cachedGenerateStream is cachedGenerate handing the answer over to `onChunk` when set,
+// a cached answer in a single chunk.
+func (co *Core) cachedGenerateStream(ctx context.Context, userEmail, method string, prompt repository.PromptRef, req llm.Request, onChunk llm.StreamFunc) (*llm.Response, bool, error) {
+	if !co.llmCacheEnabled() {
+		res, err := co.generateStream(ctx, userEmail, method, req, onChunk)
+		return res, false, err
+	}
+
+	key := llmCacheKey(co.llm.Name(), co.opts.ModelName, method, prompt, req)
+	if llmCacheBypassed(ctx) {
+		metrics.LLMCacheLookupsTotal.WithLabelValues(method, "bypass").Inc()
+	} else {
+		entry, err := co.DB.GetLLMCacheEntry(key)
+		if err != nil {
+			co.Lo.Warn("unable to read the llm cache", "method", method, "error", err)
+		}
+		if entry != nil {
+			metrics.LLMCacheLookupsTotal.WithLabelValues(method, "hit").Inc()
+			if onChunk != nil {
+				if err := onChunk(entry.Text); err != nil {
+					return nil, false, err
+				}
+			}
+			return &llm.Response{Text: entry.Text, Model: entry.Model}, true, nil
+		}
+		metrics.LLMCacheLookupsTotal.WithLabelValues(method, "miss").Inc()
+	}
+
+	res, err := co.generateStream(ctx, userEmail, method, req, onChunk)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if co.opts.LLMCacheMaxEntryBytes > 0 && len(res.Text) > co.opts.LLMCacheMaxEntryBytes {
+		return res, false, nil
+	}
+	err = co.DB.PutLLMCacheEntry(&repository.LLMCacheEntry{
+		Key:       key,
+		Method:    method,
+		Backend:   co.llm.Name(),
+		Model:     res.Model,
+		Prompt:    prompt,
+		Text:      res.Text,
+		ExpiresAt: time.Now().Add(co.opts.LLMCacheTTL),
+	})
+	if err != nil {
+		co.Lo.Warn("unable to cache the llm answer", "method", method, "error", err)
+	}
+	return res, false, nil
+}
+
+// TrimLLMCache evicts the oldest cached answers beyond the configured size limit.
+func (co *Core) TrimLLMCache() (int64, error) {
+	if !co.llmCacheEnabled() || co.opts.LLMCacheMaxBytes <= 0 {
+		return 0, nil
+	}
+	return co.DB.TrimLLMCache(co.opts.LLMCacheMaxBytes)
+}
+
+// llmCacheKey hashes everything the answer depends on: the backend and model, the core method,
+// the prompt version, and the request itself, which holds the rendered inputs.
+func llmCacheKey(backend, model, method string, prompt repository.PromptRef, req llm.Request) string {
+	h := sha256.New()
+	writeHashField(h, backend)
+	writeHashField(h, model)
+	writeHashField(h, method)
+	writeHashField(h, prompt.ID)
+	binary.Write(h, binary.BigEndian, int64(prompt.Version))
+
+	for _, part := range req.Parts {
+		writeHashField(h, part.Text)
+		writeHashField(h, part.FileURI)
+		writeHashField(h, part.MIMEType)
+		writeHashField(h, string(part.Data))
+	}
+	if req.Temperature != nil {
+		binary.Write(h, binary.BigEndian, *req.Temperature)
+	}
+	binary.Write(h, binary.BigEndian, int64(req.MaxOutputTokens))
+	if req.ResponseSchema != nil {
+		schema, _ := json.Marshal(req.ResponseSchema.JSONSchema())
+		writeHashField(h, string(schema))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeHashField writes the length before the value, so that adjacent fields can't run into each other.
+func writeHashField(h hash.Hash, value string) {
+	binary.Write(h, binary.BigEndian, int64(len(value)))
+	h.Write([]byte(value))
+}
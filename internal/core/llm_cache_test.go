@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/llm"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+func TestLLMCacheKey(t *testing.T) {
+	ref := repository.PromptRef{ID: string(PROMPT_RESUME_TAILORING), Version: 1}
+	req := llm.Request{Parts: []llm.Part{llm.Text("tailor this")}}
+	key := llmCacheKey("vertex", "gemini-2.5-flash", "TailorResumeWithJobDescriptionLLM", ref, req)
+
+	if key != llmCacheKey("vertex", "gemini-2.5-flash", "TailorResumeWithJobDescriptionLLM", ref, req) {
+		t.Error("expected the same key for the same request")
+	}
+
+	changed := map[string]string{
+		"model":   llmCacheKey("vertex", "gemini-2.5-pro", "TailorResumeWithJobDescriptionLLM", ref, req),
+		"method":  llmCacheKey("vertex", "gemini-2.5-flash", "DraftColdEmailMessageLLM", ref, req),
+		"version": llmCacheKey("vertex", "gemini-2.5-flash", "TailorResumeWithJobDescriptionLLM", repository.PromptRef{ID: ref.ID, Version: 2}, req),
+		"inputs":  llmCacheKey("vertex", "gemini-2.5-flash", "TailorResumeWithJobDescriptionLLM", ref, llm.Request{Parts: []llm.Part{llm.Text("tailor that")}}),
+		"parts":   llmCacheKey("vertex", "gemini-2.5-flash", "TailorResumeWithJobDescriptionLLM", ref, llm.Request{Parts: []llm.Part{llm.Text("tailor"), llm.Text(" this")}}),
+	}
+	for what, other := range changed {
+		if other == key {
+			t.Errorf("expected another key when the %s changes", what)
+		}
+	}
+}
+
+func TestCachedGenerateWithoutCache(t *testing.T) {
+	fake := llm.NewFake(llm.FakeReply{Text: "first"}, llm.FakeReply{Text: "second"})
+	co := newTestCore(fake)
+
+	for _, want := range []string{"first", "second"} {
+		res, cached, err := co.cachedGenerate(context.Background(), "", "test", repository.PromptRef{}, llm.Request{Parts: []llm.Part{llm.Text("prompt")}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cached || res.Text != want {
+			t.Errorf("expected a fresh %q, got %q (cached %v)", want, res.Text, cached)
+		}
+	}
+}
+
+func TestWithoutLLMCache(t *testing.T) {
+	if llmCacheBypassed(context.Background()) {
+		t.Error("expected the cache to be used by default")
+	}
+	if !llmCacheBypassed(WithoutLLMCache(context.Background())) {
+		t.Error("expected the cache to be bypassed")
+	}
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/llm"
+)
+
+var testDraftInput = ColdEmailDraftInput{
+	From:           "jane@example.com",
+	To:             "hr@acme.com",
+	CompanyName:    "Acme",
+	JobDescription: "jd",
+	ProfileSummary: "summary",
+}
+
+func TestStreamColdEmailMessageStreamsTheBodyThenDraftsTheSubject(t *testing.T) {
+	fake := llm.NewFake(llm.FakeReply{Text: "Hello dear recruiter"}, llm.FakeReply{Text: "Backend Engineer"})
+	co := newTestCore(fake)
+
+	var chunks []string
+	draft, err := co.StreamColdEmailMessageLLM(context.Background(), testDraftInput,
+		func(chunk string) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(chunks, "") != "Hello dear recruiter" || len(chunks) != 3 {
+		t.Errorf("unexpected body chunks %q", chunks)
+	}
+	if draft.Body != "Hello dear recruiter" || draft.Subject != "Interested for Backend Engineer - Acme" {
+		t.Errorf("unexpected draft %+v", draft)
+	}
+	if draft.Prompt.ID != string(PROMPT_COLD_EMAIL_DRAFT) || draft.SubjectPrompt.ID != string(PROMPT_EMAIL_SUBJECT) {
+		t.Errorf("unexpected prompt versions %+v, %+v", draft.Prompt, draft.SubjectPrompt)
+	}
+}
+
+func TestStreamColdEmailMessageKeepsThePartialBodyWhenStopped(t *testing.T) {
+	fake := llm.NewFake(llm.FakeReply{Text: "Hello dear recruiter"})
+	co := newTestCore(fake)
+	gone := errors.New("client gone")
+
+	draft, err := co.StreamColdEmailMessageLLM(context.Background(), testDraftInput,
+		func(chunk string) error {
+			if strings.HasPrefix(chunk, "dear") {
+				return gone
+			}
+			return nil
+		})
+	if !errors.Is(err, gone) {
+		t.Fatalf("expected the stream to stop, got %v", err)
+	}
+	if draft == nil || draft.Body != "Hello dear " || draft.Subject != "" {
+		t.Errorf("expected the partial body only, got %+v", draft)
+	}
+	if len(fake.Calls()) != 1 {
+		t.Errorf("expected no subject to be drafted, got %d calls", len(fake.Calls()))
+	}
+}
+
+func TestDraftColdEmailMessageUsesThePromptOfTheDraftType(t *testing.T) {
+	fake := llm.NewFake(llm.FakeReply{Text: "Checking in"}, llm.FakeReply{Text: "Backend Engineer"})
+	co := newTestCore(fake)
+
+	in := testDraftInput
+	in.Type = DRAFT_TYPE_FOLLOW_UP
+	in.PreviousEmail = "Subject: Interested for Backend Engineer - Acme"
+	draft, err := co.DraftColdEmailMessageLLM(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if draft.Prompt.ID != string(PROMPT_FOLLOW_UP_DRAFT) {
+		t.Errorf("expected the follow-up prompt, got %+v", draft.Prompt)
+	}
+	if prompt := fake.Calls()[0].Parts[0].Text; !strings.Contains(prompt, in.PreviousEmail) {
+		t.Errorf("expected the previous email in the prompt, got %q", prompt)
+	}
+	if draft.Subject != "Following up on Backend Engineer - Acme" {
+		t.Errorf("unexpected subject %q", draft.Subject)
+	}
+}
+
+func TestExtractResumeContentNeedsABackendReadingPDFs(t *testing.T) {
+	fake := llm.NewFake(llm.FakeReply{Text: "# Jane Doe"})
+
+	_, err := newTestCore(fake).ExtractResumeContentLLM(context.Background(), "jane@example.com", "/tmp/resume.pdf")
+	if !errors.Is(err, llm.ErrUnsupportedPart) {
+		t.Errorf("expected ErrUnsupportedPart, got %v", err)
+	}
+	if calls := fake.Calls(); len(calls) != 0 {
+		t.Errorf("expected the model not to be called, got %d calls", len(calls))
+	}
+}
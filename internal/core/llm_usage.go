@@ -0,0 +1,149 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/llm"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+// ErrLLMQuotaExceeded is matched by every LLMQuotaExceededError.
+var ErrLLMQuotaExceeded = errors.New("llm token quota exceeded")
+
+// LLMQuotaExceededError is returned before calling the model when the user has used up a token quota.
+type LLMQuotaExceededError struct {
+	// Period is `daily` or `monthly`.
+	Period  string
+	Limit   int64
+	Used    int64
+	ResetAt time.Time
+}
+
+func (e *LLMQuotaExceededError) Error() string {
+	return fmt.Sprintf("%s: %d of the %d tokens of the %s quota used, it resets at %s",
+		ErrLLMQuotaExceeded, e.Used, e.Limit, e.Period, e.ResetAt.Format(time.RFC3339))
+}
+
+func (e *LLMQuotaExceededError) Is(target error) bool {
+	return target == ErrLLMQuotaExceeded
+}
+
+// LLMQuotaUsage is the usage of a token quota over its current period, a zero limit is unlimited.
+type LLMQuotaUsage struct {
+	Used    int64     `json:"used"`
+	Limit   int64     `json:"limit"`
+	ResetAt time.Time `json:"resetAt"`
+}
+
+// LLMUsageReport is the token usage of a user over the current day and month.
+type LLMUsageReport struct {
+	Daily   LLMQuotaUsage `json:"daily"`
+	Monthly LLMQuotaUsage `json:"monthly"`
+	// ByFeature breaks the monthly usage down by feature.
+	ByFeature []*repository.LLMUsageTotals `json:"byFeature"`
+	Recent    []*repository.LLMUsageRecord `json:"recent"`
+}
+
+// llmQuotaPeriods returns the start of the current UTC day and month, and when they end.
+func llmQuotaPeriods(now time.Time) (dayStart, dayEnd, monthStart, monthEnd time.Time) {
+	now = now.UTC()
+	dayStart = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return dayStart, dayStart.AddDate(0, 0, 1), monthStart, monthStart.AddDate(0, 1, 0)
+}
+
+// CheckLLMQuota fails with an LLMQuotaExceededError when the user has used up their daily or monthly tokens.
+func (co *Core) CheckLLMQuota(userEmail string) error {
+	if userEmail == "" || co.DB == nil || (co.opts.LLMDailyTokenQuota <= 0 && co.opts.LLMMonthlyTokenQuota <= 0) {
+		return nil
+	}
+
+	dayStart, dayEnd, monthStart, monthEnd := llmQuotaPeriods(time.Now())
+	daily, monthly, err := co.DB.SumLLMTokens(userEmail, dayStart, monthStart)
+	if err != nil {
+		// An accounting outage doesn't take the AI features down.
+		co.Lo.Warn("unable to check the llm quota", "userEmail", userEmail, "error", err)
+		return nil
+	}
+
+	if limit := co.opts.LLMMonthlyTokenQuota; limit > 0 && monthly >= limit {
+		return &LLMQuotaExceededError{Period: "monthly", Limit: limit, Used: monthly, ResetAt: monthEnd}
+	}
+	if limit := co.opts.LLMDailyTokenQuota; limit > 0 && daily >= limit {
+		return &LLMQuotaExceededError{Period: "daily", Limit: limit, Used: daily, ResetAt: dayEnd}
+	}
+	return nil
+}
+
+// GetLLMUsage reports the token usage of the user against their quotas.
+func (co *Core) GetLLMUsage(userEmail string) (*LLMUsageReport, error) {
+	dayStart, dayEnd, monthStart, monthEnd := llmQuotaPeriods(time.Now())
+	daily, monthly, err := co.DB.SumLLMTokens(userEmail, dayStart, monthStart)
+	if err != nil {
+		return nil, err
+	}
+	byFeature, err := co.DB.AggregateLLMUsage(userEmail, repository.LLM_USAGE_BY_FEATURE, monthStart, 100)
+	if err != nil {
+		return nil, err
+	}
+	recent, err := co.DB.ListLLMUsage(userEmail, 20)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LLMUsageReport{
+		Daily:     LLMQuotaUsage{Used: daily, Limit: co.opts.LLMDailyTokenQuota, ResetAt: dayEnd},
+		Monthly:   LLMQuotaUsage{Used: monthly, Limit: co.opts.LLMMonthlyTokenQuota, ResetAt: monthEnd},
+		ByFeature: byFeature,
+		Recent:    recent,
+	}, nil
+}
+
+// recordLLMUsage stores the usage of a call of the user, the calls not made for a user aren't accounted.
+func (co *Core) recordLLMUsage(userEmail, method string, res *llm.Response, latency time.Duration, estimated bool) {
+	if userEmail == "" || co.DB == nil {
+		return
+	}
+
+	err := co.DB.InsertLLMUsage(&repository.LLMUsageRecord{
+		UserEmail:        userEmail,
+		Feature:          method,
+		Backend:          co.llm.Name(),
+		Model:            res.Model,
+		PromptTokens:     res.Usage.PromptTokens,
+		CompletionTokens: res.Usage.CompletionTokens,
+		TotalTokens:      res.Usage.TotalTokens,
+		LatencyMs:        latency.Milliseconds(),
+		EstimatedCostUSD: co.estimateLLMCost(res.Usage),
+		EstimatedTokens:  estimated,
+	})
+	if err != nil {
+		co.Lo.Warn("unable to record the llm usage", "userEmail", userEmail, "method", method, "error", err)
+	}
+}
+
+// charsPerToken is the rough number of characters of a token, to estimate the usage the backend didn't report.
+const charsPerToken = 4
+
+// estimatedLLMUsage estimates the usage of a generation which was stopped before the backend reported it,
+// out of the text of the prompt and of the answer streamed so far. The files of the prompt aren't counted.
+func estimatedLLMUsage(req llm.Request, completion string) llm.Usage {
+	promptChars := 0
+	for _, p := range req.Parts {
+		promptChars += len(p.Text)
+	}
+	usage := llm.Usage{
+		PromptTokens:     (promptChars + charsPerToken - 1) / charsPerToken,
+		CompletionTokens: (len(completion) + charsPerToken - 1) / charsPerToken,
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// estimateLLMCost prices the usage with the configured per million tokens prices.
+func (co *Core) estimateLLMCost(usage llm.Usage) float64 {
+	return (float64(usage.PromptTokens)*co.opts.LLMInputCostPerMillion +
+		float64(usage.CompletionTokens)*co.opts.LLMOutputCostPerMillion) / 1e6
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/llm"
+)
+
+func TestLLMQuotaPeriods(t *testing.T) {
+	now := time.Date(2025, time.December, 31, 23, 30, 0, 0, time.FixedZone("IST", 5*3600+1800))
+
+	dayStart, dayEnd, monthStart, monthEnd := llmQuotaPeriods(now)
+	if want := time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC); !dayStart.Equal(want) {
+		t.Errorf("expected the day to start at %s, got %s", want, dayStart)
+	}
+	if want := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC); !dayEnd.Equal(want) || !monthEnd.Equal(want) {
+		t.Errorf("expected the day and month to end at %s, got %s and %s", want, dayEnd, monthEnd)
+	}
+	if want := time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC); !monthStart.Equal(want) {
+		t.Errorf("expected the month to start at %s, got %s", want, monthStart)
+	}
+}
+
+func TestEstimateLLMCost(t *testing.T) {
+	co := &Core{opts: &CoreOpts{LLMInputCostPerMillion: 0.30, LLMOutputCostPerMillion: 2.50}}
+
+	cost := co.estimateLLMCost(llm.Usage{PromptTokens: 1_000_000, CompletionTokens: 200_000})
+	if fmt.Sprintf("%.4f", cost) != "0.8000" {
+		t.Errorf("expected a $0.80 cost, got %f", cost)
+	}
+}
+
+func TestEstimatedLLMUsageOfAStoppedStream(t *testing.T) {
+	req := llm.Request{Parts: []llm.Part{llm.InlineFile([]byte("%PDF"), "application/pdf"), llm.Text("Draft an email to the recruiter")}}
+
+	usage := estimatedLLMUsage(req, "Hello dear ")
+	if usage.PromptTokens != 8 || usage.CompletionTokens != 3 || usage.TotalTokens != 11 {
+		t.Errorf("unexpected estimated usage %+v", usage)
+	}
+}
+
+func TestLLMQuotaExceededError(t *testing.T) {
+	err := fmt.Errorf("unable to draft: %w", &LLMQuotaExceededError{Period: "daily", Limit: 10, Used: 12, ResetAt: time.Now()})
+	if !errors.Is(err, ErrLLMQuotaExceeded) {
+		t.Error("expected the error to match ErrLLMQuotaExceeded")
+	}
+
+	// Without a user or quotas, nothing is checked.
+	if err := newTestCore(llm.NewEchoFake()).CheckLLMQuota("jane@example.com"); err != nil {
+		t.Errorf("expected no quota check, got %v", err)
+	}
+}
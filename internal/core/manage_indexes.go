@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -54,3 +55,61 @@ func (co *Core) createIndexHelper(collectionName, fieldName string, isUnique boo
 
 	co.Lo.Info("successfully created index", "collectionName", collectionName, "fieldName", fieldName)
 }
+
+// createTTLIndexHelper creates a TTL index, so documents are purged by MongoDB once `fieldName` is in the past.
+func (co *Core) createTTLIndexHelper(collectionName, fieldName string) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := co.DB.Database("referrer").Collection(collectionName)
+
+	keys, _ := bson.Marshal(bson.D{{Name: fieldName, Value: 1}})
+	indexModel := mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetExpireAfterSeconds(0).SetName(fmt.Sprintf("%s_ttl_index", fieldName)),
+	}
+
+	_, err := collection.Indexes().CreateOne(ctx, indexModel)
+	if err != nil {
+		co.Lo.Error("failed to create ttl index on", "collectionName", collectionName, "fieldName", fieldName, slog.Any("index_err", err.Error()))
+		panic(err)
+	}
+
+	co.Lo.Info("successfully created ttl index", "collectionName", collectionName, "fieldName", fieldName)
+}
+
+// createCompoundIndexHelper creates a descending compound index over the given fields, in order.
+func (co *Core) createCompoundIndexHelper(collectionName string, fieldNames ...string) {
+	co.createCompoundIndex(collectionName, false, fieldNames...)
+}
+
+// createUniqueCompoundIndexHelper creates a unique descending compound index over the given fields, in order.
+func (co *Core) createUniqueCompoundIndexHelper(collectionName string, fieldNames ...string) {
+	co.createCompoundIndex(collectionName, true, fieldNames...)
+}
+
+func (co *Core) createCompoundIndex(collectionName string, isUnique bool, fieldNames ...string) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := co.DB.Database("referrer").Collection(collectionName)
+
+	var keys bson.D
+	for _, fieldName := range fieldNames {
+		keys = append(keys, bson.DocElem{Name: fieldName, Value: -1})
+	}
+	foo, _ := bson.Marshal(keys)
+	indexName := fmt.Sprintf("%s_index", strings.Join(fieldNames, "_"))
+	indexModel := mongo.IndexModel{
+		Keys:    foo,
+		Options: options.Index().SetUnique(isUnique).SetName(indexName),
+	}
+
+	_, err := collection.Indexes().CreateOne(ctx, indexModel)
+	if err != nil {
+		co.Lo.Error("failed to create compound index on", "collectionName", collectionName, "indexName", indexName, slog.Any("index_err", err.Error()))
+		panic(err)
+	}
+
+	co.Lo.Info("successfully created compound index", "collectionName", collectionName, "indexName", indexName)
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NotificationKind names a notification template, see templates/notifications.
+type NotificationKind string
+
+const (
+	RESUME_PROCESSING_COMPLETED NotificationKind = "RESUME_PROCESSING_COMPLETED"
+	RESUME_PROCESSING_FAILED    NotificationKind = "RESUME_PROCESSING_FAILED"
+	WEEKLY_DIGEST               NotificationKind = "WEEKLY_DIGEST"
+	FOLLOW_UPS_DUE              NotificationKind = "FOLLOW_UPS_DUE"
+)
+
+//go:embed templates/notifications/*.html
+var notificationTemplatesFS embed.FS
+
+// Notification is a notification to be sent to a user by email.
+type Notification struct {
+	UserEmail string
+	Kind      NotificationKind
+	// JobID is the job the notification is about, a job is notified once per kind.
+	JobID primitive.ObjectID
+	// Data fills in the template, `FirstName`, `ProfileURL` and `MailboxURL` are set when missing.
+	Data map[string]any
+}
+
+// MailboxURL is the link to the user's sent emails on the web app.
+func (co *Core) MailboxURL() string {
+	return strings.TrimRight(co.opts.AppBaseUrl, "/") + "/dashboard/mailbox"
+}
+
+// ProfileURL is the link to the user's profile page on the web app.
+func (co *Core) ProfileURL() string {
+	return strings.TrimRight(co.opts.AppBaseUrl, "/") + "/dashboard/profile/resume"
+}
+
+// notificationStore is where the notifications find their recipient and are recorded, the MongoDB client of the core.
+type notificationStore interface {
+	HasNotificationBeenSent(jobID primitive.ObjectID, kind string) (bool, error)
+	GetProfileByEmail(email string) (*repository.User, error)
+	CreateNotificationLog(entry *repository.NotificationLog) error
+}
+
+// NotifyUser emails the notification to the user, when their notification preferences allow it.
+// Every notification is recorded in the notifications log, sent, skipped or failed.
+func (co *Core) NotifyUser(n Notification) error {
+	return co.notifyUser(co.DB, co.sendNotificationMail, n)
+}
+
+func (co *Core) notifyUser(store notificationStore, send func(to, subject, body string) error, n Notification) error {
+	entry := &repository.NotificationLog{
+		UserEmail: n.UserEmail,
+		Kind:      string(n.Kind),
+		Channel:   "EMAIL",
+		JobID:     n.JobID,
+	}
+
+	if !n.JobID.IsZero() {
+		sent, err := store.HasNotificationBeenSent(n.JobID, string(n.Kind))
+		if err != nil {
+			return err
+		}
+		if sent {
+			return nil
+		}
+	}
+
+	u, err := store.GetProfileByEmail(n.UserEmail)
+	if err != nil {
+		return fmt.Errorf("unable to load the profile of %s: %w", n.UserEmail, err)
+	}
+
+	data := map[string]any{
+		"FirstName":  u.Firstname,
+		"ProfileURL": co.ProfileURL(),
+		"MailboxURL": co.MailboxURL(),
+	}
+	for k, v := range n.Data {
+		data[k] = v
+	}
+
+	subject, body, err := renderNotification(n.Kind, data)
+	if err != nil {
+		return err
+	}
+	entry.Subject = subject
+
+	switch {
+	case !u.Notification.ReceiveEmails:
+		entry.Status = repository.NOTIFICATION_SKIPPED
+		entry.Reason = "email notifications are turned off"
+	default:
+		if err = send(n.UserEmail, subject, body); err != nil {
+			entry.Status = repository.NOTIFICATION_FAILED
+			entry.Reason = err.Error()
+		} else {
+			entry.Status = repository.NOTIFICATION_SENT
+		}
+	}
+
+	if logErr := store.CreateNotificationLog(entry); logErr != nil {
+		co.Lo.Error("error saving the notification log", "userEmail", n.UserEmail, "kind", n.Kind, "error", logErr)
+	}
+	return err
+}
+
+// renderNotification renders the subject and the HTML body of a notification template.
+func renderNotification(kind NotificationKind, data map[string]any) (string, string, error) {
+	tmpl, err := template.New(fmt.Sprintf("%s.html", kind)).
+		Funcs(template.FuncMap{"join": strings.Join}).
+		ParseFS(notificationTemplatesFS, fmt.Sprintf("templates/notifications/%s.html", kind))
+	if err != nil {
+		return "", "", fmt.Errorf("unknown notification template %s: %w", kind, err)
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return "", "", fmt.Errorf("unable to render %s subject: %w", kind, err)
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", data); err != nil {
+		return "", "", fmt.Errorf("unable to render %s body: %w", kind, err)
+	}
+	return strings.TrimSpace(subject.String()), body.String(), nil
+}
+
+// sendNotificationMail sends an HTML email from the service mailbox.
+// Unlike InvokeSendMail, it's not stored into the referral mailbox.
+func (co *Core) sendNotificationMail(to, subject, body string) error {
+	headers := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";"
+	mailBody := fmt.Sprintf("From: %s\nTo: %s\nSubject: %s\n%s\n\n%s\n\n", co.opts.MailAddr, to, subject, headers, body)
+
+	return co.sendMail("notification", co.opts.MailAddr, []string{to}, []byte(mailBody))
+}
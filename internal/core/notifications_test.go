@@ -0,0 +1,151 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeNotificationStore keeps the notifications log in memory.
+type fakeNotificationStore struct {
+	user *repository.User
+	log  []*repository.NotificationLog
+}
+
+func (s *fakeNotificationStore) HasNotificationBeenSent(jobID primitive.ObjectID, kind string) (bool, error) {
+	for _, entry := range s.log {
+		if entry.JobID == jobID && entry.Kind == kind && entry.Status == repository.NOTIFICATION_SENT {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *fakeNotificationStore) GetProfileByEmail(email string) (*repository.User, error) {
+	return s.user, nil
+}
+
+func (s *fakeNotificationStore) CreateNotificationLog(entry *repository.NotificationLog) error {
+	s.log = append(s.log, entry)
+	return nil
+}
+
+// recordingSender records the notification emails instead of sending them.
+type recordingSender struct {
+	bodies []string
+	err    error
+}
+
+func (r *recordingSender) send(to, subject, body string) error {
+	r.bodies = append(r.bodies, body)
+	return r.err
+}
+
+func TestRenderNotificationTemplates(t *testing.T) {
+	base := map[string]any{"FirstName": "Jane", "ProfileURL": "https://app/profile", "MailboxURL": "https://app/mailbox"}
+	with := func(data map[string]any) map[string]any {
+		for k, v := range base {
+			data[k] = v
+		}
+		return data
+	}
+
+	for kind, tc := range map[NotificationKind]struct {
+		data        map[string]any
+		wantSubject string
+		wantBody    []string
+	}{
+		RESUME_PROCESSING_COMPLETED: {
+			data:        with(map[string]any{"Summary": "Backend engineer <b>Go</b>"}),
+			wantSubject: "Your resume has been processed",
+			wantBody:    []string{"Hi Jane", "Backend engineer &lt;b&gt;Go&lt;/b&gt;", "https://app/profile"},
+		},
+		RESUME_PROCESSING_FAILED: {
+			data:        with(map[string]any{"Reason": "An internal error occurred on our side."}),
+			wantSubject: "We couldn't process your resume",
+			wantBody:    []string{"An internal error occurred on our side.", "https://app/profile"},
+		},
+		WEEKLY_DIGEST: {
+			data:        with(map[string]any{"EmailsSent": 3, "DraftsCreated": 2, "TailoredResumes": 1, "ContactsAdded": 4}),
+			wantSubject: "Your week on Referrer",
+			wantBody:    []string{"3 email(s) sent", "4 contact(s) added"},
+		},
+		FOLLOW_UPS_DUE: {
+			data: with(map[string]any{"AfterDays": 7, "Emails": []*repository.ReferralMailbox{
+				{Subject: "Referral at Acme", To: []string{"hr@acme.com", "cto@acme.com"}, CreatedAt: time.Date(2025, time.March, 4, 0, 0, 0, 0, time.UTC)},
+			}}),
+			wantSubject: "Time to follow up on 1 email(s)",
+			wantBody:    []string{"Referral at Acme &mdash; to hr@acme.com, cto@acme.com, sent on Mar 4, 2025"},
+		},
+	} {
+		subject, body, err := renderNotification(kind, tc.data)
+		if err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		if subject != tc.wantSubject {
+			t.Errorf("%s: expected the subject %q, got %q", kind, tc.wantSubject, subject)
+		}
+		for _, want := range tc.wantBody {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: expected the body to contain %q, got %s", kind, want, body)
+			}
+		}
+	}
+
+	if _, _, err := renderNotification("UNKNOWN", base); err == nil {
+		t.Error("expected an unknown notification kind to fail")
+	}
+}
+
+func TestNotifyUserSkipsTheUsersWhoTurnedTheEmailsOff(t *testing.T) {
+	store := &fakeNotificationStore{user: &repository.User{Firstname: "Jane", Notification: repository.Notification{ReceiveEmails: false}}}
+	sender := &recordingSender{}
+
+	err := newTestCore(nil).notifyUser(store, sender.send, Notification{UserEmail: "jane@example.com", Kind: RESUME_PROCESSING_COMPLETED, JobID: primitive.NewObjectID()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.bodies) != 0 {
+		t.Errorf("expected no email, got %d", len(sender.bodies))
+	}
+	if len(store.log) != 1 || store.log[0].Status != repository.NOTIFICATION_SKIPPED {
+		t.Errorf("expected the notification to be logged as skipped, got %+v", store.log)
+	}
+}
+
+func TestNotifyUserSendsTheNotificationOfAJobOnce(t *testing.T) {
+	store := &fakeNotificationStore{user: &repository.User{Firstname: "Jane", Notification: repository.Notification{ReceiveEmails: true}}}
+	sender := &recordingSender{err: errors.New("smtp unavailable")}
+	co := newTestCore(nil)
+	jobID := primitive.NewObjectID()
+
+	// a failed send is tried again on the next notification
+	if err := co.notifyUser(store, sender.send, Notification{UserEmail: "jane@example.com", Kind: RESUME_PROCESSING_COMPLETED, JobID: jobID}); err == nil {
+		t.Fatal("expected the failed send to be returned")
+	}
+	sender.err = nil
+	for range 2 {
+		if err := co.notifyUser(store, sender.send, Notification{UserEmail: "jane@example.com", Kind: RESUME_PROCESSING_COMPLETED, JobID: jobID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// another kind about the same job is a distinct notification
+	if err := co.notifyUser(store, sender.send, Notification{UserEmail: "jane@example.com", Kind: RESUME_PROCESSING_FAILED, JobID: jobID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sender.bodies) != 3 {
+		t.Errorf("expected 3 emails, the failed one, the first success and the other kind, got %d", len(sender.bodies))
+	}
+	var statuses []string
+	for _, entry := range store.log {
+		statuses = append(statuses, entry.Status)
+	}
+	if want := []string{repository.NOTIFICATION_FAILED, repository.NOTIFICATION_SENT, repository.NOTIFICATION_SENT}; strings.Join(statuses, ",") != strings.Join(want, ",") {
+		t.Errorf("expected the log statuses %v, got %v", want, statuses)
+	}
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+// freeMailDomains are personal mailbox providers, they say nothing about the recipient's company.
+var freeMailDomains = map[string]bool{
+	"gmail.com":      true,
+	"googlemail.com": true,
+	"yahoo.com":      true,
+	"outlook.com":    true,
+	"hotmail.com":    true,
+	"live.com":       true,
+	"icloud.com":     true,
+	"proton.me":      true,
+	"protonmail.com": true,
+}
+
+// OutreachVerdict is the policy outcome for a single recipient.
+type OutreachVerdict struct {
+	Recipient string   `json:"recipient"`
+	Blocked   bool     `json:"blocked"`
+	Reasons   []string `json:"reasons,omitempty"`
+}
+
+// outreachHistory is what the outreach policy looks at in the previous emails of the sender.
+type outreachHistory interface {
+	GetLastEmailToRecipient(from, recipient string, since time.Time) (*repository.ReferralMailbox, error)
+	GetRecentRecipientsAtDomain(from, domain string, since time.Time) ([]string, error)
+}
+
+// EvaluateOutreachPolicy checks every recipient against the sender's outreach policy.
+// Violations either warn or block depending on the policy action, unless `force` is set,
+// in which case the reasons are still reported but nothing is blocked.
+// Recipients are evaluated in order, so the company limit also applies within the same batch.
+func (co *Core) EvaluateOutreachPolicy(from string, recipients []string, force bool) ([]*OutreachVerdict, error) {
+	policy, err := co.DB.GetOutreachPolicy(from)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load outreach policy: %w", err)
+	}
+	return evaluateOutreachPolicy(co.DB, policy, from, recipients, force, time.Now())
+}
+
+func evaluateOutreachPolicy(history outreachHistory, policy *repository.OutreachPolicy, from string, recipients []string, force bool, now time.Time) ([]*OutreachVerdict, error) {
+	verdicts := make([]*OutreachVerdict, 0, len(recipients))
+	if policy.Action == repository.OUTREACH_POLICY_OFF {
+		for _, r := range recipients {
+			verdicts = append(verdicts, &OutreachVerdict{Recipient: r})
+		}
+		return verdicts, nil
+	}
+
+	weekAgo := now.AddDate(0, 0, -7)
+	// Tracks the distinct people per domain, including the ones accepted earlier in this batch.
+	contactedAtDomain := map[string]map[string]bool{}
+
+	for _, recipient := range recipients {
+		verdict := &OutreachVerdict{Recipient: recipient}
+		verdicts = append(verdicts, verdict)
+
+		if policy.RecipientCooldownDays > 0 {
+			last, err := history.GetLastEmailToRecipient(from, recipient, now.AddDate(0, 0, -policy.RecipientCooldownDays))
+			if err != nil {
+				return nil, fmt.Errorf("unable to check previous emails to %s: %w", recipient, err)
+			}
+			if last != nil {
+				verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("%s was already emailed on %s, within the %d days cooldown", recipient, last.CreatedAt.Format("2006-01-02"), policy.RecipientCooldownDays))
+			}
+		}
+
+		domain := recipientDomain(recipient)
+		if policy.CompanyWeeklyLimit > 0 && len(domain) > 0 && !freeMailDomains[domain] {
+			if _, ok := contactedAtDomain[domain]; !ok {
+				contacted, err := history.GetRecentRecipientsAtDomain(from, domain, weekAgo)
+				if err != nil {
+					return nil, fmt.Errorf("unable to check previous emails to %s: %w", domain, err)
+				}
+				contactedAtDomain[domain] = map[string]bool{}
+				for _, c := range contacted {
+					contactedAtDomain[domain][c] = true
+				}
+			}
+
+			addr := strings.ToLower(recipient)
+			if !contactedAtDomain[domain][addr] {
+				if len(contactedAtDomain[domain]) >= policy.CompanyWeeklyLimit {
+					verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("%d people at %s were already contacted this week, the limit is %d", len(contactedAtDomain[domain]), domain, policy.CompanyWeeklyLimit))
+				}
+			}
+		}
+
+		verdict.Blocked = len(verdict.Reasons) > 0 && policy.Action == repository.OUTREACH_POLICY_BLOCK && !force
+		if !verdict.Blocked && len(domain) > 0 && contactedAtDomain[domain] != nil {
+			contactedAtDomain[domain][strings.ToLower(recipient)] = true
+		}
+	}
+
+	return verdicts, nil
+}
+
+// recipientDomain returns the lower cased domain part of an email address.
+func recipientDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+// fakeOutreachHistory answers with the recipients already emailed by the sender.
+type fakeOutreachHistory struct {
+	emailed []string
+}
+
+func (h *fakeOutreachHistory) GetLastEmailToRecipient(from, recipient string, since time.Time) (*repository.ReferralMailbox, error) {
+	for _, addr := range h.emailed {
+		if strings.EqualFold(addr, recipient) {
+			return &repository.ReferralMailbox{From: from, To: []string{addr}, CreatedAt: since.AddDate(0, 0, 1)}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (h *fakeOutreachHistory) GetRecentRecipientsAtDomain(from, domain string, since time.Time) ([]string, error) {
+	var recipients []string
+	for _, addr := range h.emailed {
+		if recipientDomain(addr) == domain {
+			recipients = append(recipients, strings.ToLower(addr))
+		}
+	}
+	return recipients, nil
+}
+
+func TestEvaluateOutreachPolicy(t *testing.T) {
+	policy := func(action string) *repository.OutreachPolicy {
+		return &repository.OutreachPolicy{Action: action, RecipientCooldownDays: 14, CompanyWeeklyLimit: 2}
+	}
+
+	for name, tc := range map[string]struct {
+		policy     *repository.OutreachPolicy
+		emailed    []string
+		recipients []string
+		force      bool
+		// wantReasons and wantBlocked are given per recipient
+		wantReasons []int
+		wantBlocked []bool
+	}{
+		"off": {
+			policy:      policy(repository.OUTREACH_POLICY_OFF),
+			emailed:     []string{"hr@acme.com", "cto@acme.com"},
+			recipients:  []string{"hr@acme.com", "ceo@acme.com"},
+			wantReasons: []int{0, 0},
+			wantBlocked: []bool{false, false},
+		},
+		"cooldown warns": {
+			policy:      policy(repository.OUTREACH_POLICY_WARN),
+			emailed:     []string{"HR@acme.com"},
+			recipients:  []string{"hr@acme.com", "cto@acme.com"},
+			wantReasons: []int{1, 0},
+			wantBlocked: []bool{false, false},
+		},
+		"cooldown blocks": {
+			policy:      policy(repository.OUTREACH_POLICY_BLOCK),
+			emailed:     []string{"hr@acme.com"},
+			recipients:  []string{"hr@acme.com"},
+			wantReasons: []int{1},
+			wantBlocked: []bool{true},
+		},
+		"forced send is not blocked": {
+			policy:      policy(repository.OUTREACH_POLICY_BLOCK),
+			emailed:     []string{"hr@acme.com"},
+			recipients:  []string{"hr@acme.com"},
+			force:       true,
+			wantReasons: []int{1},
+			wantBlocked: []bool{false},
+		},
+		"weekly limit counts the previous emails": {
+			policy:      policy(repository.OUTREACH_POLICY_BLOCK),
+			emailed:     []string{"a@acme.com", "b@acme.com"},
+			recipients:  []string{"c@acme.com", "d@other.com"},
+			wantReasons: []int{1, 0},
+			wantBlocked: []bool{true, false},
+		},
+		"weekly limit applies within the batch": {
+			policy:      &repository.OutreachPolicy{Action: repository.OUTREACH_POLICY_BLOCK, CompanyWeeklyLimit: 2},
+			recipients:  []string{"a@acme.com", "b@acme.com", "c@acme.com"},
+			wantReasons: []int{0, 0, 1},
+			wantBlocked: []bool{false, false, true},
+		},
+		"blocked recipients don't count towards the limit": {
+			policy:      &repository.OutreachPolicy{Action: repository.OUTREACH_POLICY_BLOCK, CompanyWeeklyLimit: 1},
+			emailed:     []string{"a@acme.com"},
+			recipients:  []string{"b@acme.com", "a@acme.com"},
+			wantReasons: []int{1, 0},
+			wantBlocked: []bool{true, false},
+		},
+		"free mail domains have no limit": {
+			policy:      &repository.OutreachPolicy{Action: repository.OUTREACH_POLICY_BLOCK, CompanyWeeklyLimit: 1},
+			emailed:     []string{"a@gmail.com"},
+			recipients:  []string{"b@gmail.com"},
+			wantReasons: []int{0},
+			wantBlocked: []bool{false},
+		},
+	} {
+		verdicts, err := evaluateOutreachPolicy(&fakeOutreachHistory{emailed: tc.emailed}, tc.policy, "me@example.com", tc.recipients, tc.force, time.Now())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(verdicts) != len(tc.recipients) {
+			t.Fatalf("%s: expected %d verdicts, got %d", name, len(tc.recipients), len(verdicts))
+		}
+		for i, v := range verdicts {
+			if v.Recipient != tc.recipients[i] {
+				t.Errorf("%s: expected verdict %d for %s, got %s", name, i, tc.recipients[i], v.Recipient)
+			}
+			if len(v.Reasons) != tc.wantReasons[i] || v.Blocked != tc.wantBlocked[i] {
+				t.Errorf("%s: %s: expected %d reasons and blocked=%v, got %v and blocked=%v", name, v.Recipient, tc.wantReasons[i], tc.wantBlocked[i], v.Reasons, v.Blocked)
+			}
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package core
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"text/template"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+// PromptID names a prompt of the registry, see templates/prompts.
+type PromptID string
+
+const (
+	PROMPT_RESUME_EXTRACTION PromptID = "RESUME_EXTRACTION"
+	PROMPT_PROFILE_SUMMARY   PromptID = "PROFILE_SUMMARY"
+	PROMPT_COLD_EMAIL_DRAFT  PromptID = "COLD_EMAIL_DRAFT"
+	PROMPT_EMAIL_SUBJECT     PromptID = "EMAIL_SUBJECT"
+	PROMPT_RESUME_TAILORING  PromptID = "RESUME_TAILORING"
+
+	PROMPT_REFERRAL_REQUEST_DRAFT   PromptID = "REFERRAL_REQUEST_DRAFT"
+	PROMPT_FOLLOW_UP_DRAFT          PromptID = "FOLLOW_UP_DRAFT"
+	PROMPT_THANK_YOU_DRAFT          PromptID = "THANK_YOU_DRAFT"
+	PROMPT_INFORMATIONAL_CHAT_DRAFT PromptID = "INFORMATIONAL_CHAT_DRAFT"
+)
+
+// BUILTIN_PROMPT_VERSION is the version number of the embedded templates, the stored versions come after it.
+const BUILTIN_PROMPT_VERSION = 1
+
+var (
+	// ErrUnknownPrompt is returned for a prompt id missing from the registry.
+	ErrUnknownPrompt = errors.New("unknown prompt")
+	// ErrInvalidPromptTemplate is returned when a template doesn't parse or uses an undeclared variable.
+	ErrInvalidPromptTemplate = errors.New("invalid prompt template")
+)
+
+//go:embed templates/prompts/*.tmpl
+var promptTemplatesFS embed.FS
+
+// PromptVariable is a variable a prompt template can use, as `{{.Name}}`.
+type PromptVariable struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// sample fills in the variable when a template is validated.
+	sample any
+}
+
+// PromptDefinition describes a prompt of the registry and the variables of its templates.
+type PromptDefinition struct {
+	ID          PromptID         `json:"id"`
+	Description string           `json:"description"`
+	Variables   []PromptVariable `json:"variables"`
+}
+
+var promptDefinitions = []PromptDefinition{
+	{
+		ID:          PROMPT_RESUME_EXTRACTION,
+		Description: "Extracts the content of the uploaded resume PDF, attached to the prompt, as markdown.",
+	},
+	{
+		ID:          PROMPT_PROFILE_SUMMARY,
+		Description: "Summarizes the extracted resume into the profile summary.",
+		Variables: []PromptVariable{
+			{Name: "ResumeContent", Description: "The extracted resume, in markdown.", sample: "resume"},
+		},
+	},
+	{
+		ID:          PROMPT_COLD_EMAIL_DRAFT,
+		Description: "Drafts the body of a cold email to a recruiter.",
+		// TemplateType is the draft type, kept for the versions forked before the draft types had their own prompts.
+		Variables: append(draftPromptVariables(),
+			PromptVariable{Name: "TemplateType", Description: "The kind of email asked for.", sample: "recruiter-outreach"},
+		),
+	},
+	{
+		ID:          PROMPT_REFERRAL_REQUEST_DRAFT,
+		Description: "Drafts the body of an email asking an employee of the company for a referral.",
+		Variables:   draftPromptVariables(),
+	},
+	{
+		ID:          PROMPT_FOLLOW_UP_DRAFT,
+		Description: "Drafts the body of a follow-up on an email left unanswered.",
+		Variables: append(draftPromptVariables(),
+			PromptVariable{Name: "PreviousEmail", Description: "The last email sent to the recipient, empty when none was found.", sample: "previous email"},
+		),
+	},
+	{
+		ID:          PROMPT_THANK_YOU_DRAFT,
+		Description: "Drafts the body of a thank-you email after an interview.",
+		Variables:   draftPromptVariables(),
+	},
+	{
+		ID:          PROMPT_INFORMATIONAL_CHAT_DRAFT,
+		Description: "Drafts the body of an email asking for a short informational chat.",
+		Variables:   draftPromptVariables(),
+	},
+	{
+		ID:          PROMPT_EMAIL_SUBJECT,
+		Description: "Writes the subject line of a drafted email.",
+		Variables: []PromptVariable{
+			{Name: "MailBody", Description: "The drafted email body.", sample: "body"},
+			{Name: "CompanyName", Description: "The company applied to.", sample: "Acme"},
+		},
+	},
+	{
+		ID:          PROMPT_RESUME_TAILORING,
+		Description: "Tailors the resume to a job description, as a one page ATS-friendly markdown resume.",
+		Variables: []PromptVariable{
+			{Name: "JobDescription", Description: "The job description.", sample: "description"},
+			{Name: "CompanyName", Description: "The company applied to.", sample: "Acme"},
+			{Name: "JobRole", Description: "The role applied to.", sample: "Software Engineer"},
+			{Name: "ResumeContent", Description: "The extracted resume, in markdown.", sample: "resume"},
+		},
+	},
+}
+
+// draftPromptVariables are the variables shared by the prompts of the draft types.
+func draftPromptVariables() []PromptVariable {
+	return []PromptVariable{
+		{Name: "To", Description: "The recipient email address.", sample: "recruiter@example.com"},
+		{Name: "CompanyName", Description: "The company applied to.", sample: "Acme"},
+		{Name: "JobRole", Description: "The role applied to, may be empty.", sample: "Software Engineer"},
+		{Name: "JobUrls", Description: "The job posting URLs, a list.", sample: []string{"https://example.com/job"}},
+		{Name: "JobDescription", Description: "The job description.", sample: "description"},
+		{Name: "ProfileSummary", Description: "The candidate profile summary.", sample: "summary"},
+		{Name: "Tone", Description: "The tone instruction of the variant, empty when no tone was asked for.", sample: "Keep it short."},
+	}
+}
+
+// PromptDefinitions lists the prompts of the registry.
+func PromptDefinitions() []PromptDefinition {
+	return promptDefinitions
+}
+
+// LookupPromptDefinition returns the definition of the prompt.
+func LookupPromptDefinition(id PromptID) (*PromptDefinition, error) {
+	for i := range promptDefinitions {
+		if promptDefinitions[i].ID == id {
+			return &promptDefinitions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownPrompt, id)
+}
+
+// builtinPromptVersion is the embedded template of the prompt.
+func builtinPromptVersion(def *PromptDefinition) (*repository.PromptVersion, error) {
+	tmpl, err := promptTemplatesFS.ReadFile(fmt.Sprintf("templates/prompts/%s.tmpl", def.ID))
+	if err != nil {
+		return nil, fmt.Errorf("missing built-in template of %s: %w", def.ID, err)
+	}
+	return &repository.PromptVersion{
+		PromptID:    string(def.ID),
+		Version:     BUILTIN_PROMPT_VERSION,
+		Description: "Built-in",
+		Template:    string(tmpl),
+		Builtin:     true,
+	}, nil
+}
+
+// GetPromptVersion returns a version of the prompt, built-in or stored.
+func (co *Core) GetPromptVersion(id PromptID, version int) (*repository.PromptVersion, error) {
+	def, err := LookupPromptDefinition(id)
+	if err != nil {
+		return nil, err
+	}
+	if version == BUILTIN_PROMPT_VERSION {
+		return builtinPromptVersion(def)
+	}
+	return co.DB.GetPromptVersion(string(id), version)
+}
+
+// ListPromptVersions lists the built-in version and the stored ones visible to the owner, newest first.
+// With `allOwners`, the versions of every user are listed.
+func (co *Core) ListPromptVersions(id PromptID, ownerEmail string, allOwners bool) ([]*repository.PromptVersion, error) {
+	def, err := LookupPromptDefinition(id)
+	if err != nil {
+		return nil, err
+	}
+	builtin, err := builtinPromptVersion(def)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := co.DB.ListPromptVersions(string(id), ownerEmail, allOwners)
+	if err != nil {
+		return nil, err
+	}
+	return append(versions, builtin), nil
+}
+
+// ActivePromptVersion resolves the version of the prompt used for the user:
+// their own activation, else the global activation, else the built-in template.
+func (co *Core) ActivePromptVersion(userEmail string, id PromptID) (*repository.PromptVersion, error) {
+	def, err := LookupPromptDefinition(id)
+	if err != nil {
+		return nil, err
+	}
+	if co.DB == nil {
+		return builtinPromptVersion(def)
+	}
+
+	owner, global, err := co.DB.GetPromptActivations(string(id), userEmail)
+	if err != nil {
+		return nil, err
+	}
+	for _, activation := range []*repository.PromptActivation{owner, global} {
+		if activation == nil {
+			continue
+		}
+		if activation.Version == BUILTIN_PROMPT_VERSION {
+			return builtinPromptVersion(def)
+		}
+		v, err := co.DB.GetPromptVersion(string(id), activation.Version)
+		if err == nil {
+			return v, nil
+		}
+		co.Lo.Warn("active prompt version not loadable, falling back", "promptId", id, "version", activation.Version, "owner", activation.OwnerEmail, "error", err)
+	}
+	return builtinPromptVersion(def)
+}
+
+// RenderPrompt renders the version of the prompt active for the user, and tells which one it was.
+func (co *Core) RenderPrompt(userEmail string, id PromptID, vars map[string]any) (string, repository.PromptRef, error) {
+	v, err := co.ActivePromptVersion(userEmail, id)
+	if err != nil {
+		return "", repository.PromptRef{}, err
+	}
+	ref := repository.PromptRef{ID: string(id), Version: v.Version}
+
+	tmpl, err := parsePromptTemplate(id, v.Template)
+	if err != nil {
+		return "", ref, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return "", ref, fmt.Errorf("unable to render the prompt %s v%d: %w", id, v.Version, err)
+	}
+	return out.String(), ref, nil
+}
+
+// ForkPromptVersion stores a new version of the prompt for the owner, globally when the owner is empty.
+// The template of `fromVersion` is copied when no template is given.
+func (co *Core) ForkPromptVersion(id PromptID, fromVersion int, ownerEmail, template, description, createdBy string) (*repository.PromptVersion, error) {
+	def, err := LookupPromptDefinition(id)
+	if err != nil {
+		return nil, err
+	}
+	source, err := co.GetPromptVersion(id, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	if !promptVersionVisible(source, ownerEmail) {
+		return nil, repository.ErrPromptVersionNotFound
+	}
+
+	if template == "" {
+		template = source.Template
+	}
+	if err := validatePromptTemplate(def, template); err != nil {
+		return nil, err
+	}
+
+	v := &repository.PromptVersion{
+		PromptID:    string(id),
+		OwnerEmail:  ownerEmail,
+		Description: description,
+		Template:    template,
+		ForkedFrom:  source.Version,
+		CreatedBy:   createdBy,
+	}
+	if err := co.DB.CreatePromptVersion(v, BUILTIN_PROMPT_VERSION+1); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// ActivatePromptVersion makes the version the one used for the owner, globally when the owner is empty.
+// A user can activate the built-in, the global and their own versions, a global activation only the first two.
+func (co *Core) ActivatePromptVersion(id PromptID, version int, ownerEmail, activatedBy string) (*repository.PromptVersion, error) {
+	v, err := co.GetPromptVersion(id, version)
+	if err != nil {
+		return nil, err
+	}
+	if !promptVersionVisible(v, ownerEmail) {
+		return nil, repository.ErrPromptVersionNotFound
+	}
+
+	err = co.DB.SetPromptActivation(&repository.PromptActivation{
+		PromptID:    string(id),
+		OwnerEmail:  ownerEmail,
+		Version:     version,
+		ActivatedBy: activatedBy,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// promptVersionVisible tells whether the version is usable by the owner.
+func promptVersionVisible(v *repository.PromptVersion, ownerEmail string) bool {
+	return v.Builtin || v.OwnerEmail == "" || v.OwnerEmail == ownerEmail
+}
+
+func parsePromptTemplate(id PromptID, text string) (*template.Template, error) {
+	tmpl, err := template.New(string(id)).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPromptTemplate, err)
+	}
+	return tmpl, nil
+}
+
+// validatePromptTemplate parses the template and renders it with sample variables,
+// catching the syntax errors and the undeclared variables before the template is stored.
+func validatePromptTemplate(def *PromptDefinition, text string) error {
+	tmpl, err := parsePromptTemplate(def.ID, text)
+	if err != nil {
+		return err
+	}
+
+	samples := make(map[string]any, len(def.Variables))
+	for _, variable := range def.Variables {
+		samples[variable.Name] = variable.sample
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, samples); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidPromptTemplate, err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBuiltinPromptTemplatesAreValid(t *testing.T) {
+	for _, def := range PromptDefinitions() {
+		v, err := builtinPromptVersion(&def)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validatePromptTemplate(&def, v.Template); err != nil {
+			t.Errorf("built-in template of %s: %v", def.ID, err)
+		}
+	}
+}
+
+func TestValidatePromptTemplateRejectsUndeclaredVariables(t *testing.T) {
+	def, err := LookupPromptDefinition(PROMPT_EMAIL_SUBJECT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := validatePromptTemplate(def, "Subject for {{.CompanyName}}: {{.MailBody}}"); err != nil {
+		t.Errorf("expected a valid template, got %v", err)
+	}
+	for _, text := range []string{"{{.JobRole}}", "{{.CompanyName"} {
+		if err := validatePromptTemplate(def, text); !errors.Is(err, ErrInvalidPromptTemplate) {
+			t.Errorf("template %q: expected ErrInvalidPromptTemplate, got %v", text, err)
+		}
+	}
+}
+
+func TestLookupPromptDefinitionUnknownPrompt(t *testing.T) {
+	if _, err := LookupPromptDefinition("NOPE"); !errors.Is(err, ErrUnknownPrompt) {
+		t.Errorf("expected ErrUnknownPrompt, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/metrics"
+	"github.com/yuin/goldmark"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GeneratePDFFromHTML calls the PDF service (NodeJS Puppeteer) to render the HTML into a PDF document.
+func (co *Core) GeneratePDFFromHTML(html string) (pdfData []byte, err error) {
+	defer func(start time.Time) {
+		metrics.Since(metrics.PDFRenderDuration.WithLabelValues(metrics.Outcome(err)), start)
+	}(time.Now())
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := http.NewRequest(http.MethodPost, co.PdfServiceUri+"/generate-pdf", strings.NewReader(html))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "text/plain")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("[Failed]: PDF Service returned status: %s", resp.Status)
+	}
+	pdfData, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return pdfData, nil
+}
+
+// PrepareResumeAttachment stores the resume to attach to a referral email on the local disk and returns its path.
+// The tailored resume is rendered into a PDF when `tailoredResumeID` is given, otherwise the user's uploaded resume is used.
+// The caller is responsible for removing the file once the email is sent.
+func (co *Core) PrepareResumeAttachment(ctx context.Context, from, tailoredResumeID string) (string, error) {
+	if tailoredResumeID == "" {
+		u, err := co.DB.GetProfileByEmail(from)
+		if err != nil {
+			return "", err
+		}
+		return co.DownloadObjectFromGCSBucket(u.Resume)
+	}
+
+	objID, err := primitive.ObjectIDFromHex(tailoredResumeID)
+	if err != nil {
+		return "", fmt.Errorf("invalid tailoredResumeId: %w", err)
+	}
+	tr, err := co.DB.GetTailoredResumeByID(ctx, objID)
+	if err != nil {
+		return "", err
+	}
+
+	var htmlBuf bytes.Buffer
+	if err := goldmark.Convert([]byte(tr.ResumeMarkdown), &htmlBuf); err != nil {
+		return "", fmt.Errorf("failed to convert markdown to HTML: %w", err)
+	}
+	pdfResp, err := co.GeneratePDFFromHTML(htmlBuf.String())
+	if err != nil {
+		return "", err
+	}
+
+	tmpFile, err := os.CreateTemp("/tmp", "Sounish_Naths_Resume_*.pdf")
+	if err != nil {
+		return "", err
+	}
+	defer tmpFile.Close()
+	if _, err := tmpFile.Write(pdfResp); err != nil {
+		return "", err
+	}
+	return tmpFile.Name(), nil
+}
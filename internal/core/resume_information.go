@@ -0,0 +1,207 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/llm"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+// resumeJSONRepairAttempts is how many times an invalid answer is sent back to the model with its validation errors.
+const resumeJSONRepairAttempts = 2
+
+// resumeInformationSchema is the JSON the model answers with, derived from the ResumeInformation struct.
+var resumeInformationSchema = llm.SchemaOf("resume_information", repository.ResumeInformation{})
+
+// ErrInvalidResumeJSON is matched by every ResumeValidationError.
+var ErrInvalidResumeJSON = errors.New("invalid resume information JSON")
+
+// ResumeValidationError lists the problems of a model answer, they're sent back to the model to repair it.
+type ResumeValidationError struct {
+	Problems []string
+}
+
+func (e *ResumeValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidResumeJSON, strings.Join(e.Problems, "; "))
+}
+
+func (e *ResumeValidationError) Is(target error) bool {
+	return target == ErrInvalidResumeJSON
+}
+
+// ResumeConversionError is returned when the model answer is still invalid after the repair round-trips.
+type ResumeConversionError struct {
+	Attempts int
+	// Raw is the last answer of the model.
+	Raw string
+	Err *ResumeValidationError
+}
+
+func (e *ResumeConversionError) Error() string {
+	return fmt.Sprintf("resume conversion failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *ResumeConversionError) Unwrap() error {
+	return e.Err
+}
+
+// ConvertResumeToJSONStructLLM converts the extracted resume content of the user into a ResumeInformation.
+// The backends supporting it are constrained to the schema. The answer is strictly decoded and validated,
+// an invalid answer goes back to the model with its validation errors for a repair.
+func (co *Core) ConvertResumeToJSONStructLLM(ctx context.Context, userEmail, content string) (*repository.ResumeInformation, error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	structured := co.llm.Capabilities().StructuredOutput
+	req := llm.Request{
+		Parts: []llm.Part{llm.Text(content), llm.Text(resumeJSONPrompt(structured))},
+	}
+	if structured {
+		req.ResponseSchema = resumeInformationSchema
+	}
+
+	res, err := co.generate(ctx, userEmail, "ConvertResumeToJSONStructLLM", req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate contents: %w", err)
+	}
+
+	info, validationErr := decodeResumeInformation(res.Text)
+	raw := res.Text
+	attempts := 1
+	for validationErr != nil && attempts <= resumeJSONRepairAttempts {
+		co.Lo.Warn("model answered with an invalid resume JSON, asking for a repair", "attempt", attempts, "error", validationErr)
+
+		req.Parts = []llm.Part{llm.Text(resumeJSONRepairPrompt(content, raw, validationErr, structured))}
+		res, err = co.generate(ctx, userEmail, "ConvertResumeToJSONStructLLM", req)
+		if err != nil {
+			return nil, fmt.Errorf("unable to repair the resume JSON: %w", err)
+		}
+		attempts++
+		raw = res.Text
+		info, validationErr = decodeResumeInformation(raw)
+	}
+	if validationErr != nil {
+		return nil, &ResumeConversionError{Attempts: attempts, Raw: raw, Err: validationErr}
+	}
+	return info, nil
+}
+
+// ReparseResumeInformation is the structured resume to store after the resume was processed again. The stored one
+// corrected by the user is kept, only its summary and extracted content are refreshed, unless `discardUserEdits`:
+// the resume is then parsed from scratch.
+func (co *Core) ReparseResumeInformation(ctx context.Context, stored *repository.ResumeInformation, userEmail, content, summary string, discardUserEdits bool) (*repository.ResumeInformation, error) {
+	info := stored
+	if stored == nil || !stored.EditedByUser || discardUserEdits {
+		var err error
+		if info, err = co.ConvertResumeToJSONStructLLM(ctx, userEmail, content); err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			info.ID = stored.ID
+		}
+	}
+	info.Email = userEmail
+	info.ProfileSummary = summary
+	info.ExtractedContent = content
+	return info, nil
+}
+
+// resumeJSONPrompt asks for the resume JSON, the schema is spelled out for the backends not enforcing it.
+func resumeJSONPrompt(structured bool) string {
+	prompt := `
+		[Role]: You are a Senior Data Entry Specialist.
+
+		[Task]: Convert the provided resume content into a structured JSON format.
+
+		[Instructions]:
+		1.  Adhere strictly to the JSON schema, every field is required. Use an empty string or an empty list when the resume doesn't say.
+		2.  Do not invent any information that is not in the resume.
+		3.  Do not include any explanatory text or markdown formatting in your response.
+	`
+	if !structured {
+		prompt += "\n\t\t[JSON Schema]:\n\t\t" + resumeJSONSchemaText() + "\n"
+	}
+	return prompt
+}
+
+// resumeJSONRepairPrompt sends an invalid answer back to the model with its validation errors.
+func resumeJSONRepairPrompt(content, raw string, validationErr *ResumeValidationError, structured bool) string {
+	var b strings.Builder
+	b.WriteString("[Task]: Your previous answer is not a valid resume JSON. Fix it and answer with the corrected JSON only.\n\n")
+	b.WriteString("[Validation Errors]:\n")
+	for _, problem := range validationErr.Problems {
+		b.WriteString("- " + problem + "\n")
+	}
+	b.WriteString("\n[Previous Answer]:\n" + raw + "\n\n")
+	b.WriteString("[Resume Content]:\n" + content + "\n")
+	if !structured {
+		b.WriteString("\n[JSON Schema]:\n" + resumeJSONSchemaText() + "\n")
+	}
+	return b.String()
+}
+
+func resumeJSONSchemaText() string {
+	schema, _ := json.Marshal(resumeInformationSchema.JSONSchema())
+	return string(schema)
+}
+
+// decodeResumeInformation strictly decodes the model answer, tolerating a markdown code fence around it,
+// and checks it holds a usable resume.
+func decodeResumeInformation(raw string) (*repository.ResumeInformation, *ResumeValidationError) {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimPrefix(raw, "```json")
+	raw = strings.TrimPrefix(raw, "```")
+	raw = strings.TrimSuffix(raw, "```")
+	raw = strings.Trim(strings.TrimSpace(raw), "'")
+
+	dec := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	dec.DisallowUnknownFields()
+
+	var info repository.ResumeInformation
+	if err := dec.Decode(&info); err != nil {
+		return nil, &ResumeValidationError{Problems: []string{err.Error()}}
+	}
+	if dec.More() {
+		return nil, &ResumeValidationError{Problems: []string{"trailing content after the JSON object"}}
+	}
+
+	if problems := validateResumeInformation(&info); len(problems) > 0 {
+		return nil, &ResumeValidationError{Problems: problems}
+	}
+	return &info, nil
+}
+
+// validateResumeInformation lists what makes the resume unusable.
+func validateResumeInformation(info *repository.ResumeInformation) []string {
+	var problems []string
+	if info.FullName == "" && len(info.WorkExperiences) == 0 && len(info.Educations) == 0 {
+		problems = append(problems, "no fullName, workExperiences or educations found")
+	}
+	for i, exp := range info.WorkExperiences {
+		if strings.TrimSpace(exp.OrganizationName) == "" {
+			problems = append(problems, fmt.Sprintf("workExperiences[%d].organizationName is empty", i))
+		}
+	}
+	for i, edu := range info.Educations {
+		if strings.TrimSpace(edu.InstituteName) == "" {
+			problems = append(problems, fmt.Sprintf("educations[%d].institutionName is empty", i))
+		}
+	}
+	for i, link := range info.SocialLinks {
+		if strings.TrimSpace(link.Value) == "" {
+			problems = append(problems, fmt.Sprintf("socialLinks[%d].value is empty", i))
+		}
+	}
+	for i, project := range info.PersonalProjects {
+		if strings.TrimSpace(project.Name) == "" {
+			problems = append(problems, fmt.Sprintf("personalProjects[%d].name is empty", i))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/llm"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const validResumeJSON = `{"fullName":"Jane Doe","email":"jane@example.com","skills":{"programmingLanguages":["Go"],"toolsAndTechnologies":[],"frameworks":[],"cloudPlatforms":[],"miscellenous":[]},"socialLinks":[],"workExperiences":[{"organizationName":"Acme","location":"Remote","tenure":"2020-2024","experiences":"Built things"}],"personalProjects":[],"educations":[],"achievements":[]}`
+
+func newTestCore(provider llm.Provider) *Core {
+	return &Core{Lo: slog.Default(), llm: provider, opts: &CoreOpts{}}
+}
+
+func TestConvertResumeToJSONStructRepairsAnInvalidAnswer(t *testing.T) {
+	fake := llm.NewFake(
+		llm.FakeReply{Text: `{"fullName":"Jane Doe","nickname":"JD"}`},
+		llm.FakeReply{Text: "```json\n" + validResumeJSON + "\n```"},
+	)
+	fake.Caps = llm.Capabilities{StructuredOutput: true}
+
+	info, err := newTestCore(fake).ConvertResumeToJSONStructLLM(context.Background(), "", "resume content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.FullName != "Jane Doe" || len(info.WorkExperiences) != 1 {
+		t.Errorf("unexpected resume information %+v", info)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected a repair round-trip, got %d calls", len(calls))
+	}
+	if calls[0].ResponseSchema == nil || calls[1].ResponseSchema == nil {
+		t.Error("expected the response schema on every call")
+	}
+	if repair := calls[1].Parts[0].Text; !strings.Contains(repair, `unknown field "nickname"`) {
+		t.Errorf("expected the validation errors in the repair prompt, got %q", repair)
+	}
+}
+
+func TestConvertResumeToJSONStructFailsWithATypedError(t *testing.T) {
+	fake := llm.NewFake(
+		llm.FakeReply{Text: "not json"},
+		llm.FakeReply{Text: `{"fullName":""}`},
+		llm.FakeReply{Text: `{"workExperiences":[{"organizationName":""}]}`},
+	)
+
+	_, err := newTestCore(fake).ConvertResumeToJSONStructLLM(context.Background(), "", "resume content")
+
+	var convErr *ResumeConversionError
+	if !errors.As(err, &convErr) {
+		t.Fatalf("expected a ResumeConversionError, got %v", err)
+	}
+	if convErr.Attempts != 1+resumeJSONRepairAttempts || !errors.Is(err, ErrInvalidResumeJSON) {
+		t.Errorf("unexpected conversion error %+v", convErr)
+	}
+	if !strings.Contains(convErr.Err.Error(), "workExperiences[0].organizationName is empty") {
+		t.Errorf("expected the last validation problems, got %v", convErr.Err)
+	}
+	if fake.Calls()[0].ResponseSchema != nil {
+		t.Error("expected no response schema without structured output support")
+	}
+	if !strings.Contains(fake.Calls()[0].Parts[1].Text, `"additionalProperties":false`) {
+		t.Error("expected the JSON schema spelled out in the prompt")
+	}
+}
+
+func TestReparseResumeInformationKeepsTheUserEdits(t *testing.T) {
+	fake := llm.NewFake(llm.FakeReply{Text: validResumeJSON})
+	co := newTestCore(fake)
+	stored := &repository.ResumeInformation{
+		ID:              primitive.NewObjectID(),
+		FullName:        "Jane A. Doe",
+		WorkExperiences: []repository.WorkExperience{{OrganizationName: "Acme Corp"}},
+		EditedByUser:    true,
+	}
+
+	info, err := co.ReparseResumeInformation(context.Background(), stored, "jane@example.com", "new content", "new summary", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Calls()) != 0 {
+		t.Error("expected no parsing of the resume corrected by the user")
+	}
+	if info.FullName != "Jane A. Doe" || info.WorkExperiences[0].OrganizationName != "Acme Corp" || !info.EditedByUser {
+		t.Errorf("expected the user edits kept, got %+v", info)
+	}
+	if info.ExtractedContent != "new content" || info.ProfileSummary != "new summary" {
+		t.Errorf("expected the content and summary refreshed, got %+v", info)
+	}
+
+	info, err = co.ReparseResumeInformation(context.Background(), stored, "jane@example.com", "new content", "new summary", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.FullName != "Jane Doe" || info.EditedByUser || info.ID != stored.ID {
+		t.Errorf("expected the resume parsed again into the stored document, got %+v", info)
+	}
+}
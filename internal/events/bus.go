@@ -0,0 +1,117 @@
+package events
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// EventType describes what happened to a job.
+type EventType string
+
+const (
+	// BULK_EMAIL_PROGRESS is emitted once per recipient of a bulk send.
+	BULK_EMAIL_PROGRESS EventType = "BULK_EMAIL_PROGRESS"
+	// BULK_EMAIL_COMPLETED is emitted when a bulk send has gone through all recipients.
+	BULK_EMAIL_COMPLETED EventType = "BULK_EMAIL_COMPLETED"
+	// JOB_STAGE_CHANGED is emitted when a queued job moves to its next stage.
+	JOB_STAGE_CHANGED EventType = "JOB_STAGE_CHANGED"
+	// JOB_COMPLETED is emitted when a queued job reaches its terminal stage.
+	JOB_COMPLETED EventType = "JOB_COMPLETED"
+	// JOB_RETRY_SCHEDULED is emitted when a failed stage is queued to run again after a backoff.
+	JOB_RETRY_SCHEDULED EventType = "JOB_RETRY_SCHEDULED"
+	// JOB_FAILED is emitted when a job ran out of attempts.
+	JOB_FAILED EventType = "JOB_FAILED"
+)
+
+// Event is a single progress notification for one of the user's jobs.
+type Event struct {
+	Type      EventType `json:"type"`
+	UserEmail string    `json:"userEmail"`
+	JobID     string    `json:"jobId"`
+	JobKind   string    `json:"jobKind"`
+
+	Stage     string `json:"stage,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Recipient string `json:"recipient,omitempty"`
+	Sent      int    `json:"sent,omitempty"`
+	Total     int    `json:"total,omitempty"`
+	Error     string `json:"error,omitempty"`
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Filter decides whether a subscriber is interested in an event.
+type Filter func(Event) bool
+
+// ForUser returns a filter matching only the events of the given user.
+func ForUser(userEmail string) Filter {
+	return func(ev Event) bool {
+		return ev.UserEmail == userEmail
+	}
+}
+
+type subscriber struct {
+	ch     chan Event
+	filter Filter
+}
+
+// Bus is an in-process publish/subscribe hub for job events.
+// Publishing never blocks: a subscriber whose buffer is full misses the event.
+type Bus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[int]*subscriber
+	lo     *slog.Logger
+}
+
+// NewBus creates an empty event bus.
+func NewBus() *Bus {
+	return &Bus{
+		subs: make(map[int]*subscriber),
+		lo:   slog.Default(),
+	}
+}
+
+// Publish fans the event out to every matching subscriber.
+func (b *Bus) Publish(ev Event) {
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, s := range b.subs {
+		if s.filter != nil && !s.filter(ev) {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			b.lo.Warn("[EVENTBUS]: dropping event for slow subscriber", "type", ev.Type, "jobId", ev.JobID)
+		}
+	}
+}
+
+// Subscribe registers a new subscriber and returns its event channel along with
+// an unsubscribe function which must be called once the subscriber is done.
+func (b *Bus) Subscribe(filter Filter, buffer int) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	s := &subscriber{ch: make(chan Event, buffer), filter: filter}
+	b.subs[id] = s
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs, id)
+			close(s.ch)
+		})
+	}
+}
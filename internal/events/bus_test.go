@@ -0,0 +1,60 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestPublishFansOutToTheMatchingSubscribers(t *testing.T) {
+	bus := NewBus()
+	all, unsubscribeAll := bus.Subscribe(nil, 4)
+	defer unsubscribeAll()
+	jane, unsubscribeJane := bus.Subscribe(ForUser("jane@example.com"), 4)
+	defer unsubscribeJane()
+
+	bus.Publish(Event{Type: JOB_STAGE_CHANGED, UserEmail: "jane@example.com", JobID: "1"})
+	bus.Publish(Event{Type: JOB_COMPLETED, UserEmail: "john@example.com", JobID: "2"})
+
+	if len(all) != 2 {
+		t.Errorf("expected every event on the unfiltered subscriber, got %d", len(all))
+	}
+	if len(jane) != 1 {
+		t.Fatalf("expected only the event of jane, got %d", len(jane))
+	}
+	if ev := <-jane; ev.JobID != "1" || ev.Timestamp.IsZero() {
+		t.Errorf("expected the timestamped event of job 1, got %+v", ev)
+	}
+}
+
+func TestPublishDropsTheEventsOfASlowSubscriber(t *testing.T) {
+	bus := NewBus()
+	slow, unsubscribeSlow := bus.Subscribe(nil, 1)
+	defer unsubscribeSlow()
+	fast, unsubscribeFast := bus.Subscribe(nil, 3)
+	defer unsubscribeFast()
+
+	// never blocks on the full buffer of the slow subscriber
+	for _, jobID := range []string{"1", "2", "3"} {
+		bus.Publish(Event{Type: BULK_EMAIL_PROGRESS, JobID: jobID})
+	}
+
+	if len(slow) != 1 || len(fast) != 3 {
+		t.Fatalf("expected 1 event kept by the slow subscriber and 3 by the fast one, got %d and %d", len(slow), len(fast))
+	}
+	if ev := <-slow; ev.JobID != "1" {
+		t.Errorf("expected the slow subscriber to keep the first event, got job %s", ev.JobID)
+	}
+}
+
+func TestUnsubscribeClosesTheChannel(t *testing.T) {
+	bus := NewBus()
+	ch, unsubscribe := bus.Subscribe(nil, 1)
+
+	unsubscribe()
+	// a second call is a no-op, and publishing no longer reaches the subscriber
+	unsubscribe()
+	bus.Publish(Event{Type: JOB_FAILED})
+
+	if _, ok := <-ch; ok {
+		t.Error("expected the channel to be closed without any event")
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AdminOnlyMiddleware restricts the route to the users listed in the `ADMIN_EMAILS`.
+// The admin is only ever identified by the email claim of a verified token, never by the query params.
+func AdminOnlyMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		hctx := c.(*HandlerContext)
+
+		userEmail := getVerifiedUserEmail(c)
+		if len(userEmail) == 0 || !isValidEmail(userEmail) {
+			return SendErrorResponse(c, http.StatusUnauthorized, fmt.Errorf("a valid token is required"))
+		}
+		if !hctx.GetCore().IsAdmin(userEmail) {
+			return SendErrorResponse(c, http.StatusForbidden, fmt.Errorf("admin access required"))
+		}
+		return next(c)
+	}
+}
+
+// ListDeadJobsHandler lists the jobs which ran out of attempts, most recently dead first.
+// Supports cursor pagination (`cursor`, `limit`) and filtering by `kind`.
+func ListDeadJobsHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	limit := 20
+	if l := c.QueryParam("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var cursor primitive.ObjectID
+	if cursorStr := c.QueryParam("cursor"); cursorStr != "" {
+		id, err := primitive.ObjectIDFromHex(cursorStr)
+		if err != nil {
+			return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid cursor"))
+		}
+		cursor = id
+	}
+
+	jobs, err := hctx.GetCore().DB.ListDeadJobs(c.QueryParam("kind"), cursor, limit)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, fmt.Errorf("failed to fetch dead jobs: %w", err))
+	}
+
+	nextCursor := ""
+	if len(jobs) == limit {
+		nextCursor = jobs[len(jobs)-1].ID.Hex()
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"data": jobs,
+		"meta": map[string]any{
+			"limit":      limit,
+			"nextCursor": nextCursor,
+		},
+	})
+}
+
+// GetDeadJobHandler returns a single dead job with its last error.
+func GetDeadJobHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid job id"))
+	}
+
+	job, err := hctx.GetCore().DB.GetDeadJob(id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return SendErrorResponse(c, http.StatusNotFound, fmt.Errorf("dead job not found"))
+	}
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// RequeueDeadJobHandler puts a dead job back into the job queue, it resumes from the stage it died in.
+func RequeueDeadJobHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid job id"))
+	}
+
+	job, err := hctx.GetCore().DB.RequeueDeadJob(id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return SendErrorResponse(c, http.StatusNotFound, fmt.Errorf("dead job not found"))
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return SendErrorResponse(c, http.StatusConflict, fmt.Errorf("job is already queued"))
+	}
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	hctx.GetCore().Lo.Info("dead job requeued", "jobId", job.ID.Hex(), "kind", job.Kind, "stage", job.Stage, "by", getVerifiedUserEmail(c))
+	return c.JSON(http.StatusOK, job)
+}
+
+// GetJobQueueDepthHandler reports how many jobs are waiting or running, per priority and status.
+func GetJobQueueDepthHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	depths, err := hctx.GetCore().DB.GetJobQueueDepth()
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, fmt.Errorf("failed to compute the queue depth: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"data": depths,
+	})
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+)
+
+func TestAdminOnlyMiddlewareRejectsTheEmailQueryParam(t *testing.T) {
+	e := echo.New()
+	reached := false
+	handler := AdminOnlyMiddleware(func(c echo.Context) error {
+		reached = true
+		return c.NoContent(http.StatusOK)
+	})
+
+	for name, token := range map[string]*jwt.Token{
+		"no token":         nil,
+		"unverified token": {Claims: jwt.MapClaims{"email": "admin@example.com"}, Valid: false},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/jobs/dead/1/requeue?email=admin@example.com", nil)
+		rec := httptest.NewRecorder()
+		c := &HandlerContext{Context: e.NewContext(req, rec), Co: &core.Core{}}
+		if token != nil {
+			c.Set("user", token)
+		}
+
+		if err := handler(c); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, rec.Code)
+		}
+	}
+	if reached {
+		t.Error("the admin handler must not run without a verified token")
+	}
+}
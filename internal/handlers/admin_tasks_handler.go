@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+// ListScheduledTasksHandler lists the periodic tasks with their schedule, last run and next run.
+func ListScheduledTasksHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	tasks, err := hctx.GetCore().DB.ListScheduledTasks()
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, fmt.Errorf("failed to fetch the scheduled tasks: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"data": tasks,
+	})
+}
+
+// RunScheduledTaskHandler makes a periodic task due right away, the scheduler of one of the replicas runs it shortly.
+// The regular schedule resumes after that run.
+func RunScheduledTaskHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	task, err := hctx.GetCore().DB.RequestScheduledTaskRun(c.Param("name"), getVerifiedUserEmail(c))
+	if errors.Is(err, repository.ErrScheduledTaskNotFound) {
+		return SendErrorResponse(c, http.StatusNotFound, err)
+	}
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	hctx.GetCore().Lo.Info("scheduled task run requested", "task", task.Name, "by", task.TriggeredBy)
+	return c.JSON(http.StatusAccepted, task)
+}
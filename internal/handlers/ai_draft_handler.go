@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
 	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
 )
 
@@ -12,90 +18,263 @@ type ReferralColdmailRequestDto struct {
 	To   string `json:"to"`
 	From string `json:"from"`
 
-	CompanyName      string   `json:"companyName"`
-	JobUrls          []string `json:"jobUrls"`
-	JobDescription   string   `json:"jobDescription"`
-	TemplateType     string   `json:"templateType"`
-	TailoredResumeID string   `json:"tailoredResumeId"`
+	CompanyName    string   `json:"companyName"`
+	JobUrls        []string `json:"jobUrls"`
+	JobDescription string   `json:"jobDescription"`
+	// DraftType is one of the core.DraftTypes, a recruiter outreach by default.
+	DraftType string `json:"draftType"`
+	JobRole   string `json:"jobRole"`
+	// TemplateType is the template label of the older clients, read when no DraftType is given, see resolveDraftType.
+	TemplateType     string `json:"templateType"`
+	TailoredResumeID string `json:"tailoredResumeId"`
+	// Force skips the cached answers, `force=true` in the query string works as well.
+	Force bool `json:"force"`
+	// Variants is the number of drafts to write, 1 to 5, each in its own tone. The tones not given in Tones are picked in order.
+	Variants int      `json:"variants"`
+	Tones    []string `json:"tones"`
 }
 
 func DraftReferralEmailWithAiHandler(c echo.Context) error {
 	// Get context
 	hctx := c.(*HandlerContext)
 
-	var rmailDto ReferralColdmailRequestDto
+	rmailDto, u, err := bindDraftRequest(c)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, err)
+	}
 
-	if err := c.Bind(&rmailDto); err != nil {
+	tones, err := draftTones(rmailDto)
+	if err != nil {
 		return SendErrorResponse(c, http.StatusBadRequest, err)
 	}
 
+	// Step02: Call LLM apis with private customized prompt, one call per variant.
+	llmCtx := llmRequestContext(c, rmailDto.Force)
+	drafts, err := hctx.GetCore().DraftColdEmailVariantsLLM(llmCtx, draftInput(hctx, rmailDto, u), tones)
+	if err != nil {
+		return SendLLMErrorResponse(c, http.StatusBadRequest, fmt.Errorf("unable to generated draft email %s: %w", rmailDto.From, err))
+	}
+
+	// Step03: Tailor the resume to the job description.
+	tailoredResumeID, tailoredResumeCached := tailorResumeForDraft(llmCtx, hctx, rmailDto, u)
+
+	// Step04: Store the draft emails with the correct tailoredResumeID, the variants as siblings of one group.
+	// If a tailoredResumeID was passed in the request, it will be used.
+	// If a new one was generated, that one will be used.
+	// Otherwise, it will be an empty string, and the default resume will be used upon sending.
+	// A variant which couldn't be stored is left out, its send couldn't be tracked back to it.
+	var groupID primitive.ObjectID
+	if len(tones) > 1 {
+		groupID = primitive.NewObjectID()
+	}
+	variants := make([]map[string]any, 0, len(drafts))
+	stored := make([]*core.ColdEmailDraft, 0, len(drafts))
+	for _, draft := range drafts {
+		record := newAiDraftColdEmail(rmailDto, draft, tailoredResumeID)
+		record.GroupID = groupID
+		if err := hctx.GetCore().DB.CreateAiDraftEmail(record); err != nil {
+			hctx.GetCore().Lo.Error("unable to store the draft email", "from", rmailDto.From, "tone", draft.Tone, "error", err)
+			continue
+		}
+
+		stored = append(stored, draft)
+		variants = append(variants, map[string]any{
+			"id":            record.ID,
+			"tone":          draft.Tone,
+			"variant":       draft.Variant,
+			"mailSubject":   draft.Subject,
+			"mailBody":      draft.Body,
+			"prompt":        draft.Prompt,
+			"subjectPrompt": draft.SubjectPrompt,
+			"cached":        draft.Cached,
+		})
+	}
+
+	if len(stored) == 0 {
+		return SendErrorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to store the draft email %s", rmailDto.From))
+	}
+
+	// The first variant is returned at the top level as well, for the clients reading a single draft.
+	draft := stored[0]
+	var group any
+	if !groupID.IsZero() {
+		group = groupID
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"id":                   variants[0]["id"],
+		"mailSubject":          draft.Subject,
+		"mailBody":             draft.Body,
+		"tailoredResumeId":     tailoredResumeID,
+		"prompt":               draft.Prompt,
+		"subjectPrompt":        draft.SubjectPrompt,
+		"cached":               draft.Cached,
+		"tailoredResumeCached": tailoredResumeCached,
+		"groupId":              group,
+		"variants":             variants,
+	})
+}
+
+// legacyDraftTemplates are the template labels sent by the older clients, some of them name the job role as well.
+var legacyDraftTemplates = map[string]struct {
+	Type    core.DraftType
+	JobRole string
+}{
+	"draft-with-ai":             {Type: core.DRAFT_TYPE_RECRUITER_OUTREACH},
+	"Draft with AI":             {Type: core.DRAFT_TYPE_RECRUITER_OUTREACH},
+	"Software Engineering":      {Type: core.DRAFT_TYPE_RECRUITER_OUTREACH, JobRole: "Software Engineer"},
+	"Data Engineering":          {Type: core.DRAFT_TYPE_RECRUITER_OUTREACH, JobRole: "Data Engineer"},
+	"Business Analyst":          {Type: core.DRAFT_TYPE_RECRUITER_OUTREACH, JobRole: "Business Analyst"},
+	"Ask for Job Opportunities": {Type: core.DRAFT_TYPE_REFERRAL},
+}
+
+// resolveDraftType checks the draft type of the request. Without one, the draft type is read from the
+// template label of the older clients, a recruiter outreach when there's no label either. Any other label is rejected.
+func (dto *ReferralColdmailRequestDto) resolveDraftType() (core.DraftType, error) {
+	if len(dto.DraftType) > 0 {
+		return core.ParseDraftType(dto.DraftType)
+	}
+	if len(dto.TemplateType) == 0 {
+		return core.DRAFT_TYPE_RECRUITER_OUTREACH, nil
+	}
+
+	if draftType, err := core.ParseDraftType(dto.TemplateType); err == nil {
+		return draftType, nil
+	}
+	legacy, ok := legacyDraftTemplates[dto.TemplateType]
+	if !ok {
+		return "", fmt.Errorf("unknown template type %q, expected one of the draft types %v", dto.TemplateType, core.DraftTypes())
+	}
+	if len(dto.JobRole) == 0 {
+		dto.JobRole = legacy.JobRole
+	}
+	return legacy.Type, nil
+}
+
+// bindDraftRequest reads and checks the draft request, and fetches the profile of its sender.
+func bindDraftRequest(c echo.Context) (*ReferralColdmailRequestDto, *repository.User, error) {
+	hctx := c.(*HandlerContext)
+
+	var rmailDto ReferralColdmailRequestDto
+	if err := c.Bind(&rmailDto); err != nil {
+		return nil, nil, err
+	}
+
 	// check for errors of proper email address
 	if !isValidEmail(rmailDto.To) || !isValidEmail(rmailDto.From) {
-		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email address"))
+		return nil, nil, fmt.Errorf("invalid email address")
 	}
+	draftType, err := rmailDto.resolveDraftType()
+	if err != nil {
+		return nil, nil, err
+	}
+	rmailDto.DraftType = string(draftType)
 
-	// Steps to perform the action
 	// Step01: Get the profile information from the `from` email address.
 	u, err := hctx.GetCore().DB.GetProfileByEmail(rmailDto.From)
 	if err != nil || len(u.Firstname) == 0 {
-		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("unable to fetch information for %s: %w", rmailDto.From, err))
-	}
-	// Step02: Call LLM apis with private customized prompt.
-	mailSubject, mailBody, err := hctx.GetCore().DraftColdEmailMessageLLM(
-		rmailDto.From,
-		rmailDto.To,
-		rmailDto.CompanyName,
-		rmailDto.TemplateType,
-		rmailDto.JobDescription,
-		u.ProfileSummary,
-		rmailDto.JobUrls,
-	)
+		return nil, nil, fmt.Errorf("unable to fetch information for %s: %w", rmailDto.From, err)
+	}
+
+	// Without a job description, it's read from the job postings. The email can be drafted without, the failure is only logged.
+	if len(strings.TrimSpace(rmailDto.JobDescription)) == 0 && len(rmailDto.JobUrls) > 0 {
+		jobDescription, err := hctx.GetCore().JobDescriptionFromUrls(c.Request().Context(), rmailDto.JobUrls)
+		if err != nil {
+			hctx.GetCore().Lo.Warn("unable to fetch the job description of the draft", "from", rmailDto.From, "error", err)
+		}
+		rmailDto.JobDescription = jobDescription
+	}
+	return &rmailDto, u, nil
+}
+
+// tailorResumeForDraft returns the tailored resume to attach to the draft: the one of the request, or a new one
+// tailored to the job description. A failed tailoring falls back to the default resume, with an empty ID.
+func tailorResumeForDraft(ctx context.Context, hctx *HandlerContext, rmailDto *ReferralColdmailRequestDto, u *repository.User) (string, bool) {
+	if len(rmailDto.TailoredResumeID) > 0 || len(rmailDto.JobDescription) == 0 {
+		return rmailDto.TailoredResumeID, false
+	}
+
+	tailoring, err := hctx.GetCore().TailorResumeWithJobDescriptionLLM(ctx, rmailDto.From, rmailDto.JobDescription, u.ExtractedContent, rmailDto.CompanyName, rmailDto.JobRole)
+	if err != nil {
+		hctx.GetCore().Lo.Warn("unable to tailor the resume of the draft", "from", rmailDto.From, "error", err)
+		return "", false
+	}
+	tr := &repository.TailoredResume{
+		UserID:         u.ID.Hex(),
+		JobDescription: rmailDto.JobDescription,
+		ResumeMarkdown: tailoring.Markdown,
+		CompanyName:    rmailDto.CompanyName,
+		JobRole:        rmailDto.JobRole,
+		Prompt:         tailoring.Prompt,
+		ATSScore:       atsScore(rmailDto.JobDescription, tailoring.Markdown),
+	}
+	insertedID, err := hctx.GetCore().DB.CreateTailoredResume(ctx, tr)
 	if err != nil {
-		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("unable to generated draft email %s: %w", rmailDto.From, err))
-	}
-
-	tailoredResumeID := rmailDto.TailoredResumeID
-
-	// If a Job Description is provided AND no tailored resume ID was given, then generate a new one.
-	if len(rmailDto.JobDescription) > 0 && len(tailoredResumeID) == 0 {
-		// Generate tailored resume and store it
-		resumeMarkdown, tErr := hctx.GetCore().TailorResumeWithJobDescriptionLLM(rmailDto.JobDescription, u.ExtractedContent, rmailDto.CompanyName, rmailDto.TemplateType)
-		if tErr == nil {
-			tr := &repository.TailoredResume{
-				UserID:         u.ID.Hex(),
-				JobDescription: rmailDto.JobDescription,
-				ResumeMarkdown: resumeMarkdown,
-				CompanyName:    rmailDto.CompanyName,
-				JobRole:        rmailDto.TemplateType,
-			}
-			ctx := c.Request().Context()
-			insertedID, err := hctx.GetCore().DB.CreateTailoredResume(ctx, tr)
-			if err == nil {
-				tailoredResumeID = insertedID.Hex()
-			}
+		return "", false
+	}
+	return insertedID.Hex(), tailoring.Cached
+}
+
+// draftTones returns the tones of the variants asked for. A single draft asked without a tone has none.
+func draftTones(rmailDto *ReferralColdmailRequestDto) ([]core.DraftTone, error) {
+	if rmailDto.Variants <= 1 && len(rmailDto.Tones) == 0 {
+		if rmailDto.Variants < 0 {
+			return nil, fmt.Errorf("variants must be between 1 and %d", core.MAX_DRAFT_VARIANTS)
 		}
+		return []core.DraftTone{""}, nil
 	}
 
-	// Step04: Store the draft email with the correct tailoredResumeID
-	// If a tailoredResumeID was passed in the request, it will be used.
-	// If a new one was generated, that one will be used.
-	// Otherwise, it will be an empty string, and the default resume will be used upon sending.
-	_, _ = hctx.GetCore().DB.CreateAiDraftEmail(
-		rmailDto.From,
-		rmailDto.To,
-		rmailDto.CompanyName,
-		rmailDto.TemplateType,
-		rmailDto.JobDescription,
-		u.ProfileSummary,
-		mailSubject,
-		mailBody,
-		tailoredResumeID,
-		rmailDto.JobUrls,
-	)
-
-	return c.JSON(http.StatusOK, map[string]string{
-		"mailSubject":      mailSubject,
-		"mailBody":         mailBody,
-		"tailoredResumeId": tailoredResumeID,
-	})
+	requested := make([]core.DraftTone, 0, len(rmailDto.Tones))
+	for _, t := range rmailDto.Tones {
+		tone, err := core.ParseDraftTone(t)
+		if err != nil {
+			return nil, err
+		}
+		requested = append(requested, tone)
+	}
+	return core.PickDraftTones(max(rmailDto.Variants, len(requested)), requested)
+}
+
+// draftInput is what the email of the request is drafted from. A follow-up is drafted from the last email sent to the recipient.
+func draftInput(hctx *HandlerContext, rmailDto *ReferralColdmailRequestDto, u *repository.User) core.ColdEmailDraftInput {
+	in := core.ColdEmailDraftInput{
+		From:           rmailDto.From,
+		To:             rmailDto.To,
+		CompanyName:    rmailDto.CompanyName,
+		JobRole:        rmailDto.JobRole,
+		JobDescription: rmailDto.JobDescription,
+		ProfileSummary: u.ProfileSummary,
+		JobUrls:        rmailDto.JobUrls,
+		Type:           core.DraftType(rmailDto.DraftType),
+	}
+
+	if in.Type == core.DRAFT_TYPE_FOLLOW_UP {
+		mails, _, err := hctx.GetCore().DB.GetLatestEmailsByFilter(bson.M{"from": rmailDto.From, "to": rmailDto.To}, 1, 0)
+		if err != nil {
+			hctx.GetCore().Lo.Warn("unable to fetch the email followed up on", "from", rmailDto.From, "error", err)
+		} else if len(mails) > 0 {
+			in.PreviousEmail = fmt.Sprintf("Subject: %s\n\n%s", mails[0].Subject, mails[0].Body)
+		}
+	}
+	return in
+}
+
+// newAiDraftColdEmail is the draft record of the request.
+func newAiDraftColdEmail(rmailDto *ReferralColdmailRequestDto, draft *core.ColdEmailDraft, tailoredResumeID string) *repository.AiDraftColdEmail {
+	return &repository.AiDraftColdEmail{
+		UserEmailAddress: rmailDto.From,
+		From:             rmailDto.From,
+		To:               rmailDto.To,
+		CompanyName:      rmailDto.CompanyName,
+		JobUrls:          rmailDto.JobUrls,
+		JobDescription:   rmailDto.JobDescription,
+		TemplateType:     rmailDto.DraftType,
+		JobRole:          rmailDto.JobRole,
+		MailSubject:      draft.Subject,
+		Mailbody:         draft.Body,
+		TailoredResumeID: tailoredResumeID,
+		Prompt:           draft.Prompt,
+		SubjectPrompt:    draft.SubjectPrompt,
+		Tone:             string(draft.Tone),
+		Variant:          draft.Variant,
+	}
 }
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+)
+
+func TestResolveDraftType(t *testing.T) {
+	for name, tc := range map[string]struct {
+		dto         ReferralColdmailRequestDto
+		wantType    core.DraftType
+		wantJobRole string
+		wantErr     bool
+	}{
+		"default":              {dto: ReferralColdmailRequestDto{}, wantType: core.DRAFT_TYPE_RECRUITER_OUTREACH},
+		"draft type":           {dto: ReferralColdmailRequestDto{DraftType: "follow-up"}, wantType: core.DRAFT_TYPE_FOLLOW_UP},
+		"unknown draft type":   {dto: ReferralColdmailRequestDto{DraftType: "congratulations"}, wantErr: true},
+		"draft type as label":  {dto: ReferralColdmailRequestDto{TemplateType: "thank-you"}, wantType: core.DRAFT_TYPE_THANK_YOU},
+		"legacy label":         {dto: ReferralColdmailRequestDto{TemplateType: "Data Engineering"}, wantType: core.DRAFT_TYPE_RECRUITER_OUTREACH, wantJobRole: "Data Engineer"},
+		"legacy label and job": {dto: ReferralColdmailRequestDto{TemplateType: "Data Engineering", JobRole: "Analytics Engineer"}, wantType: core.DRAFT_TYPE_RECRUITER_OUTREACH, wantJobRole: "Analytics Engineer"},
+		"unknown label":        {dto: ReferralColdmailRequestDto{TemplateType: "Staff Engineer"}, wantErr: true},
+		"congratulations":      {dto: ReferralColdmailRequestDto{TemplateType: "Send Congratulations"}, wantErr: true},
+	} {
+		draftType, err := tc.dto.resolveDraftType()
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got the draft type %q", name, draftType)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if draftType != tc.wantType || tc.dto.JobRole != tc.wantJobRole {
+			t.Errorf("%s: expected %q for the job role %q, got %q for %q", name, tc.wantType, tc.wantJobRole, draftType, tc.dto.JobRole)
+		}
+	}
+}
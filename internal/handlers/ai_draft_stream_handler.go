@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+// Events of a streamed AI draft, in the order they're sent.
+const (
+	DRAFT_EVENT_BODY            = "body"
+	DRAFT_EVENT_SUBJECT         = "subject"
+	DRAFT_EVENT_TAILORED_RESUME = "tailoredResume"
+	DRAFT_EVENT_DONE            = "done"
+	DRAFT_EVENT_ERROR           = "error"
+)
+
+type tailoredResumeOutcome struct {
+	ID     string
+	Cached bool
+}
+
+// StreamDraftReferralEmailWithAiHandler drafts like DraftReferralEmailWithAiHandler, streaming the draft as Server-Sent Events:
+// the `body` deltas as the model writes them, then the `subject`, then the `tailoredResume` ID, and `done` with the stored draft.
+// The resume is tailored while the email is drafted. The draft is stored once, when the stream completes,
+// or when the client goes away mid-way, as a CANCELLED draft holding what was drafted so far.
+func StreamDraftReferralEmailWithAiHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	rmailDto, u, err := bindDraftRequest(c)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, err)
+	}
+	tones, err := draftTones(rmailDto)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, err)
+	}
+	if len(tones) > 1 {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("a single variant is streamed at a time"))
+	}
+	in := draftInput(hctx, rmailDto, u)
+	in.Tone = tones[0]
+	// Once the stream started, the errors can only be sent as events, the quota is checked beforehand to answer a proper 429.
+	if err := hctx.GetCore().CheckLLMQuota(rmailDto.From); err != nil {
+		return SendLLMErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	llmCtx := llmRequestContext(c, rmailDto.Force)
+	tailored := make(chan tailoredResumeOutcome, 1)
+	go func() {
+		id, cached := tailorResumeForDraft(llmCtx, hctx, rmailDto, u)
+		tailored <- tailoredResumeOutcome{ID: id, Cached: cached}
+	}()
+
+	w := startEventStream(c)
+
+	clientGone := false
+	draft, err := hctx.GetCore().StreamColdEmailMessageLLM(llmCtx, in, func(chunk string) error {
+		err := writeEvent(w, DRAFT_EVENT_BODY, map[string]string{"delta": chunk})
+		clientGone = err != nil
+		return err
+	})
+	if err != nil {
+		if clientGone || llmCtx.Err() != nil {
+			persistStreamedDraft(hctx, rmailDto, draft, "", repository.AI_DRAFT_CANCELLED)
+			return nil
+		}
+		writeEvent(w, DRAFT_EVENT_ERROR, map[string]string{"error": fmt.Sprintf("unable to generated draft email %s: %s", rmailDto.From, err)})
+		return nil
+	}
+	if err := writeEvent(w, DRAFT_EVENT_SUBJECT, map[string]string{"subject": draft.Subject}); err != nil {
+		persistStreamedDraft(hctx, rmailDto, draft, "", repository.AI_DRAFT_CANCELLED)
+		return nil
+	}
+
+	var resume tailoredResumeOutcome
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+waitTailoring:
+	for {
+		select {
+		case resume = <-tailored:
+			break waitTailoring
+		case <-llmCtx.Done():
+			persistStreamedDraft(hctx, rmailDto, draft, "", repository.AI_DRAFT_CANCELLED)
+			return nil
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				persistStreamedDraft(hctx, rmailDto, draft, "", repository.AI_DRAFT_CANCELLED)
+				return nil
+			}
+			w.Flush()
+		}
+	}
+
+	// The client may be gone already, the draft is complete and stored as such.
+	writeEvent(w, DRAFT_EVENT_TAILORED_RESUME, map[string]any{"tailoredResumeId": resume.ID, "cached": resume.Cached})
+	record := persistStreamedDraft(hctx, rmailDto, draft, resume.ID, repository.AI_DRAFT_COMPLETED)
+	if record.ID.IsZero() {
+		writeEvent(w, DRAFT_EVENT_ERROR, map[string]string{"error": fmt.Sprintf("unable to store the draft email %s", rmailDto.From)})
+		return nil
+	}
+	writeEvent(w, DRAFT_EVENT_DONE, map[string]any{
+		"id":                   record.ID,
+		"mailSubject":          draft.Subject,
+		"mailBody":             draft.Body,
+		"tailoredResumeId":     resume.ID,
+		"prompt":               draft.Prompt,
+		"subjectPrompt":        draft.SubjectPrompt,
+		"cached":               draft.Cached,
+		"tailoredResumeCached": resume.Cached,
+		"tone":                 draft.Tone,
+	})
+	return nil
+}
+
+// persistStreamedDraft stores the outcome of a streamed draft. A draft cancelled before any of its body was written has nothing to store.
+func persistStreamedDraft(hctx *HandlerContext, rmailDto *ReferralColdmailRequestDto, draft *core.ColdEmailDraft, tailoredResumeID, status string) *repository.AiDraftColdEmail {
+	if draft == nil {
+		draft = &core.ColdEmailDraft{}
+	}
+	record := newAiDraftColdEmail(rmailDto, draft, tailoredResumeID)
+	record.Status = status
+	if status == repository.AI_DRAFT_CANCELLED && record.Mailbody == "" {
+		return record
+	}
+
+	if err := hctx.GetCore().DB.CreateAiDraftEmail(record); err != nil {
+		hctx.GetCore().Lo.Error("unable to store the streamed draft", "from", rmailDto.From, "status", status, "error", err)
+	}
+	return record
+}
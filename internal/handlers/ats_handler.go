@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/ats"
+)
+
+// ATSScoreRequestDto is the job description to score the resumes against: the given one, else the one read
+// from the job URLs, else the one the tailored resume was tailored to.
+type ATSScoreRequestDto struct {
+	JobDescription   string   `json:"jobDescription"`
+	JobUrls          []string `json:"jobUrls"`
+	TailoredResumeID string   `json:"tailoredResumeId"`
+}
+
+// ScoreATSMatchHandler scores the user's base resume, and the tailored resume when one is given, against a job description:
+// the matched, missing and overused keywords, and the overall score. The score of a tailored resume against its
+// own job description is stored on it.
+func ScoreATSMatchHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+	var req ATSScoreRequestDto
+	if err := c.Bind(&req); err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	u, err := hctx.GetCore().DB.GetProfileByEmail(userEmail)
+	if err != nil || u == nil {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("unable to fetch user: %w", err))
+	}
+
+	ctx := c.Request().Context()
+	jobDescription := strings.TrimSpace(req.JobDescription)
+	if len(jobDescription) == 0 && len(req.JobUrls) > 0 {
+		if jobDescription, err = hctx.GetCore().JobDescriptionFromUrls(ctx, req.JobUrls); err != nil {
+			return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("unable to fetch the job description from jobUrls: %w", err))
+		}
+	}
+
+	response := map[string]any{}
+	if len(req.TailoredResumeID) > 0 {
+		id, err := primitive.ObjectIDFromHex(req.TailoredResumeID)
+		if err != nil {
+			return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid tailored resume id"))
+		}
+		tr, err := hctx.GetCore().DB.GetTailoredResumeByID(ctx, id)
+		if err != nil || tr.UserID != u.ID.Hex() {
+			return SendErrorResponse(c, http.StatusNotFound, fmt.Errorf("tailored resume not found"))
+		}
+
+		ownJobDescription := len(jobDescription) == 0 || jobDescription == strings.TrimSpace(tr.JobDescription)
+		if len(jobDescription) == 0 {
+			jobDescription = tr.JobDescription
+		}
+		report := ats.Analyze(jobDescription, tr.ResumeMarkdown)
+		if ownJobDescription {
+			if err := hctx.GetCore().DB.UpdateTailoredResumeATSScore(ctx, id, report.Score); err != nil {
+				hctx.GetCore().Lo.Warn("unable to store the ats score", "tailoredResumeId", req.TailoredResumeID, "error", err)
+			}
+		}
+		response["tailored"] = report
+	}
+
+	if len(jobDescription) == 0 {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("jobDescription, jobUrls or tailoredResumeId is required"))
+	}
+	if len(u.ExtractedContent) > 0 {
+		response["base"] = ats.Analyze(jobDescription, u.ExtractedContent)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// atsScore is the score of the resume against the job description, as stored on the tailored resumes.
+func atsScore(jobDescription, resume string) *int {
+	score := ats.Analyze(jobDescription, resume).Score
+	return &score
+}
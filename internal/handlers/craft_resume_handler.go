@@ -3,10 +3,7 @@ package handlers
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
-	"strings"
-	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
@@ -22,13 +19,23 @@ func TailorResumeWithJobDescriptionHandler(c echo.Context) error {
 		UserEmail      string `json:"userEmail"`
 		CompanyName    string `json:"companyName"`
 		JobRole        string `json:"jobRole"`
+		// JobUrls are read for the job description when none is given.
+		JobUrls []string `json:"jobUrls"`
+		Force   bool     `json:"force"`
 	}
 	var req requestDto
 	if err := c.Bind(&req); err != nil {
 		return SendErrorResponse(c, http.StatusBadRequest, err)
 	}
-	if len(req.JobDescription) == 0 || len(req.UserEmail) == 0 || len(req.CompanyName) == 0 || len(req.JobRole) == 0 {
-		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("jobDescription, userEmail, companyName, and jobRole are required"))
+	if (len(req.JobDescription) == 0 && len(req.JobUrls) == 0) || len(req.UserEmail) == 0 || len(req.CompanyName) == 0 || len(req.JobRole) == 0 {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("jobDescription or jobUrls, userEmail, companyName, and jobRole are required"))
+	}
+	if len(req.JobDescription) == 0 {
+		jobDescription, err := hctx.GetCore().JobDescriptionFromUrls(c.Request().Context(), req.JobUrls)
+		if err != nil {
+			return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("unable to fetch the job description from jobUrls: %w", err))
+		}
+		req.JobDescription = jobDescription
 	}
 
 	u, err := hctx.GetCore().DB.GetProfileByEmail(req.UserEmail)
@@ -39,18 +46,20 @@ func TailorResumeWithJobDescriptionHandler(c echo.Context) error {
 		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("user has no extracted resume content"))
 	}
 
-	resumeMarkdown, err := hctx.GetCore().TailorResumeWithJobDescriptionLLM(req.JobDescription, u.ExtractedContent, req.CompanyName, req.JobRole)
+	tailoring, err := hctx.GetCore().TailorResumeWithJobDescriptionLLM(llmRequestContext(c, req.Force), req.UserEmail, req.JobDescription, u.ExtractedContent, req.CompanyName, req.JobRole)
 	if err != nil {
-		return SendErrorResponse(c, http.StatusInternalServerError, err)
+		return SendLLMErrorResponse(c, http.StatusInternalServerError, err)
 	}
 
 	// Store tailored resume in MongoDB
 	tr := &repository.TailoredResume{
 		UserID:         u.ID.Hex(),
 		JobDescription: req.JobDescription,
-		ResumeMarkdown: resumeMarkdown,
+		ResumeMarkdown: tailoring.Markdown,
 		CompanyName:    req.CompanyName,
 		JobRole:        req.JobRole,
+		Prompt:         tailoring.Prompt,
+		ATSScore:       atsScore(req.JobDescription, tailoring.Markdown),
 	}
 	ctx := context.Background()
 	insertedID, err := hctx.GetCore().DB.CreateTailoredResume(ctx, tr)
@@ -58,7 +67,7 @@ func TailorResumeWithJobDescriptionHandler(c echo.Context) error {
 		return SendErrorResponse(c, http.StatusInternalServerError, fmt.Errorf("failed to store tailored resume: %w", err))
 	}
 
-	return c.JSON(http.StatusOK, map[string]any{"id": insertedID.Hex()})
+	return c.JSON(http.StatusOK, map[string]any{"id": insertedID.Hex(), "cached": tailoring.Cached})
 }
 
 // GetTailoredResumeByIDHandler fetches a tailored resume by its ID
@@ -99,19 +108,23 @@ func UpdateTailoredResumeHandler(c echo.Context) error {
 		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid id"))
 	}
 	ctx := context.Background()
-	err = hctx.GetCore().DB.UpdateTailoredResumeMarkdown(ctx, id, req.ResumeMarkdown)
+	tr, err := hctx.GetCore().DB.GetTailoredResumeByID(ctx, id)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusNotFound, fmt.Errorf("tailored resume not found: %w", err))
+	}
+	// The edited resume is scored again against its job description.
+	score := atsScore(tr.JobDescription, req.ResumeMarkdown)
+	err = hctx.GetCore().DB.UpdateTailoredResumeMarkdown(ctx, id, req.ResumeMarkdown, *score)
 	if err != nil {
 		return SendErrorResponse(c, http.StatusInternalServerError, err)
 	}
-	return c.JSON(http.StatusOK, map[string]any{"success": true})
+	return c.JSON(http.StatusOK, map[string]any{"success": true, "atsScore": *score})
 }
 
 // Generate the PDF using NodeJS Puppeteer service
 func GeneratePDFHandler(c echo.Context) error {
 	// Get context
 	hctx := c.(*HandlerContext)
-	// Http client with 10 second time out
-	client := &http.Client{Timeout: 10 * time.Second}
 	// Take out the resumeContent from JSON request body
 	var reqBody struct {
 		ResumeContent string `json:"resumeContent"`
@@ -120,24 +133,7 @@ func GeneratePDFHandler(c echo.Context) error {
 		return SendErrorResponse(c, http.StatusBadRequest, err)
 	}
 	// NOTE: The PDF service expects HTML in the body as plain text
-	req, err := http.NewRequest(http.MethodPost, hctx.GetCore().PdfServiceUri+"/generate-pdf", strings.NewReader(reqBody.ResumeContent))
-	if err != nil {
-		return err
-	}
-	req.Header.Add("Content-Type", "text/plain")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("[Failed]: PDF Service returned status: %s", resp.Status)
-	}
-
-	// Read the PDF buffer
-	pdfData, err := io.ReadAll(resp.Body)
+	pdfData, err := hctx.GetCore().GeneratePDFFromHTML(reqBody.ResumeContent)
 	if err != nil {
 		return err
 	}
@@ -145,7 +141,8 @@ func GeneratePDFHandler(c echo.Context) error {
 	return c.Blob(http.StatusOK, "application/pdf", pdfData)
 }
 
-// GetLatestTailoredResumesHandler fetches the latest 10 tailored resumes for a user by email
+// GetLatestTailoredResumesHandler fetches the latest 10 tailored resumes for a user by email.
+// `sort=atsScore` lists the best ATS scores first, `sort=createdAt` (default) the latest resumes.
 func GetLatestTailoredResumesHandler(c echo.Context) error {
 	hctx := c.(*HandlerContext)
 	email := c.QueryParam("email")
@@ -153,12 +150,18 @@ func GetLatestTailoredResumesHandler(c echo.Context) error {
 	if email == "" {
 		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("missing email parameter"))
 	}
+	sortBy := c.QueryParam("sort")
+	switch sortBy {
+	case "", repository.TAILORED_RESUMES_BY_RECENT, repository.TAILORED_RESUMES_BY_ATS_SCORE:
+	default:
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("sort must be one of createdAt or atsScore"))
+	}
 	u, err := hctx.GetCore().DB.GetProfileByEmail(email)
 	if err != nil || u == nil {
 		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("unable to fetch user: %w", err))
 	}
 	ctx := context.Background()
-	resumes, err := hctx.GetCore().DB.GetLatestTailoredResumesByUser(ctx, u.ID.Hex(), companyName)
+	resumes, err := hctx.GetCore().DB.GetLatestTailoredResumesByUser(ctx, u.ID.Hex(), companyName, sortBy)
 	if err != nil {
 		return SendErrorResponse(c, http.StatusInternalServerError, err)
 	}
@@ -1,20 +1,20 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
-	"github.com/yuin/goldmark"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"github.com/sounishnath003/customgo-mailer-service/internal/jobs"
 	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
 )
 
@@ -25,8 +25,23 @@ type EmailSenderDto struct {
 	Sub              string   `json:"subject"`
 	Body             string   `json:"body"`
 	TailoredResumeID string   `json:"tailoredResumeId,omitempty"`
+	// Force sends the email even when the outreach policy would block it.
+	Force bool `json:"force,omitempty"`
+	// ScheduledAt delays the send, the email is then sent in background by the job queue.
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty"`
+	// DraftID is the AI draft the email was written from, it's recorded as the chosen variant of its group.
+	DraftID string `json:"draftId,omitempty"`
 }
 
+// EmailSentResponseDto is the email sent, along with the warnings the outreach policy raised on it.
+type EmailSentResponseDto struct {
+	EmailSenderDto
+	PolicyWarnings []string `json:"policyWarnings,omitempty"`
+}
+
+// maxEmailScheduleAhead is how far in the future a send can be scheduled.
+const maxEmailScheduleAhead = 30 * 24 * time.Hour
+
 // SendEmailHandler handlers will handle the email sending capability to the multiple users
 func SendEmailHandler(c echo.Context) error {
 	var emailSenderDto EmailSenderDto
@@ -37,6 +52,25 @@ func SendEmailHandler(c echo.Context) error {
 
 	hctx := c.(*HandlerContext)
 
+	var draftID primitive.ObjectID
+	if len(emailSenderDto.DraftID) > 0 {
+		id, err := primitive.ObjectIDFromHex(emailSenderDto.DraftID)
+		if err != nil {
+			return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid draftId"))
+		}
+		draftID = id
+	}
+
+	scheduled := emailSenderDto.ScheduledAt != nil
+	if scheduled {
+		if !emailSenderDto.ScheduledAt.After(time.Now()) {
+			return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("scheduledAt must be in the future"))
+		}
+		if emailSenderDto.ScheduledAt.After(time.Now().Add(maxEmailScheduleAhead)) {
+			return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("scheduledAt cannot be more than 30 days ahead"))
+		}
+	}
+
 	// Identify real recipients (excluding the sender)
 	var recipients []string
 	sender := emailSenderDto.From
@@ -46,102 +80,87 @@ func SendEmailHandler(c echo.Context) error {
 		}
 	}
 
-	// Helper to prepare resume
-	prepareResume := func(ctx context.Context) (string, error) {
-		if emailSenderDto.TailoredResumeID != "" {
-			objID, objErr := primitive.ObjectIDFromHex(emailSenderDto.TailoredResumeID)
-			if objErr != nil {
-				return "", fmt.Errorf("invalid tailoredResumeId: %w", objErr)
-			}
-			tr, tErr := hctx.GetCore().DB.GetTailoredResumeByID(ctx, objID)
-			if tErr != nil {
-				return "", tErr
-			}
-			var htmlBuf bytes.Buffer
-			if err := goldmark.Convert([]byte(tr.ResumeMarkdown), &htmlBuf); err != nil {
-				return "", fmt.Errorf("failed to convert markdown to HTML: %w", err)
-			}
-			pdfResp, pdfErr := generatePDFfromResume(hctx.GetCore().PdfServiceUri, htmlBuf.String())
-			if pdfErr != nil {
-				return "", pdfErr
-			}
-			tmpFile, tmpErr := os.CreateTemp("/tmp", "Sounish_Naths_Resume_*.pdf")
-			if tmpErr != nil {
-				return "", tmpErr
-			}
-			defer tmpFile.Close()
-			if _, wErr := tmpFile.Write(pdfResp); wErr != nil {
-				return "", wErr
-			}
-			return tmpFile.Name(), nil
-		} else {
-			u, err := hctx.GetCore().DB.GetProfileByEmail(emailSenderDto.From)
-			if err != nil {
-				return "", err
-			}
-			return hctx.GetCore().DownloadObjectFromGCSBucket(u.Resume)
+	if scheduled && len(recipients) == 0 {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("a scheduled send needs at least one recipient"))
+	}
+
+	// Guard against contacting the same person or company too often.
+	verdicts, err := hctx.GetCore().EvaluateOutreachPolicy(sender, recipients, emailSenderDto.Force)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+	blocked := map[string]string{}
+	var policyWarnings []string
+	for _, v := range verdicts {
+		if v.Blocked {
+			blocked[v.Recipient] = strings.Join(v.Reasons, "; ")
+			continue
 		}
+		policyWarnings = append(policyWarnings, v.Reasons...)
+	}
+
+	// A single recipient blocked by the outreach policy is refused outright.
+	if len(recipients) == 1 && len(blocked) > 0 {
+		return SendErrorResponse(c, http.StatusConflict, fmt.Errorf("blocked by outreach policy, resend with force=true to override: %s", blocked[recipients[0]]))
 	}
 
-	// BULK MODE: >1 recipients
-	if len(recipients) > 1 {
+	draft := sentDraft(hctx, sender, draftID)
+
+	// BULK MODE: >1 recipients, or a scheduled send
+	if len(recipients) > 1 || scheduled {
 		// Create Job
+		bodyHash := sha256.Sum256([]byte(emailSenderDto.Body))
 		job := &repository.BulkEmailJob{
 			UserEmail:       sender,
+			Subject:         emailSenderDto.Sub,
+			BodyHash:        hex.EncodeToString(bodyHash[:]),
 			TotalRecipients: len(recipients),
 			SentCount:       0,
+			SkippedCount:    len(blocked),
 			Status:          "PENDING",
+			ScheduledAt:     emailSenderDto.ScheduledAt,
+		}
+		// Recipients blocked by the outreach policy are skipped straight away.
+		for _, recipient := range recipients {
+			r := repository.BulkEmailRecipient{Email: recipient, Status: "PENDING"}
+			if reason, ok := blocked[recipient]; ok {
+				r.Status = "SKIPPED"
+				r.Reason = reason
+			}
+			job.Recipients = append(job.Recipients, r)
 		}
 		if err := hctx.GetCore().DB.CreateBulkEmailJob(job); err != nil {
 			return SendErrorResponse(c, http.StatusInternalServerError, fmt.Errorf("failed to create bulk job: %w", err))
 		}
 
-		go func() {
-			// Background context
-			bgCtx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-			defer cancel()
-
-			localDst, err := prepareResume(bgCtx)
-			if err != nil {
-				hctx.GetCore().Lo.Error("failed to prepare resume for bulk send", "error", err)
-				hctx.GetCore().DB.FailBulkEmailJob(job.ID, fmt.Sprintf("failed to prepare resume: %s", err.Error()))
-				return
-			}
-			defer os.Remove(localDst)
-
-			sentCount := 0
-			for _, recipient := range recipients {
-				// Construct To: [recipient, sender]
-				currentTo := []string{recipient, sender}
-				
-				// Send
-				err := hctx.GetCore().InvokeSendMailWithAttachment(
-					sender, 
-					currentTo, 
-					emailSenderDto.Sub, 
-					emailSenderDto.Body, 
-					emailSenderDto.TailoredResumeID, 
-					localDst,
-				)
-				
-				if err != nil {
-					hctx.GetCore().Lo.Error("failed to send bulk email", "to", recipient, "error", err)
-					// We log error but continue sending to others
-				} else {
-					hctx.GetCore().Lo.Info("bulk email sent", "to", recipient)
-					sentCount++
-					hctx.GetCore().DB.UpdateBulkEmailJobProgress(job.ID, sentCount)
-				}
-				
-				// Small delay to be nice to SMTP
-				time.Sleep(500 * time.Millisecond)
-			}
-			hctx.GetCore().DB.CompleteBulkEmailJob(job.ID)
-		}()
+		// The emails are sent by the worker pool, the queued job shares the bulk job ID.
+		var runAt time.Time
+		if scheduled {
+			runAt = *emailSenderDto.ScheduledAt
+		}
+		err := jobs.EnqueueBulkEmail(hctx.GetCore(), jobs.BulkEmailPayload{
+			BulkJobID:        job.ID,
+			From:             sender,
+			Subject:          emailSenderDto.Sub,
+			Body:             emailSenderDto.Body,
+			TailoredResumeID: emailSenderDto.TailoredResumeID,
+			Draft:            draft,
+		}, runAt)
+		if err != nil {
+			hctx.GetCore().DB.FailBulkEmailJob(job.ID, err.Error())
+			return SendErrorResponse(c, http.StatusInternalServerError, fmt.Errorf("failed to queue bulk job: %w", err))
+		}
+		markDraftChosen(hctx, sender, draftID)
 
+		message := fmt.Sprintf("Processing bulk emails to %d recipients in background.", len(recipients)-len(blocked))
+		if scheduled {
+			message = fmt.Sprintf("Scheduled emails to %d recipients at %s.", len(recipients)-len(blocked), emailSenderDto.ScheduledAt.Format(time.RFC3339))
+		}
 		return c.JSON(http.StatusAccepted, map[string]any{
-			"message": fmt.Sprintf("Processing bulk emails to %d recipients in background.", len(recipients)),
-			"jobId":   job.ID.Hex(),
+			"message":        message,
+			"jobId":          job.ID.Hex(),
+			"skipped":        blocked,
+			"policyWarnings": policyWarnings,
 		})
 	}
 
@@ -149,41 +168,44 @@ func SendEmailHandler(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	localDst, err := prepareResume(ctx)
+	localDst, err := hctx.GetCore().PrepareResumeAttachment(ctx, emailSenderDto.From, emailSenderDto.TailoredResumeID)
 	if err != nil {
 		return SendErrorResponse(c, http.StatusInternalServerError, err)
 	}
 	defer os.Remove(localDst)
 
-	err = hctx.GetCore().InvokeSendMailWithAttachment(emailSenderDto.From, emailSenderDto.To, emailSenderDto.Sub, emailSenderDto.Body, emailSenderDto.TailoredResumeID, localDst)
+	err = hctx.GetCore().InvokeSendMailWithAttachment(emailSenderDto.From, emailSenderDto.To, emailSenderDto.Sub, emailSenderDto.Body, emailSenderDto.TailoredResumeID, localDst, draft)
 	if err != nil {
 		return SendErrorResponse(c, http.StatusInternalServerError, err)
 	}
+	markDraftChosen(hctx, sender, draftID)
 
-	return c.JSON(http.StatusOK, emailSenderDto)
+	return c.JSON(http.StatusOK, EmailSentResponseDto{EmailSenderDto: emailSenderDto, PolicyWarnings: policyWarnings})
 }
 
-// Helper to call the PDF service (simulate the logic from craft_resume_handler.go)
-func generatePDFfromResume(pdfServiceUri, resumeContent string) ([]byte, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest(http.MethodPost, pdfServiceUri+"/generate-pdf", strings.NewReader(resumeContent))
-	if err != nil {
-		return nil, err
+// sentDraft is the reference to the AI draft the email is written from, stored on the sent emails so their replies
+// can be joined to the tone of the draft. The email is sent all the same without it, a missing draft is only logged.
+func sentDraft(hctx *HandlerContext, sender string, draftID primitive.ObjectID) *repository.SentDraft {
+	if draftID.IsZero() {
+		return nil
 	}
-	req.Header.Add("Content-Type", "text/plain")
-	resp, err := client.Do(req)
+	draft, err := hctx.GetCore().DB.GetAiDraftEmail(sender, draftID)
 	if err != nil {
-		return nil, err
+		hctx.GetCore().Lo.Warn("unable to find the draft of the email", "from", sender, "draftId", draftID.Hex(), "error", err)
+		return nil
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("[Failed]: PDF Service returned status: %s", resp.Status)
+	return draft.Sent()
+}
+
+// markDraftChosen records the sent draft as the chosen variant of its group, to learn which tones get answered.
+// The email is out already, a failure is only logged.
+func markDraftChosen(hctx *HandlerContext, sender string, draftID primitive.ObjectID) {
+	if draftID.IsZero() {
+		return
 	}
-	pdfData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if err := hctx.GetCore().DB.MarkAiDraftChosen(sender, draftID); err != nil {
+		hctx.GetCore().Lo.Warn("unable to record the chosen draft", "from", sender, "draftId", draftID.Hex(), "error", err)
 	}
-	return pdfData, nil
 }
 
 func GetReferralEmailsHandler(c echo.Context) error {
@@ -278,3 +300,119 @@ func GetBulkEmailJobStatusHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, job)
 }
 
+// BulkEmailJobSummary is a bulk email job along with its aggregated delivery stats.
+type BulkEmailJobSummary struct {
+	*repository.BulkEmailJob
+	Stats BulkEmailJobStats `json:"stats"`
+}
+
+// BulkEmailJobStats aggregates the outcome of a bulk email job.
+type BulkEmailJobStats struct {
+	Sent            int     `json:"sent"`
+	Failed          int     `json:"failed"`
+	Skipped         int     `json:"skipped"`
+	Pending         int     `json:"pending"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// ListBulkEmailJobsHandler lists the past bulk email campaigns of the user.
+// Supports cursor pagination (`cursor`, `limit`) and filtering by `status`, `subject`, `startDate` and `endDate`.
+func ListBulkEmailJobsHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+
+	filter, cursor, limit, err := bulkEmailJobsQuery(c, userEmail)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	bulkJobs, err := hctx.GetCore().DB.ListBulkEmailJobs(filter, cursor, limit)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, fmt.Errorf("failed to fetch bulk jobs: %w", err))
+	}
+
+	summaries := make([]BulkEmailJobSummary, 0, len(bulkJobs))
+	for _, job := range bulkJobs {
+		summaries = append(summaries, BulkEmailJobSummary{
+			BulkEmailJob: job,
+			Stats: BulkEmailJobStats{
+				Sent:            job.SentCount,
+				Failed:          job.FailedCount,
+				Skipped:         job.SkippedCount,
+				Pending:         max(job.TotalRecipients-job.SentCount-job.FailedCount-job.SkippedCount, 0),
+				DurationSeconds: job.Duration().Seconds(),
+			},
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"data": summaries,
+		"meta": map[string]any{
+			"limit":      limit,
+			"nextCursor": nextBulkEmailJobsCursor(bulkJobs, limit),
+		},
+	})
+}
+
+// bulkEmailJobsQuery reads the filters, the cursor and the page size of the bulk email jobs listing.
+func bulkEmailJobsQuery(c echo.Context, userEmail string) (repository.BulkEmailJobFilter, primitive.ObjectID, int, error) {
+	filter := repository.BulkEmailJobFilter{
+		UserEmail: userEmail,
+		Status:    strings.ToUpper(c.QueryParam("status")),
+		Subject:   c.QueryParam("subject"),
+	}
+	switch filter.Status {
+	case "", "PENDING", "IN_PROGRESS", "COMPLETED", "FAILED":
+	default:
+		return filter, primitive.NilObjectID, 0, fmt.Errorf("invalid status filter")
+	}
+
+	// Date Range params (YYYY-MM-DD)
+	if startDateStr := c.QueryParam("startDate"); startDateStr != "" {
+		t, err := time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			return filter, primitive.NilObjectID, 0, fmt.Errorf("invalid startDate, expected YYYY-MM-DD")
+		}
+		filter.From = t
+	}
+	if endDateStr := c.QueryParam("endDate"); endDateStr != "" {
+		t, err := time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			return filter, primitive.NilObjectID, 0, fmt.Errorf("invalid endDate, expected YYYY-MM-DD")
+		}
+		// Add 23h 59m 59s to end date to include the whole day
+		filter.To = t.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+	}
+
+	limit := 20
+	if l := c.QueryParam("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var cursor primitive.ObjectID
+	if cursorStr := c.QueryParam("cursor"); cursorStr != "" {
+		id, err := primitive.ObjectIDFromHex(cursorStr)
+		if err != nil {
+			return filter, primitive.NilObjectID, 0, fmt.Errorf("invalid cursor")
+		}
+		cursor = id
+	}
+
+	return filter, cursor, limit, nil
+}
+
+// nextBulkEmailJobsCursor is the cursor of the page after `bulkJobs`, empty on the last page.
+// A full page means there may be more, the last job becomes the next cursor.
+func nextBulkEmailJobsCursor(bulkJobs []*repository.BulkEmailJob, limit int) string {
+	if len(bulkJobs) < limit || len(bulkJobs) == 0 {
+		return ""
+	}
+	return bulkJobs[len(bulkJobs)-1].ID.Hex()
+}
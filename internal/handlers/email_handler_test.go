@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestListBulkEmailJobsRejectsAnInvalidQuery(t *testing.T) {
+	for name, query := range map[string]string{
+		"status":    "status=CANCELLED",
+		"startDate": "startDate=03/04/2025",
+		"endDate":   "endDate=2025-13-01",
+		"cursor":    "cursor=not-an-object-id",
+	} {
+		// the query is rejected before the database is used, the core has none
+		req := httptest.NewRequest(http.MethodGet, "/api/send-email/jobs?email=jane@example.com&"+query, nil)
+		rec := httptest.NewRecorder()
+		c := &HandlerContext{Context: echo.New().NewContext(req, rec), Co: &core.Core{}}
+
+		if err := ListBulkEmailJobsHandler(c); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestBulkEmailJobsQuery(t *testing.T) {
+	cursor := primitive.NewObjectID()
+	req := httptest.NewRequest(http.MethodGet, "/api/send-email/jobs?status=completed&startDate=2025-03-01&endDate=2025-03-31&limit=50&cursor="+cursor.Hex(), nil)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	filter, gotCursor, limit, err := bulkEmailJobsQuery(c, "jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.UserEmail != "jane@example.com" || filter.Status != "COMPLETED" {
+		t.Errorf("expected the completed jobs of jane@example.com, got %+v", filter)
+	}
+	if !filter.From.Equal(time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)) || !filter.To.Equal(time.Date(2025, time.March, 31, 23, 59, 59, 0, time.UTC)) {
+		t.Errorf("expected the whole of March 2025, got %v to %v", filter.From, filter.To)
+	}
+	if gotCursor != cursor || limit != 50 {
+		t.Errorf("expected the cursor %s and the limit 50, got %s and %d", cursor.Hex(), gotCursor.Hex(), limit)
+	}
+
+	// without params the first page holds the default number of jobs
+	c = echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/send-email/jobs", nil), httptest.NewRecorder())
+	_, gotCursor, limit, err = bulkEmailJobsQuery(c, "jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotCursor.IsZero() || limit != 20 {
+		t.Errorf("expected no cursor and the limit 20, got %s and %d", gotCursor.Hex(), limit)
+	}
+}
+
+func TestNextBulkEmailJobsCursor(t *testing.T) {
+	page := []*repository.BulkEmailJob{{ID: primitive.NewObjectID()}, {ID: primitive.NewObjectID()}}
+
+	if cursor := nextBulkEmailJobsCursor(page, 2); cursor != page[1].ID.Hex() {
+		t.Errorf("expected a full page to continue after its last job %s, got %q", page[1].ID.Hex(), cursor)
+	}
+	if cursor := nextBulkEmailJobsCursor(page, 3); cursor != "" {
+		t.Errorf("expected no cursor after the last page, got %q", cursor)
+	}
+	if cursor := nextBulkEmailJobsCursor(nil, 0); cursor != "" {
+		t.Errorf("expected no cursor for an empty page, got %q", cursor)
+	}
+}
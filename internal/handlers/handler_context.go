@@ -23,3 +23,31 @@ func getEmailIDFromToken(c echo.Context) string {
 	claims := user.Claims.(jwt.MapClaims)
 	return claims["email"].(string)
 }
+
+// getRequestUserEmail resolves the caller's email from the JWT claims when the auth
+// middleware has parsed a token, falling back to the `email` query param otherwise.
+func getRequestUserEmail(c echo.Context) string {
+	if token, ok := c.Get("user").(*jwt.Token); ok {
+		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			if email, ok := claims["email"].(string); ok && len(email) > 0 {
+				return email
+			}
+		}
+	}
+	return c.QueryParam("email")
+}
+
+// getVerifiedUserEmail returns the email claim of the token verified by the auth middleware, empty when the request
+// carries no valid token. Unlike getRequestUserEmail it never falls back to the query params.
+func getVerifiedUserEmail(c echo.Context) string {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok || token == nil || !token.Valid {
+		return ""
+	}
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		if email, ok := claims["email"].(string); ok {
+			return email
+		}
+	}
+	return ""
+}
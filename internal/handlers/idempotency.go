@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header carrying the client generated key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// idempotencyKeyTTL is how long a completed response stays replayable.
+	idempotencyKeyTTL = 24 * time.Hour
+	// idempotencyLockTTL is how long a request may hold the key before it's considered abandoned.
+	idempotencyLockTTL = 5 * time.Minute
+)
+
+// idempotencyRecorder tees everything written to the response, so it can be stored for replays.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *idempotencyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *idempotencyRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// idempotencyStore keeps the idempotency keys, the MongoDB client of the core.
+type idempotencyStore interface {
+	ReserveIdempotencyKey(k *repository.IdempotencyKey) error
+	GetIdempotencyKey(id string) (*repository.IdempotencyKey, error)
+	CompleteIdempotencyKey(id string, responseStatus int, contentType string, body []byte) error
+	ReleaseIdempotencyKey(id string) error
+}
+
+// IdempotencyMiddleware makes a route safe to retry when the client sends an `Idempotency-Key` header.
+//   - The first request reserves the key and its response is stored in MongoDB with a TTL.
+//   - A retry with the same key and payload gets the stored response replayed.
+//   - A retry while the first request is still running is rejected with 409.
+//   - Reusing a key with a different payload is rejected with 422.
+//
+// Requests without the header are passed through untouched.
+func IdempotencyMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return idempotencyMiddleware(next, func(c echo.Context) idempotencyStore {
+		return c.(*HandlerContext).GetCore().DB
+	})
+}
+
+func idempotencyMiddleware(next echo.HandlerFunc, keys func(c echo.Context) idempotencyStore) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(IdempotencyKeyHeader)
+		if len(key) == 0 {
+			return next(c)
+		}
+		if len(key) > 255 {
+			return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("%s header must be at most 255 characters", IdempotencyKeyHeader))
+		}
+
+		hctx := c.(*HandlerContext)
+		store := keys(c)
+
+		// Read the body to fingerprint the request and put it back for the handler.
+		reqBody, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return SendErrorResponse(c, http.StatusBadRequest, err)
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(reqBody))
+
+		// The key is scoped to its caller, the keys of two users never share a stored response.
+		caller := idempotencyCaller(c, reqBody)
+		scopedID := sha256.Sum256([]byte(c.Request().Method + " " + c.Path() + " " + caller + " " + key))
+		requestHash := sha256.Sum256(reqBody)
+
+		record := &repository.IdempotencyKey{
+			ID:          hex.EncodeToString(scopedID[:]),
+			Key:         key,
+			Caller:      caller,
+			Method:      c.Request().Method,
+			Path:        c.Path(),
+			RequestHash: hex.EncodeToString(requestHash[:]),
+			LockedUntil: time.Now().Add(idempotencyLockTTL),
+			CreatedAt:   time.Now(),
+			ExpiresAt:   time.Now().Add(idempotencyKeyTTL),
+		}
+
+		err = store.ReserveIdempotencyKey(record)
+		if errors.Is(err, repository.ErrIdempotencyKeyExists) {
+			return replayIdempotentResponse(c, store, record)
+		}
+		if err != nil {
+			return SendErrorResponse(c, http.StatusInternalServerError, fmt.Errorf("unable to reserve idempotency key: %w", err))
+		}
+
+		recorder := &idempotencyRecorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = recorder
+
+		if err := next(c); err != nil {
+			store.ReleaseIdempotencyKey(record.ID)
+			return err
+		}
+
+		// Server side failures are not stored, the client is expected to retry them.
+		if c.Response().Status >= http.StatusInternalServerError {
+			store.ReleaseIdempotencyKey(record.ID)
+			return nil
+		}
+
+		contentType := c.Response().Header().Get(echo.HeaderContentType)
+		if err := store.CompleteIdempotencyKey(record.ID, c.Response().Status, contentType, recorder.body.Bytes()); err != nil {
+			hctx.GetCore().Lo.Error("unable to store idempotent response", "key", key, "error", err)
+		}
+		return nil
+	}
+}
+
+// idempotencyCaller is the email of the user sending the request: the one of the token or the `email` query param,
+// otherwise the `from` of the JSON body.
+func idempotencyCaller(c echo.Context, reqBody []byte) string {
+	if email := getRequestUserEmail(c); len(email) > 0 {
+		return strings.ToLower(email)
+	}
+	var body struct {
+		From string `json:"from"`
+	}
+	if err := json.Unmarshal(reqBody, &body); err == nil {
+		return strings.ToLower(strings.TrimSpace(body.From))
+	}
+	return ""
+}
+
+// replayIdempotentResponse answers a retried request from the stored idempotency key.
+func replayIdempotentResponse(c echo.Context, store idempotencyStore, record *repository.IdempotencyKey) error {
+	stored, err := store.GetIdempotencyKey(record.ID)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusConflict, fmt.Errorf("a request with this idempotency key is already being processed"))
+	}
+
+	if stored.RequestHash != record.RequestHash {
+		return SendErrorResponse(c, http.StatusUnprocessableEntity, fmt.Errorf("idempotency key was already used with a different request payload"))
+	}
+
+	if stored.Status != "COMPLETED" {
+		return SendErrorResponse(c, http.StatusConflict, fmt.Errorf("a request with this idempotency key is already being processed"))
+	}
+
+	c.Response().Header().Set("Idempotent-Replayed", "true")
+	return c.Blob(stored.ResponseStatus, stored.ResponseContentType, stored.ResponseBody)
+}
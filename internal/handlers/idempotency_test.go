@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestIdempotencyCallerScopesTheKeyToTheUser(t *testing.T) {
+	e := echo.New()
+	for name, tc := range map[string]struct {
+		target string
+		body   string
+		want   string
+	}{
+		"query param":  {"/api/send-email?email=Jane@example.com", `{"from":"john@example.com"}`, "jane@example.com"},
+		"body sender":  {"/api/send-email", `{"from":" John@example.com "}`, "john@example.com"},
+		"no caller":    {"/api/send-email", `{"to":["hr@example.com"]}`, ""},
+		"invalid body": {"/api/send-email", `not json`, ""},
+	} {
+		c := e.NewContext(httptest.NewRequest(http.MethodPost, tc.target, nil), httptest.NewRecorder())
+		if got := idempotencyCaller(c, []byte(tc.body)); got != tc.want {
+			t.Errorf("%s: expected caller %q, got %q", name, tc.want, got)
+		}
+	}
+}
+
+// fakeIdempotencyStore keeps the idempotency keys in memory.
+type fakeIdempotencyStore struct {
+	keys map[string]*repository.IdempotencyKey
+}
+
+func (s *fakeIdempotencyStore) ReserveIdempotencyKey(k *repository.IdempotencyKey) error {
+	if _, ok := s.keys[k.ID]; ok {
+		return repository.ErrIdempotencyKeyExists
+	}
+	k.Status = "IN_PROGRESS"
+	reserved := *k
+	s.keys[k.ID] = &reserved
+	return nil
+}
+
+func (s *fakeIdempotencyStore) GetIdempotencyKey(id string) (*repository.IdempotencyKey, error) {
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return k, nil
+}
+
+func (s *fakeIdempotencyStore) CompleteIdempotencyKey(id string, responseStatus int, contentType string, body []byte) error {
+	k := s.keys[id]
+	k.Status = "COMPLETED"
+	k.ResponseStatus = responseStatus
+	k.ResponseContentType = contentType
+	k.ResponseBody = body
+	return nil
+}
+
+func (s *fakeIdempotencyStore) ReleaseIdempotencyKey(id string) error {
+	delete(s.keys, id)
+	return nil
+}
+
+// idempotentRequest sends the request with the idempotency key through the middleware wrapping `handler`.
+func idempotentRequest(store *fakeIdempotencyStore, handler echo.HandlerFunc, key, body string) *httptest.ResponseRecorder {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/send-email?email=jane@example.com", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(IdempotencyKeyHeader, key)
+	rec := httptest.NewRecorder()
+	c := &HandlerContext{Context: e.NewContext(req, rec), Co: &core.Core{Lo: slog.Default()}}
+	c.SetPath("/api/send-email")
+
+	mw := idempotencyMiddleware(handler, func(c echo.Context) idempotencyStore { return store })
+	if err := mw(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+func TestIdempotencyMiddlewareReplaysTheStoredResponse(t *testing.T) {
+	store := &fakeIdempotencyStore{keys: map[string]*repository.IdempotencyKey{}}
+	runs := 0
+	handler := func(c echo.Context) error {
+		runs++
+		return c.JSON(http.StatusCreated, map[string]int{"run": runs})
+	}
+
+	first := idempotentRequest(store, handler, "key-1", `{"to":["hr@acme.com"]}`)
+	retry := idempotentRequest(store, handler, "key-1", `{"to":["hr@acme.com"]}`)
+
+	if runs != 1 {
+		t.Errorf("expected the handler to run once, it ran %d times", runs)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("expected the replay of %d %s, got %d %s", first.Code, first.Body, retry.Code, retry.Body)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("expected only the retry to be marked as replayed")
+	}
+}
+
+func TestIdempotencyMiddlewareRejectsTheRetryOfARunningRequest(t *testing.T) {
+	store := &fakeIdempotencyStore{keys: map[string]*repository.IdempotencyKey{}}
+	var retry *httptest.ResponseRecorder
+	var handler echo.HandlerFunc
+	handler = func(c echo.Context) error {
+		// the client retries while the first request is still running
+		if retry == nil {
+			retry = idempotentRequest(store, handler, "key-1", `{"to":["hr@acme.com"]}`)
+		}
+		return c.NoContent(http.StatusOK)
+	}
+
+	first := idempotentRequest(store, handler, "key-1", `{"to":["hr@acme.com"]}`)
+	if first.Code != http.StatusOK || retry.Code != http.StatusConflict {
+		t.Errorf("expected 200 then 409, got %d and %d", first.Code, retry.Code)
+	}
+}
+
+func TestIdempotencyMiddlewareRejectsTheKeyReusedWithAnotherPayload(t *testing.T) {
+	store := &fakeIdempotencyStore{keys: map[string]*repository.IdempotencyKey{}}
+	runs := 0
+	handler := func(c echo.Context) error {
+		runs++
+		return c.NoContent(http.StatusOK)
+	}
+
+	idempotentRequest(store, handler, "key-1", `{"to":["hr@acme.com"]}`)
+	reused := idempotentRequest(store, handler, "key-1", `{"to":["cto@acme.com"]}`)
+
+	if reused.Code != http.StatusUnprocessableEntity || runs != 1 {
+		t.Errorf("expected 422 without running the handler again, got %d after %d runs", reused.Code, runs)
+	}
+}
+
+func TestIdempotencyMiddlewareDoesNotStoreTheServerErrors(t *testing.T) {
+	store := &fakeIdempotencyStore{keys: map[string]*repository.IdempotencyKey{}}
+	runs := 0
+	handler := func(c echo.Context) error {
+		runs++
+		if runs == 1 {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "smtp unavailable"})
+		}
+		return c.NoContent(http.StatusOK)
+	}
+
+	failed := idempotentRequest(store, handler, "key-1", `{"to":["hr@acme.com"]}`)
+	retry := idempotentRequest(store, handler, "key-1", `{"to":["hr@acme.com"]}`)
+
+	if failed.Code != http.StatusInternalServerError || retry.Code != http.StatusOK || runs != 2 {
+		t.Errorf("expected the retry of the 500 to run again, got %d then %d after %d runs", failed.Code, retry.Code, runs)
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/events"
+)
+
+// keepAliveInterval is how often an idle stream is pinged so proxies keep it open.
+const keepAliveInterval = 15 * time.Second
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     isAllowedWebSocketOrigin,
+}
+
+// isAllowedWebSocketOrigin mirrors the CORS origins allowed by the server.
+func isAllowedWebSocketOrigin(r *http.Request) bool {
+	switch r.Header.Get("Origin") {
+	case "", "http://localhost:4200", "http://localhost:3000":
+		return true
+	}
+	return false
+}
+
+// subscribeToJobEvents validates the caller and subscribes to their job events,
+// optionally narrowed down to a single job via the `jobId` query param.
+func subscribeToJobEvents(c echo.Context) (<-chan events.Event, func(), error) {
+	hctx := c.(*HandlerContext)
+
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return nil, nil, fmt.Errorf("invalid email or no email found")
+	}
+
+	jobID := c.QueryParam("jobId")
+	filter := func(ev events.Event) bool {
+		return ev.UserEmail == userEmail && (jobID == "" || ev.JobID == jobID)
+	}
+
+	ch, unsubscribe := hctx.GetCore().Events.Subscribe(filter, 64)
+	return ch, unsubscribe, nil
+}
+
+// StreamJobEventsHandler streams the user's job progress as Server-Sent Events.
+func StreamJobEventsHandler(c echo.Context) error {
+	ch, unsubscribe, err := subscribeToJobEvents(c)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, err)
+	}
+	defer unsubscribe()
+
+	w := startEventStream(c)
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			w.Flush()
+		case ev, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := writeEvent(w, string(ev.Type), ev); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// startEventStream answers the request with a Server-Sent Events stream.
+func startEventStream(c echo.Context) *echo.Response {
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+	return w
+}
+
+// writeEvent sends the data as JSON in a Server-Sent Event, a data which can't be encoded is skipped.
+func writeEvent(w *echo.Response, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, payload); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
+
+// JobEventsWebSocketHandler streams the user's job progress over a WebSocket.
+func JobEventsWebSocketHandler(c echo.Context) error {
+	ch, unsubscribe, err := subscribeToJobEvents(c)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, err)
+	}
+	defer unsubscribe()
+
+	conn, err := wsUpgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Drain the client side, so a close frame or a broken connection ends the stream.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return nil
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+				return nil
+			}
+		case ev, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := conn.WriteJSON(ev); err != nil {
+				return nil
+			}
+		}
+	}
+}
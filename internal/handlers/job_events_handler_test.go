@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/events"
+)
+
+func TestSubscribeToJobEventsFiltersByUserAndJob(t *testing.T) {
+	bus := events.NewBus()
+	subscribe := func(target string) (<-chan events.Event, func(), error) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		c := &HandlerContext{Context: echo.New().NewContext(req, httptest.NewRecorder()), Co: &core.Core{Events: bus}}
+		return subscribeToJobEvents(c)
+	}
+
+	if _, _, err := subscribe("/api/jobs/events"); err == nil {
+		t.Error("expected a subscription without a user to be rejected")
+	}
+
+	userEvents, unsubscribeUser, err := subscribe("/api/jobs/events?email=jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribeUser()
+	jobEvents, unsubscribeJob, err := subscribe("/api/jobs/events?email=jane@example.com&jobId=job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribeJob()
+
+	bus.Publish(events.Event{Type: events.JOB_STAGE_CHANGED, UserEmail: "jane@example.com", JobID: "job-1"})
+	bus.Publish(events.Event{Type: events.JOB_COMPLETED, UserEmail: "jane@example.com", JobID: "job-2"})
+	bus.Publish(events.Event{Type: events.JOB_COMPLETED, UserEmail: "john@example.com", JobID: "job-1"})
+
+	if len(userEvents) != 2 {
+		t.Errorf("expected the 2 events of jane, got %d", len(userEvents))
+	}
+	if len(jobEvents) != 1 {
+		t.Fatalf("expected the single event of jane's job-1, got %d", len(jobEvents))
+	}
+	if ev := <-jobEvents; ev.Type != events.JOB_STAGE_CHANGED {
+		t.Errorf("expected the stage change of job-1, got %s", ev.Type)
+	}
+}
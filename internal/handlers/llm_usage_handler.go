@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+// GetLLMUsageHandler reports the tokens the user consumed today and this month against their quotas,
+// broken down by feature, with their latest calls.
+func GetLLMUsageHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+
+	usage, err := hctx.GetCore().GetLLMUsage(userEmail)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, fmt.Errorf("failed to fetch the llm usage: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, usage)
+}
+
+// AggregateLLMUsageHandler sums the LLM usage of every user over the last `days` (30 by default),
+// grouped by `groupBy`: `user` (default), `feature` or `model`. The biggest consumers come first, up to `limit`.
+func AggregateLLMUsageHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	group := c.QueryParam("groupBy")
+	groupBy := repository.LLM_USAGE_BY_USER
+	switch group {
+	case "", "user":
+		group = "user"
+	case "feature":
+		groupBy = repository.LLM_USAGE_BY_FEATURE
+	case "model":
+		groupBy = repository.LLM_USAGE_BY_MODEL
+	default:
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("groupBy must be one of user, feature or model"))
+	}
+
+	days := 30
+	if d := c.QueryParam("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 || n > 366 {
+			return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("days must be between 1 and 366"))
+		}
+		days = n
+	}
+
+	limit := 50
+	if l := c.QueryParam("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	totals, err := hctx.GetCore().DB.AggregateLLMUsage("", groupBy, since, limit)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, fmt.Errorf("failed to aggregate the llm usage: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"data": totals,
+		"meta": map[string]any{
+			"groupBy": group,
+			"since":   since,
+			"limit":   limit,
+		},
+	})
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+// GetOutreachPolicyHandler returns the duplicate outreach policy of the user.
+func GetOutreachPolicyHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+
+	policy, err := hctx.GetCore().DB.GetOutreachPolicy(userEmail)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+// UpdateOutreachPolicyHandler configures the duplicate outreach policy of the user.
+func UpdateOutreachPolicyHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	var policy repository.OutreachPolicy
+	if err := c.Bind(&policy); err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, err)
+	}
+
+	// the policy always belongs to the caller, a userEmail sent in the body is ignored
+	policy.UserEmail = getRequestUserEmail(c)
+	if !isValidEmail(policy.UserEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+
+	policy.Action = strings.ToUpper(policy.Action)
+	switch policy.Action {
+	case repository.OUTREACH_POLICY_OFF, repository.OUTREACH_POLICY_WARN, repository.OUTREACH_POLICY_BLOCK:
+	default:
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("action must be one of OFF, WARN or BLOCK"))
+	}
+	if policy.RecipientCooldownDays < 0 || policy.CompanyWeeklyLimit < 0 {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("recipientCooldownDays and companyWeeklyLimit cannot be negative"))
+	}
+
+	if err := hctx.GetCore().DB.UpsertOutreachPolicy(&policy); err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
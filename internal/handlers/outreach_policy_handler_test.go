@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+)
+
+func TestUpdateOutreachPolicyIgnoresTheUserEmailOfTheBody(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPatch, "/api/profile/outreach-policy", strings.NewReader(`{"userEmail":"victim@example.com","action":"OFF"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := &HandlerContext{Context: e.NewContext(req, rec), Co: &core.Core{}}
+
+	// without a caller the policy has no owner, it must not be stored for the email of the body
+	if err := UpdateOutreachPolicyHandler(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
@@ -2,11 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/jobs"
 	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // ProfileInformationHandler handlers the submission of information
@@ -53,8 +59,9 @@ func ProfileInformationHandler(c echo.Context) error {
 		return SendErrorResponse(c, http.StatusInternalServerError, err)
 	}
 
-	// Push the resume update as new job
-	if err = hctx.GetCore().SubmitResumeToJobQueue(email, dstPath); err != nil {
+	// Push the resume update as new job, the structured information the user corrected is kept unless `discardEdits`.
+	discardEdits, _ := strconv.ParseBool(c.FormValue("discardEdits"))
+	if _, err = jobs.EnqueueResumeProcessing(hctx.GetCore(), email, dstPath, discardEdits); err != nil {
 		return SendErrorResponse(c, http.StatusInternalServerError, err)
 	}
 
@@ -177,3 +184,153 @@ func UpdateProfileHandler(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Profile updated successfully"})
 }
+
+// ProfileProcessingStatus is the latest resume processing job of the user, with its stage runs and error.
+type ProfileProcessingStatus struct {
+	*repository.JobQueue
+	// ElapsedMs is the time since the job was queued, until it finished.
+	ElapsedMs int64 `json:"elapsedMs"`
+}
+
+// GetProfileProcessingHandler tells where the user's latest resume processing stands.
+func GetProfileProcessingHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+
+	job, err := hctx.GetCore().DB.GetLatestJob(userEmail, jobs.RESUME_PROCESSING)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return SendErrorResponse(c, http.StatusNotFound, fmt.Errorf("no resume processing found, upload a resume first"))
+	}
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	finishedAt := time.Now()
+	if job.Status == "COMPLETED" || job.Status == "FAILED" {
+		finishedAt = job.UpdatedAt
+	}
+
+	return c.JSON(http.StatusOK, ProfileProcessingStatus{
+		JobQueue:  job,
+		ElapsedMs: finishedAt.Sub(job.CreatedAt).Milliseconds(),
+	})
+}
+
+// reprocessStore reads the profile and the jobs of the user, the MongoDB client of the core.
+type reprocessStore interface {
+	GetProfileByEmail(email string) (*repository.User, error)
+	HasActiveJob(userEmail, kind string) (bool, error)
+	GetResumeInformation(email string) (*repository.ResumeInformation, error)
+}
+
+// ReprocessProfileHandler runs the resume processing again on the resume already stored for the user.
+func ReprocessProfileHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+	return reprocessProfile(c, hctx.GetCore().DB, func(userEmail, resume string, discardEdits bool) (primitive.ObjectID, error) {
+		return jobs.EnqueueResumeProcessing(hctx.GetCore(), userEmail, resume, discardEdits)
+	})
+}
+
+// reprocessProfile enqueues the resume processing of the user, unless one is already PENDING or IN_PROGRESS.
+func reprocessProfile(c echo.Context, store reprocessStore, enqueue func(userEmail, resume string, discardEdits bool) (primitive.ObjectID, error)) error {
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+
+	u, err := store.GetProfileByEmail(userEmail)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusNotFound, fmt.Errorf("profile not found"))
+	}
+	if len(u.Resume) == 0 {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("no resume uploaded yet"))
+	}
+
+	active, err := store.HasActiveJob(userEmail, jobs.RESUME_PROCESSING)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+	if active {
+		return SendErrorResponse(c, http.StatusConflict, fmt.Errorf("the resume is already being processed"))
+	}
+
+	// The structured information the user corrected is kept, unless `discardEdits=true`.
+	discardEdits, _ := strconv.ParseBool(c.QueryParam("discardEdits"))
+	info, err := store.GetResumeInformation(userEmail)
+	if err != nil && !errors.Is(err, repository.ErrResumeInformationNotFound) {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	jobID, err := enqueue(userEmail, u.Resume, discardEdits)
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	response := map[string]string{
+		"message": "Resume processing started",
+		"jobId":   jobID.Hex(),
+	}
+	if info != nil && info.EditedByUser && !discardEdits {
+		response["warning"] = "the structured information you corrected is kept, reprocess with discardEdits=true to parse the resume again"
+	}
+	return c.JSON(http.StatusAccepted, response)
+}
+
+// GetStructuredProfileHandler returns the structured information parsed out of the user's resume.
+func GetStructuredProfileHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+
+	info, err := hctx.GetCore().DB.GetResumeInformation(userEmail)
+	if errors.Is(err, repository.ErrResumeInformationNotFound) {
+		return SendErrorResponse(c, http.StatusNotFound, err)
+	}
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, info)
+}
+
+// UpdateStructuredProfileHandler lets the user correct the structured information parsed out of their resume.
+// Only the fields present in the body are changed, lists are replaced as a whole.
+func UpdateStructuredProfileHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+
+	info, err := hctx.GetCore().DB.GetResumeInformation(userEmail)
+	if errors.Is(err, repository.ErrResumeInformationNotFound) {
+		return SendErrorResponse(c, http.StatusNotFound, err)
+	}
+	if err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	// The body is decoded on top of the stored document.
+	id, extractedContent := info.ID, info.ExtractedContent
+	if err := c.Bind(info); err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	info.ID = id
+	info.Email = userEmail
+	info.ExtractedContent = extractedContent
+	info.EditedByUser = true
+
+	if err := hctx.GetCore().DB.UpsertResumeInformation(info); err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, info)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeReprocessStore holds a user with a stored resume, and tells whether a resume processing is running.
+type fakeReprocessStore struct {
+	active bool
+}
+
+func (s *fakeReprocessStore) GetProfileByEmail(email string) (*repository.User, error) {
+	return &repository.User{Email: email, Resume: "gs://resumes/jane@example.com/resume.pdf"}, nil
+}
+
+func (s *fakeReprocessStore) HasActiveJob(userEmail, kind string) (bool, error) {
+	return s.active, nil
+}
+
+func (s *fakeReprocessStore) GetResumeInformation(email string) (*repository.ResumeInformation, error) {
+	return nil, repository.ErrResumeInformationNotFound
+}
+
+func TestReprocessProfileRejectsAReprocessWhileOneIsRunning(t *testing.T) {
+	for name, tc := range map[string]struct {
+		active       bool
+		wantStatus   int
+		wantEnqueued int
+	}{
+		"nothing running":      {active: false, wantStatus: http.StatusAccepted, wantEnqueued: 1},
+		"processing in flight": {active: true, wantStatus: http.StatusConflict, wantEnqueued: 0},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/profile/reprocess?email=jane@example.com", nil)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+
+		enqueued := 0
+		err := reprocessProfile(c, &fakeReprocessStore{active: tc.active}, func(userEmail, resume string, discardEdits bool) (primitive.ObjectID, error) {
+			enqueued++
+			return primitive.NewObjectID(), nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if rec.Code != tc.wantStatus || enqueued != tc.wantEnqueued {
+			t.Errorf("%s: expected %d with %d job(s) enqueued, got %d with %d", name, tc.wantStatus, tc.wantEnqueued, rec.Code, enqueued)
+		}
+	}
+}
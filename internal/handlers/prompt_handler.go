@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+// ForkPromptVersionDto forks a version of a prompt, the template of `fromVersion` is copied when `template` is empty.
+type ForkPromptVersionDto struct {
+	FromVersion int    `json:"fromVersion"`
+	Template    string `json:"template"`
+	Description string `json:"description"`
+}
+
+// ListPromptsHandler lists the prompts of the registry with their variables and the version active for the user.
+func ListPromptsHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+
+	type promptSummary struct {
+		core.PromptDefinition
+		ActiveVersion int `json:"activeVersion"`
+	}
+	prompts := make([]promptSummary, 0)
+	for _, def := range core.PromptDefinitions() {
+		active, err := hctx.GetCore().ActivePromptVersion(userEmail, def.ID)
+		if err != nil {
+			return SendErrorResponse(c, http.StatusInternalServerError, err)
+		}
+		prompts = append(prompts, promptSummary{PromptDefinition: def, ActiveVersion: active.Version})
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"data": prompts,
+	})
+}
+
+// ListPromptVersionsHandler lists the built-in, the global and the user's own versions of a prompt.
+func ListPromptVersionsHandler(c echo.Context) error {
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+	return listPromptVersions(c, userEmail, false)
+}
+
+// GetPromptVersionHandler returns a version of a prompt visible to the user.
+func GetPromptVersionHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid version"))
+	}
+
+	v, err := hctx.GetCore().GetPromptVersion(core.PromptID(c.Param("id")), version)
+	if err == nil && !v.Builtin && v.OwnerEmail != "" && v.OwnerEmail != userEmail {
+		err = repository.ErrPromptVersionNotFound
+	}
+	if err != nil {
+		return sendPromptError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, v)
+}
+
+// ForkPromptVersionHandler stores a new version of a prompt, owned by the user.
+func ForkPromptVersionHandler(c echo.Context) error {
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+	return forkPromptVersion(c, userEmail, userEmail)
+}
+
+// ActivatePromptVersionHandler makes a version of a prompt the one used for the user's generations.
+func ActivatePromptVersionHandler(c echo.Context) error {
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+	return activatePromptVersion(c, userEmail, userEmail)
+}
+
+// ResetPromptActivationHandler falls the user back to the global version of a prompt.
+func ResetPromptActivationHandler(c echo.Context) error {
+	hctx := c.(*HandlerContext)
+
+	userEmail := getRequestUserEmail(c)
+	if len(userEmail) == 0 || !isValidEmail(userEmail) {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid email or no email found"))
+	}
+
+	id := core.PromptID(c.Param("id"))
+	if _, err := core.LookupPromptDefinition(id); err != nil {
+		return sendPromptError(c, err)
+	}
+	if err := hctx.GetCore().DB.DeletePromptActivation(string(id), userEmail); err != nil {
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+
+	active, err := hctx.GetCore().ActivePromptVersion(userEmail, id)
+	if err != nil {
+		return sendPromptError(c, err)
+	}
+	return c.JSON(http.StatusOK, active)
+}
+
+// AdminListPromptVersionsHandler lists every version of a prompt, whatever its owner.
+func AdminListPromptVersionsHandler(c echo.Context) error {
+	return listPromptVersions(c, "", true)
+}
+
+// AdminForkPromptVersionHandler stores a new global version of a prompt.
+func AdminForkPromptVersionHandler(c echo.Context) error {
+	return forkPromptVersion(c, "", getVerifiedUserEmail(c))
+}
+
+// AdminActivatePromptVersionHandler makes a version of a prompt the one used for every user without their own activation.
+func AdminActivatePromptVersionHandler(c echo.Context) error {
+	return activatePromptVersion(c, "", getVerifiedUserEmail(c))
+}
+
+func listPromptVersions(c echo.Context, ownerEmail string, allOwners bool) error {
+	hctx := c.(*HandlerContext)
+
+	versions, err := hctx.GetCore().ListPromptVersions(core.PromptID(c.Param("id")), ownerEmail, allOwners)
+	if err != nil {
+		return sendPromptError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"data": versions,
+	})
+}
+
+func forkPromptVersion(c echo.Context, ownerEmail, createdBy string) error {
+	hctx := c.(*HandlerContext)
+
+	var dto ForkPromptVersionDto
+	if err := c.Bind(&dto); err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, err)
+	}
+	if dto.FromVersion == 0 {
+		dto.FromVersion = core.BUILTIN_PROMPT_VERSION
+	}
+
+	v, err := hctx.GetCore().ForkPromptVersion(core.PromptID(c.Param("id")), dto.FromVersion, ownerEmail, dto.Template, dto.Description, createdBy)
+	if err != nil {
+		return sendPromptError(c, err)
+	}
+
+	hctx.GetCore().Lo.Info("prompt version forked", "promptId", v.PromptID, "version", v.Version, "forkedFrom", v.ForkedFrom, "by", createdBy)
+	return c.JSON(http.StatusCreated, v)
+}
+
+func activatePromptVersion(c echo.Context, ownerEmail, activatedBy string) error {
+	hctx := c.(*HandlerContext)
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		return SendErrorResponse(c, http.StatusBadRequest, fmt.Errorf("invalid version"))
+	}
+
+	v, err := hctx.GetCore().ActivatePromptVersion(core.PromptID(c.Param("id")), version, ownerEmail, activatedBy)
+	if err != nil {
+		return sendPromptError(c, err)
+	}
+
+	hctx.GetCore().Lo.Info("prompt version activated", "promptId", v.PromptID, "version", v.Version, "owner", ownerEmail, "by", activatedBy)
+	return c.JSON(http.StatusOK, v)
+}
+
+// sendPromptError maps the errors of the prompt registry to their status code.
+func sendPromptError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, core.ErrUnknownPrompt), errors.Is(err, repository.ErrPromptVersionNotFound):
+		return SendErrorResponse(c, http.StatusNotFound, err)
+	case errors.Is(err, core.ErrInvalidPromptTemplate):
+		return SendErrorResponse(c, http.StatusBadRequest, err)
+	default:
+		return SendErrorResponse(c, http.StatusInternalServerError, err)
+	}
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -8,9 +10,11 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
 )
 
 // SendErrorResponse sends the API Errors in JSON formatted standard.
@@ -22,6 +26,27 @@ func SendErrorResponse(c echo.Context, statusCode int, err error) error {
 	})
 }
 
+// SendLLMErrorResponse sends the error of an LLM feature, a used up token quota is a 429 telling when it resets.
+func SendLLMErrorResponse(c echo.Context, statusCode int, err error) error {
+	var quotaErr *core.LLMQuotaExceededError
+	if errors.As(err, &quotaErr) {
+		retryAfter := int(time.Until(quotaErr.ResetAt).Seconds()) + 1
+		c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		return SendErrorResponse(c, http.StatusTooManyRequests, err)
+	}
+	return SendErrorResponse(c, statusCode, err)
+}
+
+// llmRequestContext is the context of the LLM calls of the request, skipping the LLM cache
+// when asked with `force` in the body or `force=true` in the query string.
+func llmRequestContext(c echo.Context, force bool) context.Context {
+	ctx := c.Request().Context()
+	if queryForce, _ := strconv.ParseBool(c.QueryParam("force")); force || queryForce {
+		ctx = core.WithoutLLMCache(ctx)
+	}
+	return ctx
+}
+
 // isValidEmail checks whether the string s contains any match of the regular expression email.
 func isValidEmail(email string) bool {
 	var emailRgx = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
@@ -0,0 +1,264 @@
+package jobposting
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// MAX_DESCRIPTION_RUNES caps the extracted description, long pages are mostly noise for the prompts.
+const MAX_DESCRIPTION_RUNES = 12000
+
+// Posting is a downloaded job posting.
+type Posting struct {
+	// URL is where the posting was found, after the redirects.
+	URL      string `json:"url" bson:"url"`
+	Title    string `json:"title,omitempty" bson:"title,omitempty"`
+	Company  string `json:"company,omitempty" bson:"company,omitempty"`
+	Location string `json:"location,omitempty" bson:"location,omitempty"`
+	// Description is the plain text of the posting.
+	Description string `json:"description" bson:"description"`
+	// Structured is true when the posting was read from the JSON-LD JobPosting of the page.
+	Structured bool `json:"structured" bson:"structured"`
+	// Truncated is true when the page was larger than the download limit.
+	Truncated bool `json:"truncated,omitempty" bson:"truncated,omitempty"`
+}
+
+// Text is the posting as handed over to the prompts: its title, company and location, then its description.
+func (p *Posting) Text() string {
+	var header []string
+	for _, field := range []string{p.Title, p.Company, p.Location} {
+		if field != "" {
+			header = append(header, field)
+		}
+	}
+	if len(header) == 0 {
+		return p.Description
+	}
+	return strings.Join(header, " - ") + "\n\n" + p.Description
+}
+
+// Extract reads the posting out of an HTML page: from its JSON-LD JobPosting when there's one,
+// otherwise from the text of its main content.
+func Extract(page []byte) *Posting {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return &Posting{}
+	}
+
+	var scripts []string
+	var title string
+	walk(doc, func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.Script:
+			if strings.EqualFold(attr(n, "type"), "application/ld+json") && n.FirstChild != nil {
+				scripts = append(scripts, n.FirstChild.Data)
+			}
+			return false
+		case atom.Title:
+			if title == "" && n.FirstChild != nil {
+				title = normalizeText(n.FirstChild.Data)
+			}
+			return false
+		}
+		return true
+	})
+
+	for _, script := range scripts {
+		if posting := jobPostingFromJSONLD(script); posting != nil {
+			return posting
+		}
+	}
+	return &Posting{Title: title, Description: truncateRunes(mainText(doc), MAX_DESCRIPTION_RUNES)}
+}
+
+// jobPostingFromJSONLD returns the first JobPosting of the JSON-LD script, which may hold a single object,
+// a list of them or a @graph.
+func jobPostingFromJSONLD(script string) *Posting {
+	var data any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(script)), &data); err != nil {
+		return nil
+	}
+
+	var find func(v any) map[string]any
+	find = func(v any) map[string]any {
+		switch v := v.(type) {
+		case []any:
+			for _, item := range v {
+				if found := find(item); found != nil {
+					return found
+				}
+			}
+		case map[string]any:
+			if hasType(v["@type"], "JobPosting") {
+				return v
+			}
+			return find(v["@graph"])
+		}
+		return nil
+	}
+	job := find(data)
+	if job == nil {
+		return nil
+	}
+
+	description := stringField(job["description"])
+	// The description is most often HTML itself.
+	if doc, err := html.Parse(strings.NewReader(description)); err == nil {
+		description = mainText(doc)
+	}
+	var sections []string
+	for _, key := range []string{"responsibilities", "qualifications", "skills", "experienceRequirements", "educationRequirements"} {
+		if text := normalizeText(stringField(job[key])); text != "" {
+			sections = append(sections, text)
+		}
+	}
+	if len(sections) > 0 {
+		description = strings.TrimSpace(description + "\n\n" + strings.Join(sections, "\n\n"))
+	}
+	if description == "" {
+		return nil
+	}
+
+	return &Posting{
+		Title:       normalizeText(stringField(job["title"])),
+		Company:     normalizeText(stringField(job["hiringOrganization"])),
+		Location:    normalizeText(stringField(job["jobLocation"])),
+		Description: truncateRunes(description, MAX_DESCRIPTION_RUNES),
+		Structured:  true,
+	}
+}
+
+// hasType is true when the JSON-LD @type, a string or a list, holds `want`.
+func hasType(v any, want string) bool {
+	switch v := v.(type) {
+	case string:
+		return v == want
+	case []any:
+		for _, t := range v {
+			if s, ok := t.(string); ok && s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stringField flattens a JSON-LD value into text: the name of an organization, the locality of an address,
+// the items of a list.
+func stringField(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []any:
+		var parts []string
+		for _, item := range v {
+			if s := stringField(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, "; ")
+	case map[string]any:
+		for _, key := range []string{"name", "address", "addressLocality", "description"} {
+			if s := stringField(v[key]); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// skippedElements hold no content of the posting.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true, atom.Svg: true, atom.Iframe: true,
+	atom.Head: true, atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Form: true, atom.Button: true,
+}
+
+// blockElements end a line of text.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Ul: true, atom.Ol: true, atom.Tr: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Section: true, atom.Article: true, atom.Main: true, atom.Table: true, atom.Dd: true, atom.Dt: true,
+}
+
+// mainText is the text of the <main> or <article> of the page when it has one, otherwise of its whole body.
+func mainText(doc *html.Node) string {
+	root := doc
+	walk(doc, func(n *html.Node) bool {
+		if root != doc {
+			return false
+		}
+		if n.DataAtom == atom.Main || n.DataAtom == atom.Article {
+			root = n
+			return false
+		}
+		return !skippedElements[n.DataAtom]
+	})
+
+	var b strings.Builder
+	var write func(n *html.Node)
+	write = func(n *html.Node) {
+		if n.Type == html.ElementNode && skippedElements[n.DataAtom] {
+			return
+		}
+		// The line breaks of the source are blanks, only the blocks break the lines.
+		if n.Type == html.TextNode {
+			b.WriteString(strings.ReplaceAll(n.Data, "\n", " "))
+			return
+		}
+		if n.DataAtom == atom.Li {
+			b.WriteString("\n- ")
+		} else if blockElements[n.DataAtom] {
+			b.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			write(c)
+		}
+		if blockElements[n.DataAtom] {
+			b.WriteString("\n")
+		}
+	}
+	write(root)
+	return normalizeText(b.String())
+}
+
+// walk visits the nodes depth first, the children of a node are skipped when `visit` returns false.
+func walk(n *html.Node, visit func(n *html.Node) bool) {
+	if n.Type == html.ElementNode && !visit(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, visit)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// normalizeText collapses the blanks of every line and drops the empty lines.
+func normalizeText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" && line != "-" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func truncateRunes(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,168 @@
+// Package jobposting downloads job postings and extracts their description, from the JSON-LD JobPosting
+// of the page when there's one, otherwise from the main text of the page.
+package jobposting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// DEFAULT_MAX_BYTES is how much of a page is downloaded, the rest is ignored.
+	DEFAULT_MAX_BYTES = 2 << 20
+	// DEFAULT_TIMEOUT bounds the download of a page, redirects included.
+	DEFAULT_TIMEOUT = 10 * time.Second
+	// maxRedirects is how many redirects are followed.
+	maxRedirects = 5
+)
+
+var (
+	// ErrUnsupportedURL is returned for the URLs which aren't http(s).
+	ErrUnsupportedURL = errors.New("unsupported job posting url")
+	// ErrForbiddenHost is returned for the hosts resolving to a private, loopback or link-local address.
+	ErrForbiddenHost = errors.New("job posting host is not publicly routable")
+	// ErrNotHTML is returned when the page is neither HTML nor text.
+	ErrNotHTML = errors.New("job posting is not an html page")
+	// ErrNoDescription is returned when no text could be extracted from the page.
+	ErrNoDescription = errors.New("no job description found")
+)
+
+// Options configure the Fetcher.
+type Options struct {
+	// MaxBytes is how much of a page is downloaded, DEFAULT_MAX_BYTES when zero.
+	MaxBytes int64
+	// Timeout bounds the download of a page, DEFAULT_TIMEOUT when zero.
+	Timeout time.Duration
+	// AllowPrivateHosts lets the fetcher reach the private networks, for the tests and the local development.
+	AllowPrivateHosts bool
+	// UserAgent is sent along the requests.
+	UserAgent string
+}
+
+// Fetcher downloads the job postings.
+type Fetcher struct {
+	opts   Options
+	client *http.Client
+}
+
+// NewFetcher creates a fetcher. Unless `opts.AllowPrivateHosts`, the addresses are checked when dialing,
+// so that a posting, or one of its redirects, can't point the service at its own network.
+func NewFetcher(opts Options) *Fetcher {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DEFAULT_MAX_BYTES
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DEFAULT_TIMEOUT
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = "Mozilla/5.0 (compatible; referrer-mailer/1.0)"
+	}
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivateHosts {
+		dialer.Control = rejectPrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Fetcher{
+		opts: opts,
+		client: &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return checkURL(req.URL)
+			},
+		},
+	}
+}
+
+// Fetch downloads the posting at `rawURL` and extracts its description.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Posting, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedURL, err)
+	}
+	if err := checkURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.opts.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the job posting: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch the job posting: %s", resp.Status)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" && mediaType != "text/plain" {
+		return nil, fmt.Errorf("%w: %s", ErrNotHTML, mediaType)
+	}
+
+	// One byte more than the limit tells a page cut short from a page of exactly the limit.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.opts.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the job posting: %w", err)
+	}
+	truncated := int64(len(body)) > f.opts.MaxBytes
+	if truncated {
+		body = body[:f.opts.MaxBytes]
+	}
+
+	var posting *Posting
+	if mediaType == "text/plain" {
+		posting = &Posting{Description: normalizeText(string(body))}
+	} else {
+		posting = Extract(body)
+	}
+	if posting.Description == "" {
+		return nil, ErrNoDescription
+	}
+	posting.URL = resp.Request.URL.String()
+	posting.Truncated = truncated
+	return posting, nil
+}
+
+// checkURL only lets the http(s) URLs through.
+func checkURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%w: %s", ErrUnsupportedURL, u.Redacted())
+	}
+	return nil
+}
+
+// rejectPrivateAddress refuses to dial the addresses which aren't publicly routable.
+func rejectPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast() || ip.IsInterfaceLocalMulticast() {
+		return fmt.Errorf("%w: %s", ErrForbiddenHost, host)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package jobposting
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const jsonLDPage = `<!doctype html>
+<html><head><title>Careers | Acme</title>
+<script type="application/ld+json">
+{"@context": "https://schema.org", "@graph": [
+  {"@type": "Organization", "name": "Acme"},
+  {"@type": "JobPosting", "title": "Backend Engineer",
+   "description": "<p>Build <b>Go</b> services.</p><ul><li>Kubernetes</li><li>PostgreSQL</li></ul>",
+   "hiringOrganization": {"@type": "Organization", "name": "Acme Corp"},
+   "jobLocation": {"@type": "Place", "address": {"@type": "PostalAddress", "addressLocality": "Bengaluru"}},
+   "qualifications": "3+ years of experience"}
+]}
+</script></head>
+<body><nav>Home Jobs</nav><main><h1>Backend Engineer</h1><p>Ignored, the JSON-LD wins.</p></main></body></html>`
+
+const plainPage = `<html><head><title>Data Engineer - Globex</title><style>p { color: red }</style></head>
+<body>
+  <header>Globex careers</header>
+  <nav><a href="/">Home</a></nav>
+  <article>
+    <h2>About the role</h2>
+    <p>You will   build data pipelines
+       with Spark.</p>
+    <ul><li>Airflow</li><li>BigQuery</li></ul>
+    <script>track()</script>
+  </article>
+  <footer>Copyright Globex</footer>
+</body></html>`
+
+func newTestFetcher(opts Options) *Fetcher {
+	opts.AllowPrivateHosts = true
+	return NewFetcher(opts)
+}
+
+func TestFetchReadsTheJSONLDJobPosting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(jsonLDPage))
+	}))
+	defer srv.Close()
+
+	posting, err := newTestFetcher(Options{}).Fetch(context.Background(), srv.URL+"/jobs/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !posting.Structured || posting.Title != "Backend Engineer" || posting.Company != "Acme Corp" || posting.Location != "Bengaluru" {
+		t.Errorf("unexpected posting %+v", posting)
+	}
+	want := "Build Go services.\n- Kubernetes\n- PostgreSQL\n\n3+ years of experience"
+	if posting.Description != want {
+		t.Errorf("expected the description %q, got %q", want, posting.Description)
+	}
+	if posting.URL != srv.URL+"/jobs/1" {
+		t.Errorf("unexpected url %q", posting.URL)
+	}
+}
+
+func TestFetchExtractsTheMainTextWithoutJSONLD(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(plainPage))
+	}))
+	defer srv.Close()
+
+	posting, err := newTestFetcher(Options{}).Fetch(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "About the role\nYou will build data pipelines with Spark.\n- Airflow\n- BigQuery"
+	if posting.Structured || posting.Title != "Data Engineer - Globex" || posting.Description != want {
+		t.Errorf("unexpected posting %+v", posting)
+	}
+	if text := posting.Text(); !strings.HasPrefix(text, "Data Engineer - Globex\n\nAbout the role") {
+		t.Errorf("unexpected text %q", text)
+	}
+}
+
+func TestFetchLimits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			w.Write([]byte("<html><body><p>" + strings.Repeat("kubernetes ", 1000) + "</p></body></html>"))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte(plainPage))
+		case "/pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write([]byte("%PDF-1.7"))
+		case "/empty":
+			w.Write([]byte("<html><body><script>app()</script></body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	posting, err := newTestFetcher(Options{MaxBytes: 1024}).Fetch(context.Background(), srv.URL+"/large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !posting.Truncated || len(posting.Description) > 1024 {
+		t.Errorf("expected the page to be cut at 1KB, got %d bytes (truncated %v)", len(posting.Description), posting.Truncated)
+	}
+
+	if _, err := newTestFetcher(Options{Timeout: 50 * time.Millisecond}).Fetch(context.Background(), srv.URL+"/slow"); err == nil {
+		t.Error("expected the slow page to time out")
+	}
+
+	fetcher := newTestFetcher(Options{})
+	for path, want := range map[string]error{"/pdf": ErrNotHTML, "/empty": ErrNoDescription} {
+		if _, err := fetcher.Fetch(context.Background(), srv.URL+path); !errors.Is(err, want) {
+			t.Errorf("%s: expected %v, got %v", path, want, err)
+		}
+	}
+	if _, err := fetcher.Fetch(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Error("expected a missing page to fail")
+	}
+}
+
+func TestFetchRefusesUnsupportedAndPrivateURLs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(plainPage))
+	}))
+	defer srv.Close()
+
+	if _, err := NewFetcher(Options{}).Fetch(context.Background(), srv.URL); !errors.Is(err, ErrForbiddenHost) {
+		t.Errorf("expected the loopback server to be refused, got %v", err)
+	}
+	for _, url := range []string{"file:///etc/passwd", "ftp://example.com/job", "not a url"} {
+		if _, err := NewFetcher(Options{}).Fetch(context.Background(), url); !errors.Is(err, ErrUnsupportedURL) {
+			t.Errorf("%s: expected ErrUnsupportedURL, got %v", url, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/events"
+	"github.com/sounishnath003/customgo-mailer-service/internal/metrics"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"github.com/sounishnath003/customgo-mailer-service/internal/workerpool"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BULK_EMAIL sends the same referral email to every pending recipient of a bulk email job.
+const BULK_EMAIL = "BULK_EMAIL"
+
+// SEND_EMAILS is the only stage of the bulk email jobs.
+const SEND_EMAILS workerpool.Stage = "SEND_EMAILS"
+
+// BulkEmailPayload carries the email to be sent to the recipients of a `bulk_email_jobs` document.
+type BulkEmailPayload struct {
+	BulkJobID        primitive.ObjectID `json:"bulkJobId" bson:"bulkJobId"`
+	From             string             `json:"from" bson:"from"`
+	Subject          string             `json:"subject" bson:"subject"`
+	Body             string             `json:"body" bson:"body"`
+	TailoredResumeID string             `json:"tailoredResumeId" bson:"tailoredResumeId"`
+	// Draft is the AI draft the email was written from, stored on every email sent.
+	Draft *repository.SentDraft `json:"draft,omitempty" bson:"draft,omitempty"`
+}
+
+// EnqueueBulkEmail submits a bulk email job to the job queue, it's sent at `runAt`, or right away when zero.
+// The queued job shares its ID with the bulk email job, so both are tracked under the same ID.
+func EnqueueBulkEmail(co *core.Core, payload BulkEmailPayload, runAt time.Time) error {
+	_, err := workerpool.Enqueue(co, workerpool.EnqueueRequest[BulkEmailPayload]{
+		ID:        payload.BulkJobID,
+		Kind:      BULK_EMAIL,
+		Stage:     SEND_EMAILS,
+		UserEmail: payload.From,
+		Payload:   payload,
+		Priority:  repository.JOB_PRIORITY_NORMAL,
+		RunAt:     runAt,
+	})
+	return err
+}
+
+func registerBulkEmail(r *workerpool.Registry, co *core.Core) {
+	workerpool.Register(r, workerpool.Definition[BulkEmailPayload]{
+		Kind:  BULK_EMAIL,
+		Start: SEND_EMAILS,
+		Stages: map[workerpool.Stage]workerpool.StageDefinition[BulkEmailPayload]{
+			SEND_EMAILS: {
+				Next: []workerpool.Stage{workerpool.DONE},
+				Run: func(ctx context.Context, job *repository.JobQueue, payload *BulkEmailPayload) (workerpool.Stage, error) {
+					return sendBulkEmails(ctx, co, payload)
+				},
+			},
+		},
+		OnFailed: func(ctx context.Context, job *repository.JobQueue, payload *BulkEmailPayload, err error) {
+			if failErr := co.DB.FailBulkEmailJob(payload.BulkJobID, err.Error()); failErr != nil {
+				co.Lo.Error("[WORKERPOOL]: failed to mark the bulk email job failed", "bulkJobId", payload.BulkJobID.Hex(), "error", failErr)
+			}
+		},
+	})
+}
+
+// sendBulkEmails sends the email to every recipient of the bulk job still PENDING.
+// Recipients skipped by the outreach policy, or already sent, are left untouched.
+func sendBulkEmails(ctx context.Context, co *core.Core, payload *BulkEmailPayload) (workerpool.Stage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+	startedAt := time.Now()
+
+	bulkJob, err := co.DB.GetBulkEmailJob(payload.BulkJobID)
+	if err != nil {
+		return "", fmt.Errorf("unable to load bulk email job %s: %w", payload.BulkJobID.Hex(), err)
+	}
+	co.DB.StartBulkEmailJob(bulkJob.ID)
+
+	// Every progress update is published on the event bus for live subscribers.
+	progressEvent := events.Event{
+		UserEmail: payload.From,
+		JobID:     bulkJob.ID.Hex(),
+		JobKind:   BULK_EMAIL,
+		Total:     bulkJob.TotalRecipients,
+	}
+
+	localDst, err := co.PrepareResumeAttachment(ctx, payload.From, payload.TailoredResumeID)
+	if err != nil {
+		// The stage is retried, the bulk job is only failed once the retries are used up.
+		co.Lo.Error("failed to prepare resume for bulk send", "error", err)
+		return "", fmt.Errorf("failed to prepare resume: %w", err)
+	}
+	defer os.Remove(localDst)
+
+	sentCount := bulkJob.SentCount
+	for _, recipient := range bulkJob.Recipients {
+		// Cancelled by a shutdown or a lost lease, the recipients left PENDING are sent when the job resumes.
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		progressEvent.Type = events.BULK_EMAIL_PROGRESS
+		progressEvent.Recipient = recipient.Email
+		progressEvent.Error = recipient.Reason
+
+		if recipient.Status != "PENDING" {
+			if recipient.Status == "SKIPPED" {
+				progressEvent.Status = recipient.Status
+				co.Events.Publish(progressEvent)
+				metrics.BulkEmailRecipientsTotal.WithLabelValues(recipient.Status).Inc()
+			}
+			continue
+		}
+
+		// Construct To: [recipient, sender]
+		currentTo := []string{recipient.Email, payload.From}
+
+		// Send
+		err := co.InvokeSendMailWithAttachment(
+			payload.From,
+			currentTo,
+			payload.Subject,
+			payload.Body,
+			payload.TailoredResumeID,
+			localDst,
+			payload.Draft,
+		)
+
+		if err != nil {
+			co.Lo.Error("failed to send bulk email", "to", recipient.Email, "error", err)
+			// We log error but continue sending to others
+			progressEvent.Status = "FAILED"
+			progressEvent.Error = err.Error()
+			co.DB.UpdateBulkEmailRecipient(bulkJob.ID, recipient.Email, "FAILED", err.Error())
+		} else {
+			co.Lo.Info("bulk email sent", "to", recipient.Email)
+			sentCount++
+			co.DB.UpdateBulkEmailJobProgress(bulkJob.ID, sentCount)
+			co.DB.UpdateBulkEmailRecipient(bulkJob.ID, recipient.Email, "SENT", "")
+			progressEvent.Status = "SENT"
+		}
+		progressEvent.Sent = sentCount
+		co.Events.Publish(progressEvent)
+		metrics.BulkEmailRecipientsTotal.WithLabelValues(progressEvent.Status).Inc()
+
+		// Small delay to be nice to SMTP
+		time.Sleep(500 * time.Millisecond)
+	}
+	co.DB.CompleteBulkEmailJob(bulkJob.ID)
+	metrics.Since(metrics.BulkEmailJobDuration, startedAt)
+
+	progressEvent.Type = events.BULK_EMAIL_COMPLETED
+	progressEvent.Status = "COMPLETED"
+	progressEvent.Recipient = ""
+	progressEvent.Error = ""
+	co.Events.Publish(progressEvent)
+
+	return workerpool.DONE, nil
+}
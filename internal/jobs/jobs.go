@@ -0,0 +1,14 @@
+// Package jobs defines the background job kinds processed by the worker pool.
+// Every kind registers its stage graph and typed payload with the worker pool registry.
+package jobs
+
+import (
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/workerpool"
+)
+
+// Register adds every job kind of the service to the registry.
+func Register(r *workerpool.Registry, co *core.Core) {
+	registerResumeProcessing(r, co)
+	registerBulkEmail(r, co)
+}
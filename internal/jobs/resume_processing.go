@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"github.com/sounishnath003/customgo-mailer-service/internal/workerpool"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RESUME_PROCESSING extracts the uploaded resume content and summarizes it into the user's profile.
+const RESUME_PROCESSING = "RESUME_PROCESSING"
+
+// Stages of the resume processing pipeline.
+const (
+	EXTRACT_CONTENT          workerpool.Stage = "EXTRACT_CONTENT"
+	GENERATE_PROFILE_SUMMARY workerpool.Stage = "GENERATE_PROFILE_SUMMARY"
+	CONVERT_TO_JSON          workerpool.Stage = "CONVERT_TO_JSON"
+	UPDATE_RESUME_DOCUMENT   workerpool.Stage = "UPDATE_RESUME_DOCUMENT"
+	EMAIL_NOTIFICATION       workerpool.Stage = "EMAIL_NOTIFICATION"
+)
+
+// ResumePayload is carried by the resume processing jobs from one stage to the next.
+type ResumePayload struct {
+	ResumeURL        string `json:"resumeUrl" bson:"resumeUrl"`
+	ExtractedContent string `json:"extractedContent" bson:"extractedContent"`
+	Summary          string `json:"summary" bson:"summary"`
+	// DiscardUserEdits parses the resume again even when the user corrected its structured information.
+	DiscardUserEdits bool `json:"discardUserEdits,omitempty" bson:"discardUserEdits,omitempty"`
+}
+
+// EnqueueResumeProcessing submits the uploaded resume to the job queue for processing.
+// The structured information the user corrected is kept, unless `discardUserEdits`.
+func EnqueueResumeProcessing(co *core.Core, userEmailAddress, resumeGCSPath string, discardUserEdits bool) (primitive.ObjectID, error) {
+	return workerpool.Enqueue(co, workerpool.EnqueueRequest[ResumePayload]{
+		Kind:      RESUME_PROCESSING,
+		Stage:     EXTRACT_CONTENT,
+		UserEmail: userEmailAddress,
+		Payload:   ResumePayload{ResumeURL: resumeGCSPath, DiscardUserEdits: discardUserEdits},
+		// The user is waiting on it to fill in their profile.
+		Priority: repository.JOB_PRIORITY_HIGH,
+	})
+}
+
+func registerResumeProcessing(r *workerpool.Registry, co *core.Core) {
+	workerpool.Register(r, workerpool.Definition[ResumePayload]{
+		Kind:  RESUME_PROCESSING,
+		Start: EXTRACT_CONTENT,
+		Stages: map[workerpool.Stage]workerpool.StageDefinition[ResumePayload]{
+			EXTRACT_CONTENT: {
+				Next: []workerpool.Stage{GENERATE_PROFILE_SUMMARY},
+				Run: func(ctx context.Context, job *repository.JobQueue, payload *ResumePayload) (workerpool.Stage, error) {
+					// extract content from resume
+					content, err := co.ExtractResumeContentLLM(ctx, job.UserEmailAddress, payload.ResumeURL)
+					if err != nil {
+						return "", err
+					}
+					payload.ExtractedContent = content
+					return GENERATE_PROFILE_SUMMARY, nil
+				},
+			},
+			GENERATE_PROFILE_SUMMARY: {
+				Next: []workerpool.Stage{CONVERT_TO_JSON},
+				Run: func(ctx context.Context, job *repository.JobQueue, payload *ResumePayload) (workerpool.Stage, error) {
+					summary, err := co.GenerateProfileSummaryLLM(ctx, job.UserEmailAddress, payload.ExtractedContent)
+					if err != nil {
+						return "", err
+					}
+					payload.Summary = summary
+					return CONVERT_TO_JSON, nil
+				},
+			},
+			CONVERT_TO_JSON: {
+				Next: []workerpool.Stage{UPDATE_RESUME_DOCUMENT},
+				Run: func(ctx context.Context, job *repository.JobQueue, payload *ResumePayload) (workerpool.Stage, error) {
+					stored, err := co.DB.GetResumeInformation(job.UserEmailAddress)
+					if err != nil && !errors.Is(err, repository.ErrResumeInformationNotFound) {
+						return "", err
+					}
+					info, err := co.ReparseResumeInformation(ctx, stored, job.UserEmailAddress, payload.ExtractedContent, payload.Summary, payload.DiscardUserEdits)
+					if err != nil {
+						return "", err
+					}
+					if err := co.DB.UpsertResumeInformation(info); err != nil {
+						return "", err
+					}
+					return UPDATE_RESUME_DOCUMENT, nil
+				},
+			},
+			UPDATE_RESUME_DOCUMENT: {
+				Next: []workerpool.Stage{EMAIL_NOTIFICATION},
+				Run: func(ctx context.Context, job *repository.JobQueue, payload *ResumePayload) (workerpool.Stage, error) {
+					u, err := co.DB.GetProfileByEmail(job.UserEmailAddress)
+					if err != nil {
+						return "", err
+					}
+					u.ExtractedContent = payload.ExtractedContent
+					u.ProfileSummary = payload.Summary
+					if err = co.DB.UpdateProfileInformation(u); err != nil {
+						return "", err
+					}
+					return EMAIL_NOTIFICATION, nil
+				},
+			},
+			EMAIL_NOTIFICATION: {
+				Next: []workerpool.Stage{workerpool.DONE},
+				Run: func(ctx context.Context, job *repository.JobQueue, payload *ResumePayload) (workerpool.Stage, error) {
+					// The profile is already updated, a notification which can't be sent doesn't fail the job.
+					err := co.NotifyUser(core.Notification{
+						UserEmail: job.UserEmailAddress,
+						Kind:      core.RESUME_PROCESSING_COMPLETED,
+						JobID:     job.ID,
+						Data:      map[string]any{"Summary": truncateText(payload.Summary, notificationSummaryLength)},
+					})
+					if err != nil {
+						co.Lo.Error("[WORKERPOOL]: failed to notify the user", "userEmail", job.UserEmailAddress, "stage", job.Stage, "error", err)
+					}
+					return workerpool.DONE, nil
+				},
+			},
+		},
+		OnFailed: func(ctx context.Context, job *repository.JobQueue, payload *ResumePayload, err error) {
+			// The error may carry the model, MongoDB or storage internals, the user only gets its reason.
+			co.Lo.Error("[WORKERPOOL]: resume processing failed", "userEmail", job.UserEmailAddress, "jobId", job.ID.Hex(), "stage", job.Stage, "error", err)
+			notifyErr := co.NotifyUser(core.Notification{
+				UserEmail: job.UserEmailAddress,
+				Kind:      core.RESUME_PROCESSING_FAILED,
+				JobID:     job.ID,
+				Data:      map[string]any{"Reason": resumeFailureReason(workerpool.Stage(job.Stage), err)},
+			})
+			if notifyErr != nil {
+				co.Lo.Error("[WORKERPOOL]: failed to notify the user", "userEmail", job.UserEmailAddress, "stage", job.Stage, "error", notifyErr)
+			}
+		},
+	})
+}
+
+// resumeFailureReason tells the user why their resume couldn't be processed, without the internals of the error.
+func resumeFailureReason(stage workerpool.Stage, err error) string {
+	switch {
+	case errors.Is(err, core.ErrLLMQuotaExceeded):
+		return "You have used up your AI usage quota, try again once it resets."
+	case errors.Is(err, core.ErrInvalidResumeJSON):
+		return "We couldn't make out the sections of your resume, a simpler layout usually helps."
+	case stage == EXTRACT_CONTENT:
+		return "We couldn't read the content of your resume file, make sure it's a readable PDF."
+	default:
+		return "An internal error occurred on our side."
+	}
+}
+
+// notificationSummaryLength is how much of the profile summary goes into the notification email.
+const notificationSummaryLength = 500
+
+// truncateText cuts the text down to `limit` runes, marking the cut with an ellipsis.
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
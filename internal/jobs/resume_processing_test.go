@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/workerpool"
+)
+
+func TestResumeFailureReasonHidesTheInternalError(t *testing.T) {
+	internal := "rpc error: code = PermissionDenied desc = gs://referrer-resumes/jane@example.com/resume.pdf"
+
+	for name, tc := range map[string]struct {
+		stage workerpool.Stage
+		err   error
+		want  string
+	}{
+		"quota":           {CONVERT_TO_JSON, &core.LLMQuotaExceededError{Period: "daily"}, "quota"},
+		"invalid JSON":    {CONVERT_TO_JSON, &core.ResumeConversionError{Err: &core.ResumeValidationError{Problems: []string{internal}}}, "sections"},
+		"unreadable file": {EXTRACT_CONTENT, errors.New(internal), "readable PDF"},
+		"anything else":   {UPDATE_RESUME_DOCUMENT, fmt.Errorf("mongo: %s", internal), "internal error"},
+	} {
+		reason := resumeFailureReason(tc.stage, tc.err)
+		if !strings.Contains(reason, tc.want) {
+			t.Errorf("%s: expected the reason to mention %q, got %q", name, tc.want, reason)
+		}
+		if strings.Contains(reason, "gs://") || strings.Contains(reason, "rpc error") {
+			t.Errorf("%s: the reason leaks the internal error: %q", name, reason)
+		}
+	}
+}
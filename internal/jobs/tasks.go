@@ -0,0 +1,194 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"github.com/sounishnath003/customgo-mailer-service/internal/workerpool"
+)
+
+const (
+	// staleFileAge is how old a local file gets before the storage sweep deletes it.
+	staleFileAge = 24 * time.Hour
+	// followUpAfter is how long after an email was sent its sender gets reminded to follow up.
+	followUpAfter = 7 * 24 * time.Hour
+	// followUpWindow bounds how far back the reminders go, older emails are never reminded.
+	followUpWindow = 30 * 24 * time.Hour
+	// followUpBatchSize caps the emails reminded in a single run, the next runs pick up the rest.
+	followUpBatchSize = 500
+)
+
+// RegisterTasks adds the periodic tasks of the service to the scheduler.
+func RegisterTasks(s *workerpool.Scheduler, co *core.Core) {
+	s.Add(workerpool.PeriodicTask{
+		Name:        "contacts-sync",
+		Description: "Imports the recipients of the AI drafts into the users' networks.",
+		Schedule:    "15 * * * *",
+		Timeout:     15 * time.Minute,
+		Run:         func(ctx context.Context) error { return syncContacts(ctx, co) },
+	})
+	s.Add(workerpool.PeriodicTask{
+		Name:        "storage-sweep",
+		Description: "Deletes the downloaded resumes and rendered PDFs older than a day.",
+		Schedule:    "30 3 * * *",
+		Timeout:     5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			removed, err := co.SweepStaleFiles(staleFileAge)
+			co.Lo.Info("storage sweep done", "removed", removed)
+			return err
+		},
+	})
+	s.Add(workerpool.PeriodicTask{
+		Name:        "weekly-digest",
+		Description: "Emails every user a summary of their activity over the last week.",
+		Schedule:    "0 9 * * 1",
+		Timeout:     30 * time.Minute,
+		Run:         func(ctx context.Context) error { return sendWeeklyDigests(ctx, co) },
+	})
+	s.Add(workerpool.PeriodicTask{
+		Name:        "follow-up-reminders",
+		Description: "Reminds the users to follow up on the emails left unanswered for a week.",
+		Schedule:    "0 * * * *",
+		Timeout:     15 * time.Minute,
+		Run:         func(ctx context.Context) error { return remindFollowUps(ctx, co) },
+	})
+	s.Add(workerpool.PeriodicTask{
+		Name:        "llm-cache-trim",
+		Description: "Evicts the oldest cached LLM answers beyond the cache size limit.",
+		Schedule:    "45 * * * *",
+		Timeout:     5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			evicted, err := co.TrimLLMCache()
+			co.Lo.Info("llm cache trim done", "evicted", evicted)
+			return err
+		},
+	})
+}
+
+// syncContacts runs the contacts sync of every user.
+func syncContacts(ctx context.Context, co *core.Core) error {
+	emails, err := co.DB.ListUserEmails(false)
+	if err != nil {
+		return err
+	}
+
+	imported := 0
+	var errs []error
+	for _, email := range emails {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		count, err := co.DB.SyncContactsFromDrafts(email)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", email, err))
+			continue
+		}
+		imported += count
+	}
+	co.Lo.Info("contacts sync done", "users", len(emails), "imported", imported)
+	return errors.Join(errs...)
+}
+
+// sendWeeklyDigests emails their weekly activity to the users receiving emails, idle users are skipped.
+func sendWeeklyDigests(ctx context.Context, co *core.Core) error {
+	emails, err := co.DB.ListUserEmails(true)
+	if err != nil {
+		return err
+	}
+
+	since := time.Now().Add(-7 * 24 * time.Hour)
+	sent := 0
+	var errs []error
+	for _, email := range emails {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		u, err := co.DB.GetProfileByEmail(email)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", email, err))
+			continue
+		}
+		summary, err := co.DB.GetActivitySummary(email, u.ID.Hex(), since)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", email, err))
+			continue
+		}
+		if summary.IsEmpty() {
+			continue
+		}
+
+		err = co.NotifyUser(core.Notification{
+			UserEmail: email,
+			Kind:      core.WEEKLY_DIGEST,
+			Data: map[string]any{
+				"EmailsSent":      summary.EmailsSent,
+				"DraftsCreated":   summary.DraftsCreated,
+				"TailoredResumes": summary.TailoredResumes,
+				"ContactsAdded":   summary.ContactsAdded,
+			},
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", email, err))
+			continue
+		}
+		sent++
+	}
+	co.Lo.Info("weekly digests done", "users", len(emails), "sent", sent)
+	return errors.Join(errs...)
+}
+
+// remindFollowUps notifies the senders of the emails due for a follow up, one notification per sender.
+// The emails are flagged as reminded even when the sender turned the emails off, so they're never reconsidered.
+func remindFollowUps(ctx context.Context, co *core.Core) error {
+	now := time.Now()
+	mails, err := co.DB.ListEmailsDueForFollowUp(now.Add(-followUpWindow), now.Add(-followUpAfter), followUpBatchSize)
+	if err != nil {
+		return err
+	}
+
+	bySender := make(map[string][]*repository.ReferralMailbox)
+	senders := make([]string, 0)
+	for _, mail := range mails {
+		if _, seen := bySender[mail.From]; !seen {
+			senders = append(senders, mail.From)
+		}
+		bySender[mail.From] = append(bySender[mail.From], mail)
+	}
+
+	var errs []error
+	for _, sender := range senders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		due := bySender[sender]
+		err := co.NotifyUser(core.Notification{
+			UserEmail: sender,
+			Kind:      core.FOLLOW_UPS_DUE,
+			Data: map[string]any{
+				"Emails":    due,
+				"AfterDays": int(followUpAfter.Hours() / 24),
+			},
+		})
+		if err != nil {
+			// Left unflagged, the next run tries again.
+			errs = append(errs, fmt.Errorf("%s: %w", sender, err))
+			continue
+		}
+
+		uuids := make([]string, 0, len(due))
+		for _, mail := range due {
+			uuids = append(uuids, mail.Uuid)
+		}
+		if err := co.DB.MarkFollowUpReminded(uuids); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sender, err))
+		}
+	}
+	co.Lo.Info("follow-up reminders done", "emails", len(mails), "senders", len(senders))
+	return errors.Join(errs...)
+}
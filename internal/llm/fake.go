@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrFakeScriptExhausted is returned once every scripted reply of a fake was consumed.
+var ErrFakeScriptExhausted = errors.New("fake llm script exhausted")
+
+// FakeReply is a scripted answer of the fake provider.
+type FakeReply struct {
+	Text  string
+	Err   error
+	Usage Usage
+}
+
+// Fake is a deterministic provider for the tests and the local development.
+// It answers with the scripted replies in order, then with Respond when set.
+type Fake struct {
+	// Respond answers once the script is exhausted, the fake fails with ErrFakeScriptExhausted when nil.
+	Respond func(req Request) FakeReply
+	// Caps are the capabilities the fake claims, to exercise the code paths of the other backends.
+	Caps Capabilities
+
+	mu     sync.Mutex
+	script []FakeReply
+	calls  []Request
+}
+
+// NewFake creates a fake answering with the replies in order.
+func NewFake(replies ...FakeReply) *Fake {
+	return &Fake{script: replies}
+}
+
+// NewEchoFake creates a fake answering every request with a short echo of its prompt, for running the service without a model.
+func NewEchoFake() *Fake {
+	return &Fake{Respond: echoReply, Caps: Capabilities{FileInput: true}}
+}
+
+func (f *Fake) Name() string { return BACKEND_FAKE }
+
+func (f *Fake) Capabilities() Capabilities { return f.Caps }
+
+func (f *Fake) Generate(ctx context.Context, req Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, req)
+	var reply FakeReply
+	switch {
+	case len(f.script) > 0:
+		reply, f.script = f.script[0], f.script[1:]
+	case f.Respond != nil:
+		reply = f.Respond(req)
+	default:
+		f.mu.Unlock()
+		return nil, ErrFakeScriptExhausted
+	}
+	f.mu.Unlock()
+
+	if reply.Err != nil {
+		return nil, reply.Err
+	}
+	return &Response{Text: reply.Text, Model: BACKEND_FAKE, Usage: reply.Usage}, nil
+}
+
+// GenerateStream answers like Generate, handing the reply over word by word.
+func (f *Fake) GenerateStream(ctx context.Context, req Request, onChunk StreamFunc) (*Response, error) {
+	res, err := f.Generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range strings.SplitAfter(res.Text, " ") {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if chunk == "" {
+			continue
+		}
+		if err := onChunk(chunk); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Calls returns the requests received so far, in order.
+func (f *Fake) Calls() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.calls...)
+}
+
+// echoReply answers with the beginning of the prompt, the token counts are approximated by the words.
+func echoReply(req Request) FakeReply {
+	var prompt strings.Builder
+	for _, p := range req.Parts {
+		if p.IsFile() {
+			prompt.WriteString("[file] ")
+			continue
+		}
+		prompt.WriteString(p.Text)
+		prompt.WriteString(" ")
+	}
+	words := strings.Fields(prompt.String())
+	promptTokens := len(words)
+	if len(words) > 24 {
+		words = words[:24]
+	}
+	text := fmt.Sprintf("[fake] %s", strings.Join(words, " "))
+	completionTokens := len(strings.Fields(text))
+
+	return FakeReply{
+		Text: text,
+		Usage: Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+	}
+}
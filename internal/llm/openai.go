@@ -0,0 +1,323 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// openAITimeout bounds a single call, local models on a CPU are slow.
+const openAITimeout = 120 * time.Second
+
+// maxOpenAIErrorBody caps how much of an error response ends up in the error message.
+const maxOpenAIErrorBody = 2048
+
+// OpenAI generates content through the chat completions API of any OpenAI-compatible endpoint:
+// OpenAI itself, Ollama (`http://localhost:11434/v1`), a llama.cpp server (`http://localhost:8080/v1`)...
+type OpenAI struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	opts       OpenAIOptions
+	httpClient *http.Client
+}
+
+// OpenAIOptions tells what the OpenAI-compatible endpoint supports beyond plain text.
+type OpenAIOptions struct {
+	// StructuredOutput sends the ResponseSchema as a `json_schema` response format.
+	StructuredOutput bool
+	// FileInput sends the files other than images, like the resume PDF, as `file` content parts.
+	FileInput bool
+}
+
+// openAIHost is the host of OpenAI's own API.
+const openAIHost = "api.openai.com"
+
+// DefaultOpenAIOptions are the options of the endpoint at `baseURL`. OpenAI itself supports both,
+// the self-hosted servers (Ollama, llama.cpp...) mostly support neither, or only with some models.
+func DefaultOpenAIOptions(baseURL string) OpenAIOptions {
+	u, err := url.Parse(baseURL)
+	hosted := err == nil && strings.EqualFold(u.Hostname(), openAIHost)
+	return OpenAIOptions{StructuredOutput: hosted, FileInput: hosted}
+}
+
+// NewOpenAI creates a client for the endpoint, the API key is optional for the local servers.
+func NewOpenAI(baseURL, apiKey, model string, opts OpenAIOptions) (*OpenAI, error) {
+	if baseURL == "" {
+		return nil, errors.New("the openai backend needs a base url")
+	}
+	if model == "" {
+		return nil, errors.New("the openai backend needs a model name")
+	}
+	return &OpenAI{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		opts:       opts,
+		httpClient: &http.Client{Timeout: openAITimeout},
+	}, nil
+}
+
+func (o *OpenAI) Name() string { return BACKEND_OPENAI }
+
+// Capabilities of the OpenAI-compatible endpoint as configured, files must be inlined.
+func (o *OpenAI) Capabilities() Capabilities {
+	return Capabilities{StructuredOutput: o.opts.StructuredOutput, FileInput: o.opts.FileInput}
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+	File     *openAIFile     `json:"file,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAIFile struct {
+	Filename string `json:"filename"`
+	FileData string `json:"file_data"`
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIJSONSchema struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+	Strict bool           `json:"strict"`
+}
+
+type openAIChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	Temperature    *float32              `json:"temperature,omitempty"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	StreamOptions  *openAIStreamOptions  `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u openAIUsage) usage() Usage {
+	return Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
+
+type openAIChatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage openAIUsage `json:"usage"`
+}
+
+type openAIChatChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+func (o *OpenAI) Generate(ctx context.Context, req Request) (*Response, error) {
+	res, err := o.chatCompletion(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var out openAIChatResponse
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("unable to decode the chat completion: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return nil, ErrEmptyResponse
+	}
+
+	var text strings.Builder
+	for _, choice := range out.Choices {
+		text.WriteString(choice.Message.Content)
+	}
+	model := out.Model
+	if model == "" {
+		model = o.model
+	}
+	return &Response{Text: text.String(), Model: model, Usage: out.Usage.usage()}, nil
+}
+
+// GenerateStream streams the answer as server-sent chat completion chunks, the usage comes with the last one.
+func (o *OpenAI) GenerateStream(ctx context.Context, req Request, onChunk StreamFunc) (*Response, error) {
+	res, err := o.chatCompletion(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var text strings.Builder
+	out := &Response{Model: o.model}
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIChatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("unable to decode the chat completion chunk: %w", err)
+		}
+		if chunk.Model != "" {
+			out.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			out.Usage = chunk.Usage.usage()
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			text.WriteString(choice.Delta.Content)
+			if err := onChunk(choice.Delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if text.Len() == 0 {
+		return nil, ErrEmptyResponse
+	}
+	out.Text = text.String()
+	return out, nil
+}
+
+// chatCompletion posts the request to the chat completions API and checks the response status.
+func (o *OpenAI) chatCompletion(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	content, err := openAIContent(req.Parts, o.opts.FileInput)
+	if err != nil {
+		return nil, err
+	}
+
+	chatReq := openAIChatRequest{
+		Model:       o.model,
+		Messages:    []openAIMessage{{Role: "user", Content: content}},
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxOutputTokens,
+	}
+	if req.ResponseSchema != nil && o.opts.StructuredOutput {
+		name := req.ResponseSchema.Name
+		if name == "" {
+			name = "response"
+		}
+		chatReq.ResponseFormat = &openAIResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &openAIJSONSchema{Name: name, Schema: req.ResponseSchema.JSONSchema(), Strict: true},
+		}
+	}
+	if stream {
+		chatReq.Stream = true
+		chatReq.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	res, err := o.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, maxOpenAIErrorBody))
+		return nil, fmt.Errorf("chat completion failed with status %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return res, nil
+}
+
+// openAIContent sends a text-only prompt as a plain string, which every compatible server understands,
+// and falls back to the content parts array when files are attached. Files other than images need `fileInput`.
+func openAIContent(parts []Part, fileInput bool) (any, error) {
+	hasFiles := false
+	for _, p := range parts {
+		if p.IsFile() {
+			hasFiles = true
+			break
+		}
+	}
+
+	if !hasFiles {
+		texts := make([]string, 0, len(parts))
+		for _, p := range parts {
+			texts = append(texts, p.Text)
+		}
+		return strings.Join(texts, "\n\n"), nil
+	}
+
+	content := make([]openAIContentPart, 0, len(parts))
+	for _, p := range parts {
+		switch {
+		case p.FileURI != "":
+			return nil, fmt.Errorf("%w: remote file %s must be inlined", ErrUnsupportedPart, p.FileURI)
+		case len(p.Data) > 0:
+			dataURL := fmt.Sprintf("data:%s;base64,%s", p.MIMEType, base64.StdEncoding.EncodeToString(p.Data))
+			if strings.HasPrefix(p.MIMEType, "image/") {
+				content = append(content, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: dataURL}})
+			} else if !fileInput {
+				return nil, fmt.Errorf("%w: the endpoint isn't configured to read %s files", ErrUnsupportedPart, p.MIMEType)
+			} else {
+				content = append(content, openAIContentPart{Type: "file", File: &openAIFile{Filename: "attachment", FileData: dataURL}})
+			}
+		default:
+			content = append(content, openAIContentPart{Type: "text", Text: p.Text})
+		}
+	}
+	return content, nil
+}
@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIGenerateSendsTheChatCompletionAndReportsUsage(t *testing.T) {
+	var got openAIChatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("unexpected authorization %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(`{"model":"llama3.1","choices":[{"message":{"content":"Hello there"}}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`))
+	}))
+	defer srv.Close()
+
+	provider, err := NewOpenAI(srv.URL+"/v1/", "secret", "llama3.1", OpenAIOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := provider.Generate(context.Background(), Request{Parts: []Part{Text("first"), Text("second")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Model != "llama3.1" || len(got.Messages) != 1 || got.Messages[0].Content != "first\n\nsecond" {
+		t.Errorf("unexpected request %+v", got)
+	}
+	if res.Text != "Hello there" {
+		t.Errorf("expected the completion text, got %q", res.Text)
+	}
+	if res.Usage != (Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}) {
+		t.Errorf("unexpected usage %+v", res.Usage)
+	}
+}
+
+func TestOpenAIGenerateStreamHandsOverTheChunks(t *testing.T) {
+	var got openAIChatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"model\":\"llama3.1\",\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n"))
+		w.Write([]byte(": keep-alive\n\n"))
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\" there\"}}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":2,\"total_tokens\":14}}\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	provider, err := NewOpenAI(srv.URL+"/v1", "", "llama3.1", OpenAIOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []string
+	res, err := GenerateStream(context.Background(), provider, Request{Parts: []Part{Text("hi")}}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Stream || got.StreamOptions == nil || !got.StreamOptions.IncludeUsage {
+		t.Errorf("expected a streaming request with the usage, got %+v", got)
+	}
+	if len(chunks) != 2 || chunks[0] != "Hello" || chunks[1] != " there" {
+		t.Errorf("unexpected chunks %q", chunks)
+	}
+	if res.Text != "Hello there" || res.Model != "llama3.1" {
+		t.Errorf("unexpected response %+v", res)
+	}
+	if res.Usage != (Usage{PromptTokens: 12, CompletionTokens: 2, TotalTokens: 14}) {
+		t.Errorf("unexpected usage %+v", res.Usage)
+	}
+}
+
+func TestGenerateStreamStopsWhenTheReceiverFails(t *testing.T) {
+	stop := errors.New("client gone")
+	fake := NewFake(FakeReply{Text: "one two three"})
+
+	calls := 0
+	_, err := GenerateStream(context.Background(), fake, Request{}, func(chunk string) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("expected the stream to stop after the first chunk, got %v after %d chunks", err, calls)
+	}
+}
+
+func TestOpenAIGenerateRejectsRemoteFiles(t *testing.T) {
+	provider, err := NewOpenAI("http://localhost:1/v1", "", "llama3.1", OpenAIOptions{FileInput: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = provider.Generate(context.Background(), Request{Parts: []Part{FileURI("gs://bucket/resume.pdf", "application/pdf"), Text("extract")}})
+	if !errors.Is(err, ErrUnsupportedPart) {
+		t.Errorf("expected ErrUnsupportedPart, got %v", err)
+	}
+}
+
+func TestFakeRepliesInScriptOrder(t *testing.T) {
+	fake := NewFake(FakeReply{Text: "one"}, FakeReply{Err: ErrEmptyResponse}, FakeReply{Text: "three"})
+	ctx := context.Background()
+
+	if res, err := fake.Generate(ctx, Request{Parts: []Part{Text("a")}}); err != nil || res.Text != "one" {
+		t.Errorf("expected the first reply, got %v, %v", res, err)
+	}
+	if _, err := fake.Generate(ctx, Request{}); !errors.Is(err, ErrEmptyResponse) {
+		t.Errorf("expected the scripted error, got %v", err)
+	}
+	if res, err := fake.Generate(ctx, Request{}); err != nil || res.Text != "three" {
+		t.Errorf("expected the third reply, got %v, %v", res, err)
+	}
+	if _, err := fake.Generate(ctx, Request{}); !errors.Is(err, ErrFakeScriptExhausted) {
+		t.Errorf("expected the script to be exhausted, got %v", err)
+	}
+	if calls := fake.Calls(); len(calls) != 4 || calls[0].Parts[0].Text != "a" {
+		t.Errorf("unexpected recorded calls %+v", calls)
+	}
+}
+
+func TestDefaultOpenAIOptionsAssumeNothingOfTheSelfHostedServers(t *testing.T) {
+	for baseURL, want := range map[string]OpenAIOptions{
+		"https://api.openai.com/v1":   {StructuredOutput: true, FileInput: true},
+		"http://localhost:11434/v1":   {},
+		"http://llama.internal:8080/": {},
+		"not a url\x7f":               {},
+	} {
+		if got := DefaultOpenAIOptions(baseURL); got != want {
+			t.Errorf("%s: expected %+v, got %+v", baseURL, want, got)
+		}
+	}
+
+	enabled := true
+	provider, err := New(context.Background(), Config{Backend: BACKEND_OPENAI, BaseURL: "http://localhost:11434/v1", Model: "llama3.1", StructuredOutput: &enabled})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps := provider.Capabilities(); !caps.StructuredOutput || caps.FileInput {
+		t.Errorf("expected only the structured output to be turned on, got %+v", caps)
+	}
+}
+
+func TestOpenAISendsTheSchemaAndThePDFOnlyWhenConfigured(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = nil
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"{}"}}]}`))
+	}))
+	defer srv.Close()
+
+	schemaReq := Request{Parts: []Part{Text("parse")}, ResponseSchema: &Schema{Name: "resume"}}
+	pdfReq := Request{Parts: []Part{InlineFile([]byte("%PDF"), "application/pdf"), Text("extract")}}
+
+	selfHosted, _ := NewOpenAI(srv.URL, "", "llama3.1", OpenAIOptions{})
+	if _, err := selfHosted.Generate(context.Background(), schemaReq); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["response_format"]; ok {
+		t.Error("expected no response format without structured output")
+	}
+	if _, err := selfHosted.Generate(context.Background(), pdfReq); !errors.Is(err, ErrUnsupportedPart) {
+		t.Errorf("expected the PDF to be rejected with ErrUnsupportedPart, got %v", err)
+	}
+
+	hosted, _ := NewOpenAI(srv.URL, "", "gpt-4o-mini", OpenAIOptions{StructuredOutput: true, FileInput: true})
+	if _, err := hosted.Generate(context.Background(), schemaReq); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["response_format"]; !ok {
+		t.Error("expected the json_schema response format")
+	}
+	if _, err := hosted.Generate(context.Background(), pdfReq); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := json.Marshal(got["messages"]); !strings.Contains(string(content), `"type":"file"`) {
+		t.Errorf("expected the PDF as a file part, got %s", content)
+	}
+}
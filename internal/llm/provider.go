@@ -0,0 +1,156 @@
+// Package llm abstracts the large language model backends behind a single Provider interface.
+// Vertex AI (Gemini), any OpenAI-compatible endpoint (OpenAI, Ollama, llama.cpp server...) and a scripted fake are supported.
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Backends selectable through the configuration.
+const (
+	BACKEND_VERTEX = "vertex"
+	BACKEND_OPENAI = "openai"
+	BACKEND_FAKE   = "fake"
+)
+
+var (
+	// ErrEmptyResponse is returned when the model answered without any content.
+	ErrEmptyResponse = errors.New("empty response from model")
+	// ErrUnsupportedPart is returned when a request holds a part the backend can't send, like a remote file.
+	ErrUnsupportedPart = errors.New("part not supported by the llm backend")
+)
+
+// Part is a piece of a prompt, either text or a file.
+// A file is given by its URI (like `gs://bucket/resume.pdf`) or by its content.
+type Part struct {
+	Text     string
+	FileURI  string
+	Data     []byte
+	MIMEType string
+}
+
+// Text is a text part.
+func Text(text string) Part {
+	return Part{Text: text}
+}
+
+// FileURI is a part referencing a remote file, the backend fetches it.
+func FileURI(uri, mimeType string) Part {
+	return Part{FileURI: uri, MIMEType: mimeType}
+}
+
+// InlineFile is a part carrying the file content.
+func InlineFile(data []byte, mimeType string) Part {
+	return Part{Data: data, MIMEType: mimeType}
+}
+
+// IsFile tells whether the part is a file.
+func (p Part) IsFile() bool {
+	return p.FileURI != "" || len(p.Data) > 0
+}
+
+// Request is a single-turn generation request.
+type Request struct {
+	Parts []Part
+	// Temperature overrides the model default when set.
+	Temperature *float32
+	// MaxOutputTokens caps the completion, the model default when zero.
+	MaxOutputTokens int
+	// ResponseSchema constrains the answer to a JSON document, on the backends with StructuredOutput.
+	// The other backends ignore it, the prompt must describe the expected JSON as well.
+	ResponseSchema *Schema
+}
+
+// Usage is the token usage of a generation.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+// Response is the generated text with its usage.
+type Response struct {
+	Text  string
+	Model string
+	Usage Usage
+}
+
+// Capabilities tells what a backend supports beyond plain text.
+type Capabilities struct {
+	// FileURIs is true when the backend fetches the remote files itself, otherwise files must be inlined.
+	FileURIs bool
+	// StructuredOutput is true when the backend enforces the ResponseSchema.
+	StructuredOutput bool
+	// FileInput is true when the backend reads the files other than images, like the resume PDF.
+	FileInput bool
+}
+
+// Provider generates content from a prompt.
+type Provider interface {
+	// Name identifies the backend, e.g. in the logs.
+	Name() string
+	Capabilities() Capabilities
+	Generate(ctx context.Context, req Request) (*Response, error)
+}
+
+// Config selects and configures a backend.
+type Config struct {
+	// Backend is one of BACKEND_VERTEX (default), BACKEND_OPENAI or BACKEND_FAKE.
+	Backend string
+	Model   string
+
+	// GcpProjectID and GcpLocation configure the Vertex AI backend.
+	GcpProjectID string
+	GcpLocation  string
+
+	// BaseURL and APIKey configure the OpenAI-compatible backend, e.g. `http://localhost:11434/v1` for Ollama.
+	BaseURL string
+	APIKey  string
+	// StructuredOutput and FileInput override what the OpenAI-compatible endpoint is assumed to support,
+	// nil keeps the default of its base URL, see DefaultOpenAIOptions.
+	StructuredOutput *bool
+	FileInput        *bool
+}
+
+// New creates the provider of the configured backend.
+func New(ctx context.Context, cfg Config) (Provider, error) {
+	switch strings.ToLower(cfg.Backend) {
+	case "", BACKEND_VERTEX:
+		return NewVertex(ctx, cfg.GcpProjectID, cfg.GcpLocation, cfg.Model)
+	case BACKEND_OPENAI:
+		opts := DefaultOpenAIOptions(cfg.BaseURL)
+		if cfg.StructuredOutput != nil {
+			opts.StructuredOutput = *cfg.StructuredOutput
+		}
+		if cfg.FileInput != nil {
+			opts.FileInput = *cfg.FileInput
+		}
+		return NewOpenAI(cfg.BaseURL, cfg.APIKey, cfg.Model, opts)
+	case BACKEND_FAKE:
+		return NewEchoFake(), nil
+	default:
+		return nil, fmt.Errorf("unknown llm backend %q", cfg.Backend)
+	}
+}
+
+// unavailable is the provider used when the configured backend couldn't be created,
+// the service still starts and every generation fails with the initialization error.
+type unavailable struct {
+	err error
+}
+
+// Unavailable returns a provider failing every generation with `err`.
+func Unavailable(err error) Provider {
+	return &unavailable{err: err}
+}
+
+func (u *unavailable) Name() string { return "unavailable" }
+
+func (u *unavailable) Capabilities() Capabilities { return Capabilities{} }
+
+func (u *unavailable) Generate(ctx context.Context, req Request) (*Response, error) {
+	return nil, fmt.Errorf("llm backend unavailable: %w", u.err)
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema types, named after the OpenAPI subset Gemini understands.
+const (
+	TYPE_OBJECT  = "OBJECT"
+	TYPE_ARRAY   = "ARRAY"
+	TYPE_STRING  = "STRING"
+	TYPE_INTEGER = "INTEGER"
+	TYPE_NUMBER  = "NUMBER"
+	TYPE_BOOLEAN = "BOOLEAN"
+)
+
+// Schema describes the JSON the model must answer with, when the backend supports structured output.
+type Schema struct {
+	// Name identifies the schema, some backends require it.
+	Name        string
+	Type        string
+	Description string
+	Properties  map[string]*Schema
+	// Order keeps the properties in the struct order, the models fill them in that order.
+	Order    []string
+	Items    *Schema
+	Required []string
+}
+
+// SchemaOf derives the schema of a struct from its `json` tags. Every field is required,
+// the fields tagged `llm:"-"` are left out, they're not for the model to fill in.
+func SchemaOf(name string, v any) *Schema {
+	s := schemaOfType(reflect.TypeOf(v))
+	s.Name = name
+	return s
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func schemaOfType(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: TYPE_STRING, Description: "RFC 3339 date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		s := &Schema{Type: TYPE_OBJECT, Properties: map[string]*Schema{}}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Tag.Get("llm") == "-" {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			s.Properties[name] = schemaOfType(field.Type)
+			s.Order = append(s.Order, name)
+			s.Required = append(s.Required, name)
+		}
+		return s
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: TYPE_ARRAY, Items: schemaOfType(t.Elem())}
+	case reflect.Bool:
+		return &Schema{Type: TYPE_BOOLEAN}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: TYPE_INTEGER}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: TYPE_NUMBER}
+	default:
+		return &Schema{Type: TYPE_STRING}
+	}
+}
+
+// JSONSchema renders the schema as a JSON Schema document, closed to the additional properties.
+func (s *Schema) JSONSchema() map[string]any {
+	out := map[string]any{"type": strings.ToLower(s.Type)}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	switch s.Type {
+	case TYPE_OBJECT:
+		props := make(map[string]any, len(s.Properties))
+		for name, prop := range s.Properties {
+			props[name] = prop.JSONSchema()
+		}
+		out["properties"] = props
+		out["required"] = s.Required
+		out["additionalProperties"] = false
+	case TYPE_ARRAY:
+		out["items"] = s.Items.JSONSchema()
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type schemaLink struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type schemaProfile struct {
+	ID        string       `json:"id" llm:"-"`
+	Name      string       `json:"name"`
+	Years     int          `json:"years,omitempty"`
+	Links     []schemaLink `json:"links"`
+	Internal  string       `json:"-"`
+	UpdatedAt time.Time    `json:"updatedAt" llm:"-"`
+}
+
+func TestSchemaOfFollowsTheJSONTags(t *testing.T) {
+	s := SchemaOf("profile", schemaProfile{})
+
+	if s.Name != "profile" || s.Type != TYPE_OBJECT {
+		t.Fatalf("unexpected root schema %+v", s)
+	}
+	if want := []string{"name", "years", "links"}; !reflect.DeepEqual(s.Order, want) || !reflect.DeepEqual(s.Required, want) {
+		t.Errorf("expected the properties %v, got order %v and required %v", want, s.Order, s.Required)
+	}
+	if s.Properties["years"].Type != TYPE_INTEGER {
+		t.Errorf("expected years to be an integer, got %s", s.Properties["years"].Type)
+	}
+	links := s.Properties["links"]
+	if links.Type != TYPE_ARRAY || links.Items.Type != TYPE_OBJECT || links.Items.Properties["value"].Type != TYPE_STRING {
+		t.Errorf("unexpected links schema %+v", links)
+	}
+
+	js := s.JSONSchema()
+	if js["type"] != "object" || js["additionalProperties"] != false {
+		t.Errorf("expected a closed object JSON schema, got %v", js)
+	}
+}
@@ -0,0 +1,31 @@
+package llm
+
+import "context"
+
+// StreamFunc receives the generated text chunk by chunk, as the model produces it.
+// Returning an error stops the generation.
+type StreamFunc func(chunk string) error
+
+// StreamingProvider is implemented by the backends able to stream their answers.
+type StreamingProvider interface {
+	Provider
+	// GenerateStream calls `onChunk` with every chunk of the answer, then returns the whole answer with its usage.
+	GenerateStream(ctx context.Context, req Request, onChunk StreamFunc) (*Response, error)
+}
+
+// GenerateStream streams the answer of the provider when it supports it,
+// otherwise it waits for the whole answer and hands it over as a single chunk.
+func GenerateStream(ctx context.Context, p Provider, req Request, onChunk StreamFunc) (*Response, error) {
+	if sp, ok := p.(StreamingProvider); ok {
+		return sp.GenerateStream(ctx, req, onChunk)
+	}
+
+	res, err := p.Generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := onChunk(res.Text); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// vertexTimeout bounds a single Vertex AI call, the callers usually set a shorter deadline.
+const vertexTimeout = 60 * time.Second
+
+// Vertex generates content with Gemini on Vertex AI.
+type Vertex struct {
+	client *genai.Client
+	model  string
+}
+
+// NewVertex creates a Vertex AI client, it needs the Google Cloud application default credentials.
+func NewVertex(ctx context.Context, projectID, location, model string) (*Vertex, error) {
+	timeout := vertexTimeout
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		HTTPOptions: genai.HTTPOptions{
+			APIVersion: "v1",
+			Timeout:    &timeout,
+			Headers: http.Header{
+				"X-Vertex-AI-LLM-Request-Type": []string{"shared"},
+			},
+		},
+		Project:  projectID,
+		Location: location,
+		Backend:  genai.BackendVertexAI,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create genai client: %w", err)
+	}
+	return &Vertex{client: client, model: model}, nil
+}
+
+func (v *Vertex) Name() string { return BACKEND_VERTEX }
+
+func (v *Vertex) Capabilities() Capabilities {
+	return Capabilities{FileURIs: true, StructuredOutput: true, FileInput: true}
+}
+
+func (v *Vertex) Generate(ctx context.Context, req Request) (*Response, error) {
+	res, err := v.client.Models.GenerateContent(ctx, v.model, vertexContents(req), vertexConfig(req))
+	if err != nil {
+		return nil, err
+	}
+	return vertexResponse(res, v.model)
+}
+
+// GenerateStream streams the answer through the streaming API of Vertex AI, the usage comes with the last chunk.
+func (v *Vertex) GenerateStream(ctx context.Context, req Request, onChunk StreamFunc) (*Response, error) {
+	var text strings.Builder
+	out := &Response{Model: v.model}
+	for res, err := range v.client.Models.GenerateContentStream(ctx, v.model, vertexContents(req), vertexConfig(req)) {
+		if err != nil {
+			return nil, err
+		}
+		// The usage may come with a last chunk holding no candidate.
+		if usage := vertexUsage(res.UsageMetadata); usage.TotalTokens > 0 {
+			out.Usage = usage
+		}
+		chunk, err := vertexResponse(res, v.model)
+		if errors.Is(err, ErrEmptyResponse) {
+			continue
+		}
+		if chunk.Text == "" {
+			continue
+		}
+		text.WriteString(chunk.Text)
+		if err := onChunk(chunk.Text); err != nil {
+			return nil, err
+		}
+	}
+	if text.Len() == 0 {
+		return nil, ErrEmptyResponse
+	}
+	out.Text = text.String()
+	return out, nil
+}
+
+func vertexContents(req Request) []*genai.Content {
+	parts := make([]*genai.Part, 0, len(req.Parts))
+	for _, p := range req.Parts {
+		switch {
+		case p.FileURI != "":
+			parts = append(parts, &genai.Part{FileData: &genai.FileData{MIMEType: p.MIMEType, FileURI: p.FileURI}})
+		case len(p.Data) > 0:
+			parts = append(parts, &genai.Part{InlineData: &genai.Blob{MIMEType: p.MIMEType, Data: p.Data}})
+		default:
+			parts = append(parts, &genai.Part{Text: p.Text})
+		}
+	}
+	return []*genai.Content{{Role: "user", Parts: parts}}
+}
+
+func vertexConfig(req Request) *genai.GenerateContentConfig {
+	if req.Temperature == nil && req.MaxOutputTokens == 0 && req.ResponseSchema == nil {
+		return nil
+	}
+	config := &genai.GenerateContentConfig{
+		Temperature:     req.Temperature,
+		MaxOutputTokens: int32(req.MaxOutputTokens),
+	}
+	if req.ResponseSchema != nil {
+		config.ResponseMIMEType = "application/json"
+		config.ResponseSchema = vertexSchema(req.ResponseSchema)
+	}
+	return config
+}
+
+func vertexSchema(s *Schema) *genai.Schema {
+	out := &genai.Schema{
+		Type:             genai.Type(s.Type),
+		Description:      s.Description,
+		Required:         s.Required,
+		PropertyOrdering: s.Order,
+	}
+	if len(s.Properties) > 0 {
+		out.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, prop := range s.Properties {
+			out.Properties[name] = vertexSchema(prop)
+		}
+	}
+	if s.Items != nil {
+		out.Items = vertexSchema(s.Items)
+	}
+	return out
+}
+
+func vertexResponse(res *genai.GenerateContentResponse, model string) (*Response, error) {
+	if len(res.Candidates) == 0 {
+		return nil, ErrEmptyResponse
+	}
+
+	var text strings.Builder
+	for _, cand := range res.Candidates {
+		if cand.Content == nil {
+			continue
+		}
+		for _, part := range cand.Content.Parts {
+			text.WriteString(part.Text)
+		}
+	}
+
+	return &Response{Text: text.String(), Model: model, Usage: vertexUsage(res.UsageMetadata)}, nil
+}
+
+func vertexUsage(meta *genai.GenerateContentResponseUsageMetadata) Usage {
+	if meta == nil {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:     int(meta.PromptTokenCount),
+		CompletionTokens: int(meta.CandidatesTokenCount),
+		TotalTokens:      int(meta.TotalTokenCount),
+	}
+}
@@ -0,0 +1,192 @@
+// Package metrics holds the Prometheus collectors of the service, served on `/metrics`.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry is the registry every collector of the service is registered to.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, by route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// JobStageDuration is the time taken by a single run of a job stage, by outcome (COMPLETED, FAILED).
+	JobStageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "job_stage_duration_seconds",
+		Help:    "Duration of a single run of a job stage.",
+		Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"kind", "stage", "outcome"})
+
+	// JobsFinishedTotal counts the jobs which reached a final status (COMPLETED, FAILED).
+	JobsFinishedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "jobs_finished_total",
+		Help: "Jobs which reached a final status.",
+	}, []string{"kind", "status"})
+
+	// JobRetriesTotal counts the failed stage runs scheduled for a retry.
+	JobRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "job_retries_total",
+		Help: "Failed job stage runs scheduled for a retry.",
+	}, []string{"kind", "stage"})
+
+	// LLMRequestDuration is the latency of the LLM calls, by core method.
+	LLMRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llm_request_duration_seconds",
+		Help:    "LLM call latency, by method.",
+		Buckets: []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"method"})
+
+	// LLMRequestsTotal counts the LLM calls, by core method and outcome (success, error).
+	LLMRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "llm_requests_total",
+		Help: "LLM calls, by method and outcome.",
+	}, []string{"method", "outcome"})
+
+	// LLMTokensTotal counts the tokens consumed by the LLM calls, by core method and type (prompt, completion).
+	LLMTokensTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "llm_tokens_total",
+		Help: "Tokens consumed by the LLM calls, by method and type.",
+	}, []string{"method", "type"})
+
+	// LLMCacheLookupsTotal counts the LLM cache lookups, by core method and result (hit, miss, bypass).
+	LLMCacheLookupsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "llm_cache_lookups_total",
+		Help: "LLM cache lookups, by method and result.",
+	}, []string{"method", "result"})
+
+	// JobPostingLookupsTotal counts the job postings looked up for the drafts, by result (hit, fetched, error).
+	JobPostingLookupsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "job_posting_lookups_total",
+		Help: "Job postings looked up, by result.",
+	}, []string{"result"})
+
+	// SMTPSendsTotal counts the emails handed to the SMTP server, by kind (referral, notification) and outcome.
+	SMTPSendsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "smtp_sends_total",
+		Help: "Emails handed to the SMTP server, by kind and outcome.",
+	}, []string{"kind", "outcome"})
+
+	// SMTPSendDuration is the latency of the SMTP sends, by kind.
+	SMTPSendDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "smtp_send_duration_seconds",
+		Help:    "SMTP send latency, by kind.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 5, 10, 30},
+	}, []string{"kind"})
+
+	// PDFRenderDuration is the latency of the PDF service, by outcome.
+	PDFRenderDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pdf_render_duration_seconds",
+		Help:    "PDF service latency, by outcome.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 5, 10, 30},
+	}, []string{"outcome"})
+
+	// BulkEmailRecipientsTotal counts the recipients processed by the bulk sends, by status (SENT, FAILED, SKIPPED).
+	BulkEmailRecipientsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "bulk_email_recipients_total",
+		Help: "Recipients processed by the bulk email jobs, by status.",
+	}, []string{"status"})
+
+	// BulkEmailJobDuration is the total time taken by a bulk email job.
+	BulkEmailJobDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "bulk_email_job_duration_seconds",
+		Help:    "Total duration of the bulk email jobs.",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600},
+	})
+
+	// ScheduledTaskRunsTotal counts the runs of the periodic tasks, by task and outcome.
+	ScheduledTaskRunsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduled_task_runs_total",
+		Help: "Runs of the periodic tasks, by task and outcome.",
+	}, []string{"task", "outcome"})
+
+	// ScheduledTaskDuration is the time taken by a run of a periodic task.
+	ScheduledTaskDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scheduled_task_duration_seconds",
+		Help:    "Duration of the runs of the periodic tasks.",
+		Buckets: []float64{0.1, 0.5, 1, 5, 15, 30, 60, 300, 900},
+	}, []string{"task"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestsTotal,
+		httpRequestDuration,
+		JobStageDuration,
+		JobsFinishedTotal,
+		JobRetriesTotal,
+		LLMRequestDuration,
+		LLMRequestsTotal,
+		LLMTokensTotal,
+		LLMCacheLookupsTotal,
+		JobPostingLookupsTotal,
+		SMTPSendsTotal,
+		SMTPSendDuration,
+		PDFRenderDuration,
+		BulkEmailRecipientsTotal,
+		BulkEmailJobDuration,
+		ScheduledTaskRunsTotal,
+		ScheduledTaskDuration,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Outcome labels an operation by its error.
+func Outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// Since observes the time elapsed since `start` on the histogram.
+func Since(h prometheus.Observer, start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// EchoMiddleware records the latency and status of the HTTP requests.
+// Requests are labelled by their route pattern, not their path, to keep the cardinality bounded.
+func EchoMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+
+		status := c.Response().Status
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			status = httpErr.Code
+		} else if err != nil && !c.Response().Committed {
+			// Left to the echo error handler, which answers with a 500.
+			status = http.StatusInternalServerError
+		}
+		route := c.Path()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		httpRequestDuration.WithLabelValues(c.Request().Method, route).Observe(time.Since(start).Seconds())
+		httpRequestsTotal.WithLabelValues(c.Request().Method, route, strconv.Itoa(status)).Inc()
+		return err
+	}
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollectorsAreRegistered(t *testing.T) {
+	for name, c := range map[string]prometheus.Collector{
+		"http_requests_total":             httpRequestsTotal,
+		"http_request_duration_seconds":   httpRequestDuration,
+		"job_stage_duration_seconds":      JobStageDuration,
+		"jobs_finished_total":             JobsFinishedTotal,
+		"job_retries_total":               JobRetriesTotal,
+		"llm_request_duration_seconds":    LLMRequestDuration,
+		"llm_requests_total":              LLMRequestsTotal,
+		"llm_tokens_total":                LLMTokensTotal,
+		"llm_cache_lookups_total":         LLMCacheLookupsTotal,
+		"job_posting_lookups_total":       JobPostingLookupsTotal,
+		"smtp_sends_total":                SMTPSendsTotal,
+		"smtp_send_duration_seconds":      SMTPSendDuration,
+		"pdf_render_duration_seconds":     PDFRenderDuration,
+		"bulk_email_recipients_total":     BulkEmailRecipientsTotal,
+		"bulk_email_job_duration_seconds": BulkEmailJobDuration,
+		"scheduled_task_runs_total":       ScheduledTaskRunsTotal,
+		"scheduled_task_duration_seconds": ScheduledTaskDuration,
+	} {
+		var are prometheus.AlreadyRegisteredError
+		if err := Registry.Register(c); !errors.As(err, &are) {
+			t.Errorf("expected %s to be registered, got %v", name, err)
+		}
+	}
+
+	problems, err := testutil.GatherAndLint(Registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Errorf("%s: %s", p.Metric, p.Text)
+	}
+}
+
+func TestEchoMiddlewareLabelsTheRequestsByRoute(t *testing.T) {
+	e := echo.New()
+	e.Use(EchoMiddleware)
+	e.GET("/api/jobs/:id", func(c echo.Context) error {
+		if c.Param("id") == "missing" {
+			return echo.NewHTTPError(http.StatusNotFound, "job not found")
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	for _, path := range []string{"/api/jobs/1", "/api/jobs/2", "/api/jobs/missing", "/api/unknown"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	for labels, want := range map[[3]string]float64{
+		{"GET", "/api/jobs/:id", "200"}: 2,
+		{"GET", "/api/jobs/:id", "404"}: 1,
+		{"GET", "unmatched", "404"}:     1,
+	} {
+		if got := testutil.ToFloat64(httpRequestsTotal.WithLabelValues(labels[:]...)); got != want {
+			t.Errorf("expected %v requests labelled %v, got %v", want, labels, got)
+		}
+	}
+	// the path of a request is never a label, the series stay bounded
+	if n := testutil.CollectAndCount(httpRequestsTotal); n != 3 {
+		t.Errorf("expected 3 series, got %d", n)
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	if Outcome(nil) != "success" || Outcome(errors.New("timeout")) != "error" {
+		t.Errorf("expected success and error, got %s and %s", Outcome(nil), Outcome(errors.New("timeout")))
+	}
+}
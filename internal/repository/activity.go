@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ActivitySummary counts what a user did over a period, for the weekly digest.
+type ActivitySummary struct {
+	EmailsSent      int64 `json:"emailsSent"`
+	DraftsCreated   int64 `json:"draftsCreated"`
+	TailoredResumes int64 `json:"tailoredResumes"`
+	ContactsAdded   int64 `json:"contactsAdded"`
+}
+
+// IsEmpty tells whether the user did nothing over the period.
+func (a ActivitySummary) IsEmpty() bool {
+	return a.EmailsSent == 0 && a.DraftsCreated == 0 && a.TailoredResumes == 0 && a.ContactsAdded == 0
+}
+
+// GetActivitySummary counts the user's activity since `since`.
+// Tailored resumes are keyed by the user id, everything else by the email.
+func (mc *MongoDBClient) GetActivitySummary(userEmail, userID string, since time.Time) (ActivitySummary, error) {
+	ctx, cancel := getContextWithTimeout(20)
+	defer cancel()
+
+	db := mc.Database("referrer")
+	after := bson.M{"$gte": since}
+	var summary ActivitySummary
+	var err error
+
+	if summary.EmailsSent, err = db.Collection("referral_mailbox").CountDocuments(ctx, bson.M{"from": userEmail, "createdAt": after}); err != nil {
+		return summary, err
+	}
+	if summary.DraftsCreated, err = db.Collection("ai_email_drafts").CountDocuments(ctx, bson.M{"userEmailAddress": userEmail, "createdAt": after}); err != nil {
+		return summary, err
+	}
+	if summary.TailoredResumes, err = db.Collection("tailored_resumes").CountDocuments(ctx, bson.M{"userId": userID, "createdAt": after}); err != nil {
+		return summary, err
+	}
+	if summary.ContactsAdded, err = db.Collection("contacts").CountDocuments(ctx, bson.M{"ownerId": userEmail, "createdAt": after}); err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
+
+// ListUserEmails returns the email of every user, optionally only the ones receiving email notifications.
+func (mc *MongoDBClient) ListUserEmails(onlyReceivingEmails bool) ([]string, error) {
+	ctx, cancel := getContextWithTimeout(20)
+	defer cancel()
+
+	filter := bson.M{}
+	if onlyReceivingEmails {
+		filter["notifications.receiveEmails"] = true
+	}
+
+	collection := mc.Database("referrer").Collection("users")
+	cursor, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"email": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []struct {
+		Email string `bson:"email"`
+	}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	emails := make([]string, 0, len(users))
+	for _, u := range users {
+		emails = append(emails, u.Email)
+	}
+	return emails, nil
+}
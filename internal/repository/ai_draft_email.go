@@ -3,12 +3,19 @@ package repository
 import (
 	"time"
 
-	"gopkg.in/mgo.v2/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Outcomes of a streamed AI draft.
+const (
+	AI_DRAFT_COMPLETED = "COMPLETED"
+	// AI_DRAFT_CANCELLED is a draft whose stream was closed by the client, it holds the body drafted so far.
+	AI_DRAFT_CANCELLED = "CANCELLED"
 )
 
 type AiDraftColdEmail struct {
-	ID               bson.ObjectId `json:"id" bson:"_id,omitempty"`
-	UserEmailAddress string        `json:"userEmailAddress,omitempty" bson:"userEmailAddress"`
+	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserEmailAddress string             `json:"userEmailAddress,omitempty" bson:"userEmailAddress"`
 
 	To   string `json:"to,omitempty" bson:"to"`
 	From string `json:"from,omitempty" bson:"from"`
@@ -16,16 +23,37 @@ type AiDraftColdEmail struct {
 	CompanyName    string   `json:"companyName,omitempty" bson:"companyName"`
 	JobUrls        []string `json:"jobUrls,omitempty" bson:"jobUrls"`
 	JobDescription string   `json:"jobDescription,omitempty" bson:"jobDescription"`
-	TemplateType   string   `json:"templateType,omitempty" bson:"templateType"`
+	// TemplateType is the draft type.
+	TemplateType string `json:"templateType,omitempty" bson:"templateType"`
+	JobRole      string `json:"jobRole,omitempty" bson:"jobRole,omitempty"`
 
 	MailSubject string `json:"mailSubject,omitempty" bson:"mailSubject"`
 	Mailbody    string `json:"mailBody,omitempty" bson:"mailBody"`
 
 	TailoredResumeID string `json:"tailoredResumeId,omitempty" bson:"tailoredResumeId"`
 
+	// Prompt and SubjectPrompt are the prompt versions which drafted the body and the subject.
+	Prompt        PromptRef `json:"prompt" bson:"prompt"`
+	SubjectPrompt PromptRef `json:"subjectPrompt" bson:"subjectPrompt"`
+
+	// Status is AI_DRAFT_COMPLETED or AI_DRAFT_CANCELLED for the streamed drafts.
+	Status string `json:"status,omitempty" bson:"status,omitempty"`
+
+	// GroupID ties the variants drafted by the same request, each one in its Tone and numbered by Variant from 1.
+	GroupID primitive.ObjectID `json:"groupId,omitempty" bson:"groupId,omitempty"`
+	Tone    string             `json:"tone,omitempty" bson:"tone,omitempty"`
+	Variant int                `json:"variant,omitempty" bson:"variant,omitempty"`
+	// ChosenAt is set on the variant of the group which was sent.
+	ChosenAt *time.Time `json:"chosenAt,omitempty" bson:"chosenAt,omitempty"`
+
 	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
 }
 
+// Sent is the reference stored on the emails sent from the draft.
+func (d *AiDraftColdEmail) Sent() *SentDraft {
+	return &SentDraft{DraftID: d.ID, GroupID: d.GroupID, Tone: d.Tone, Variant: d.Variant}
+}
+
 type ProfileAnalytics struct {
 	TotalEmails int                     `json:"totalEmails" bson:"totalEmails"`
 	Companies   []CompanyEmailAggregate `json:"companies" bson:"companies"`
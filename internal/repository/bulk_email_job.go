@@ -1,21 +1,58 @@
 package repository
 
 import (
+	"regexp"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// BulkEmailRecipient tracks the delivery of a bulk email job to a single recipient.
+type BulkEmailRecipient struct {
+	Email  string `json:"email" bson:"email"`
+	Status string `json:"status" bson:"status"` // PENDING, SENT, FAILED, SKIPPED
+	Reason string `json:"reason,omitempty" bson:"reason,omitempty"`
+}
+
 type BulkEmailJob struct {
-	ID              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	UserEmail       string             `json:"userEmail" bson:"userEmail"`
-	TotalRecipients int                `json:"totalRecipients" bson:"totalRecipients"`
-	SentCount       int                `json:"sentCount" bson:"sentCount"`
-	Status          string             `json:"status" bson:"status"` // PENDING, IN_PROGRESS, COMPLETED, FAILED
-	CreatedAt       time.Time          `json:"createdAt" bson:"createdAt"`
-	UpdatedAt       time.Time          `json:"updatedAt" bson:"updatedAt"`
-	Errors          []string           `json:"errors" bson:"errors"`
+	ID              primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	UserEmail       string               `json:"userEmail" bson:"userEmail"`
+	Subject         string               `json:"subject" bson:"subject"`
+	BodyHash        string               `json:"bodyHash" bson:"bodyHash"`
+	TotalRecipients int                  `json:"totalRecipients" bson:"totalRecipients"`
+	SentCount       int                  `json:"sentCount" bson:"sentCount"`
+	FailedCount     int                  `json:"failedCount" bson:"failedCount"`
+	SkippedCount    int                  `json:"skippedCount" bson:"skippedCount"`
+	Recipients      []BulkEmailRecipient `json:"recipients" bson:"recipients"`
+	Status          string               `json:"status" bson:"status"` // PENDING, IN_PROGRESS, COMPLETED, FAILED
+	CreatedAt       time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt       time.Time            `json:"updatedAt" bson:"updatedAt"`
+	ScheduledAt     *time.Time           `json:"scheduledAt,omitempty" bson:"scheduledAt,omitempty"`
+	StartedAt       *time.Time           `json:"startedAt,omitempty" bson:"startedAt,omitempty"`
+	CompletedAt     *time.Time           `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
+	Errors          []string             `json:"errors" bson:"errors"`
+}
+
+// BulkEmailJobFilter narrows down the bulk email jobs listing.
+type BulkEmailJobFilter struct {
+	UserEmail string
+	Status    string
+	Subject   string
+	From      time.Time
+	To        time.Time
+}
+
+// Duration is the time taken by the job so far, or in total once it has finished.
+func (job *BulkEmailJob) Duration() time.Duration {
+	if job.StartedAt == nil {
+		return 0
+	}
+	if job.CompletedAt == nil {
+		return time.Since(*job.StartedAt)
+	}
+	return job.CompletedAt.Sub(*job.StartedAt)
 }
 
 func (mc *MongoDBClient) CreateBulkEmailJob(job *BulkEmailJob) error {
@@ -34,6 +71,24 @@ func (mc *MongoDBClient) CreateBulkEmailJob(job *BulkEmailJob) error {
 	return nil
 }
 
+// StartBulkEmailJob marks the job as picked up for sending.
+func (mc *MongoDBClient) StartBulkEmailJob(id primitive.ObjectID) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("bulk_email_jobs")
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":    "IN_PROGRESS",
+			"startedAt": time.Now(),
+			"updatedAt": time.Now(),
+		},
+	}
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
 func (mc *MongoDBClient) UpdateBulkEmailJobProgress(id primitive.ObjectID, sentCount int) error {
 	ctx, cancel := getContextWithTimeout(10)
 	defer cancel()
@@ -51,6 +106,31 @@ func (mc *MongoDBClient) UpdateBulkEmailJobProgress(id primitive.ObjectID, sentC
 	return err
 }
 
+// UpdateBulkEmailRecipient records the delivery status of one recipient of the job.
+// Failed and skipped recipients are also counted on the job itself.
+func (mc *MongoDBClient) UpdateBulkEmailRecipient(id primitive.ObjectID, email, status, reason string) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("bulk_email_jobs")
+
+	update := bson.M{
+		"$set": bson.M{
+			"recipients.$.status": status,
+			"recipients.$.reason": reason,
+			"updatedAt":           time.Now(),
+		},
+	}
+	switch status {
+	case "FAILED":
+		update["$inc"] = bson.M{"failedCount": 1}
+	case "SKIPPED":
+		update["$inc"] = bson.M{"skippedCount": 1}
+	}
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id, "recipients.email": email}, update)
+	return err
+}
+
 func (mc *MongoDBClient) CompleteBulkEmailJob(id primitive.ObjectID) error {
 	ctx, cancel := getContextWithTimeout(10)
 	defer cancel()
@@ -59,8 +139,9 @@ func (mc *MongoDBClient) CompleteBulkEmailJob(id primitive.ObjectID) error {
 	
 	update := bson.M{
 		"$set": bson.M{
-			"status":    "COMPLETED",
-			"updatedAt": time.Now(),
+			"status":      "COMPLETED",
+			"updatedAt":   time.Now(),
+			"completedAt": time.Now(),
 		},
 	}
 	_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, update)
@@ -75,8 +156,9 @@ func (mc *MongoDBClient) FailBulkEmailJob(id primitive.ObjectID, errStr string)
 	
 	update := bson.M{
 		"$set": bson.M{
-			"status":    "FAILED",
-			"updatedAt": time.Now(),
+			"status":      "FAILED",
+			"updatedAt":   time.Now(),
+			"completedAt": time.Now(),
 		},
 		"$push": bson.M{
 			"errors": errStr,
@@ -99,3 +181,51 @@ func (mc *MongoDBClient) GetBulkEmailJob(id primitive.ObjectID) (*BulkEmailJob,
 	}
 	return &job, nil
 }
+
+// ListBulkEmailJobs lists the user's bulk email jobs, newest first, using the job ID as a cursor.
+// Only the jobs created before the `cursor` job are returned, a nil cursor starts from the latest one.
+func (mc *MongoDBClient) ListBulkEmailJobs(filter BulkEmailJobFilter, cursor primitive.ObjectID, limit int) ([]*BulkEmailJob, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("bulk_email_jobs")
+
+	filterCondn := bson.M{"userEmail": filter.UserEmail}
+	if !cursor.IsZero() {
+		filterCondn["_id"] = bson.M{"$lt": cursor}
+	}
+	if filter.Status != "" {
+		filterCondn["status"] = filter.Status
+	}
+	if filter.Subject != "" {
+		filterCondn["subject"] = bson.M{"$regex": regexp.QuoteMeta(filter.Subject), "$options": "i"}
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		dateFilter := bson.M{}
+		if !filter.From.IsZero() {
+			dateFilter["$gte"] = filter.From
+		}
+		if !filter.To.IsZero() {
+			dateFilter["$lte"] = filter.To
+		}
+		filterCondn["createdAt"] = dateFilter
+	}
+
+	// The per recipient breakdown can be large, it's only served by the job status endpoint.
+	opts := options.Find().
+		SetSort(bson.M{"_id": -1}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"recipients": 0})
+
+	cur, err := collection.Find(ctx, filterCondn, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	jobs := []*BulkEmailJob{}
+	if err := cur.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
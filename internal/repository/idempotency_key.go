@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrIdempotencyKeyExists is returned when a key has already been reserved by an earlier request.
+var ErrIdempotencyKeyExists = errors.New("idempotency key already exists")
+
+// IdempotencyKey stores the outcome of a request sent with an `Idempotency-Key` header,
+// so that retries of the same request can be answered without running it again.
+type IdempotencyKey struct {
+	ID          string `json:"id" bson:"_id"`
+	Key         string `json:"key" bson:"key"`
+	Caller      string `json:"caller" bson:"caller"` // the key is scoped to the user who sent it
+	Method      string `json:"method" bson:"method"`
+	Path        string `json:"path" bson:"path"`
+	RequestHash string `json:"requestHash" bson:"requestHash"`
+	Status      string `json:"status" bson:"status"` // IN_PROGRESS, COMPLETED
+
+	ResponseStatus      int    `json:"responseStatus" bson:"responseStatus"`
+	ResponseContentType string `json:"responseContentType" bson:"responseContentType"`
+	ResponseBody        []byte `json:"-" bson:"responseBody"`
+
+	LockedUntil time.Time `json:"lockedUntil" bson:"lockedUntil"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt   time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+// ReserveIdempotencyKey marks the key as IN_PROGRESS for the current request.
+// A key whose previous holder died before finishing (lock expired) is taken over.
+func (mc *MongoDBClient) ReserveIdempotencyKey(k *IdempotencyKey) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("idempotency_keys")
+
+	k.Status = "IN_PROGRESS"
+	_, err := collection.InsertOne(ctx, k)
+	if err == nil {
+		return nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	// Take over an abandoned reservation of the very same request.
+	m, err := collection.UpdateOne(ctx,
+		bson.M{"_id": k.ID, "status": "IN_PROGRESS", "requestHash": k.RequestHash, "lockedUntil": bson.M{"$lt": time.Now()}},
+		bson.M{"$set": bson.M{"lockedUntil": k.LockedUntil, "expiresAt": k.ExpiresAt}},
+	)
+	if err != nil {
+		return err
+	}
+	if m.MatchedCount == 0 {
+		return ErrIdempotencyKeyExists
+	}
+	return nil
+}
+
+// GetIdempotencyKey fetches a stored idempotency key by its scoped ID.
+func (mc *MongoDBClient) GetIdempotencyKey(id string) (*IdempotencyKey, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("idempotency_keys")
+
+	var k IdempotencyKey
+	if err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&k); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// CompleteIdempotencyKey stores the response of the request, to be replayed on retries.
+func (mc *MongoDBClient) CompleteIdempotencyKey(id string, responseStatus int, contentType string, body []byte) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("idempotency_keys")
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"status":              "COMPLETED",
+		"responseStatus":      responseStatus,
+		"responseContentType": contentType,
+		"responseBody":        body,
+	}})
+	return err
+}
+
+// ReleaseIdempotencyKey drops a reservation, so the request can be retried from scratch.
+func (mc *MongoDBClient) ReleaseIdempotencyKey(id string) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("idempotency_keys")
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// JobPostingCacheEntry is a fetched job posting, keyed by the URL it was asked for.
+// The entries expire through the TTL index on `expiresAt`.
+type JobPostingCacheEntry struct {
+	URL string `json:"url" bson:"_id"`
+	// FinalURL is where the posting was found, after the redirects.
+	FinalURL    string    `json:"finalUrl" bson:"finalUrl"`
+	Title       string    `json:"title,omitempty" bson:"title,omitempty"`
+	Company     string    `json:"company,omitempty" bson:"company,omitempty"`
+	Location    string    `json:"location,omitempty" bson:"location,omitempty"`
+	Description string    `json:"description" bson:"description"`
+	Structured  bool      `json:"structured" bson:"structured"`
+	Truncated   bool      `json:"truncated,omitempty" bson:"truncated,omitempty"`
+	FetchedAt   time.Time `json:"fetchedAt" bson:"fetchedAt"`
+	ExpiresAt   time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+// GetJobPostingCacheEntry returns the live entry of the URL, nil when there's none.
+func (mc *MongoDBClient) GetJobPostingCacheEntry(url string) (*JobPostingCacheEntry, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("job_postings")
+
+	// The TTL monitor only runs every minute, the expired entries are filtered out.
+	var entry JobPostingCacheEntry
+	err := collection.FindOne(ctx, bson.M{"_id": url, "expiresAt": bson.M{"$gt": time.Now()}}).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// PutJobPostingCacheEntry stores the entry, replacing the one of the same URL.
+func (mc *MongoDBClient) PutJobPostingCacheEntry(entry *JobPostingCacheEntry) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("job_postings")
+	entry.FetchedAt = time.Now()
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": entry.URL}, entry, options.Replace().SetUpsert(true))
+	return err
+}
@@ -1,11 +1,27 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
-	"gopkg.in/mgo.v2/bson"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrJobVersionConflict is returned when a job was modified by someone else since it was read.
+var ErrJobVersionConflict = errors.New("job was modified concurrently")
+
+// ErrJobLeaseLost is returned when renewing the lease of a job the worker no longer holds.
+var ErrJobLeaseLost = errors.New("job lease lost")
+
+// JobType is the legacy stage enum of the resume processing jobs.
+//
+// Deprecated: jobs now carry their `Kind` and `Stage` as strings. It's only kept
+// to pick up the jobs queued before the stage graphs were introduced.
 type JobType int
 
 const (
@@ -15,29 +31,598 @@ const (
 	EMAIL_NOTIFICATION
 )
 
-// String - Creating common behavior - give the type a String function
+// String - Creating common behavior - give the type a String function
 func (j JobType) String() string {
-	return [...]string{"EXTRACT_CONTENT", "GENERATE_PROFILE_SUMMARY", "UPDATE_RESUME_DOCUMENT", "EMAIL_NOTIFICATION"}[j-1]
+	names := [...]string{"EXTRACT_CONTENT", "GENERATE_PROFILE_SUMMARY", "UPDATE_RESUME_DOCUMENT", "EMAIL_NOTIFICATION"}
+	if j < 1 || int(j) > len(names) {
+		return fmt.Sprintf("JobType(%d)", int(j))
+	}
+	return names[j-1]
 }
 
-// EnumIndex - Creating common behavior - give the type a EnumIndex function
+// EnumIndex - Creating common behavior - give the type a EnumIndex function
 func (j JobType) EnumIndex() int {
 	return int(j)
 }
 
-type Payload struct {
-	ResumeURL        string `json:"resumeUrl" bson:"resumeUrl"`
-	ExtractedContent string `json:"extractedContent" bson:"extractedContent"`
-	Summary          string `json:"summary" bson:"summary"`
-}
+// LEGACY_JOB_KIND is the kind of the jobs queued before job kinds existed, they were all resume processing jobs.
+const LEGACY_JOB_KIND = "RESUME_PROCESSING"
+
+// Priorities of the queued jobs, the highest priority due job is claimed first.
+const (
+	JOB_PRIORITY_LOW    = 0
+	JOB_PRIORITY_NORMAL = 50
+	JOB_PRIORITY_HIGH   = 100
+)
 
+// JobQueue is a background job document of the `job_queues` collection.
+// The payload is stored as raw BSON, it's decoded into the typed payload of the job kind by the worker pool.
 type JobQueue struct {
-	ID bson.ObjectId `json:"id" bson:"_id,omitempty"`
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 
 	UserEmailAddress string    `json:"userEmailAddress" bson:"userEmailAddress"`
-	JobType          JobType   `json:"jobType" bson:"jobType"`
+	Kind             string    `json:"kind" bson:"kind"`
+	Stage            string    `json:"stage" bson:"stage"`
+	JobType          JobType   `json:"-" bson:"jobType,omitempty"`
 	Status           string    `json:"status" bson:"status"`
 	CreatedAt        time.Time `json:"createdAt" bson:"createdAt"`
 	UpdatedAt        time.Time `json:"updatedAt" bson:"updatedAt"`
-	Payload          Payload   `json:"payload" bson:"payload"`
+	Payload          bson.Raw  `json:"-" bson:"payload"`
+
+	// Priority orders the due jobs, RunAt is when the job was scheduled to run, jobs aren't claimed before it.
+	Priority int       `json:"priority" bson:"priority"`
+	RunAt    time.Time `json:"runAt" bson:"runAt"`
+
+	// StageRuns records every run of the job's stages, with its timings and error.
+	StageRuns []JobStageRun `json:"stageRuns,omitempty" bson:"stageRuns,omitempty"`
+
+	// Attempts counts the failed runs of the current stage, it's reset when the job moves to its next stage.
+	Attempts  int        `json:"attempts" bson:"attempts"`
+	LastError string     `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	NextRunAt *time.Time `json:"nextRunAt,omitempty" bson:"nextRunAt,omitempty"`
+
+	// LeaseOwner is the worker holding the IN_PROGRESS job until LeaseExpiresAt, the lease is renewed while the job runs.
+	LeaseOwner     string     `json:"leaseOwner,omitempty" bson:"leaseOwner,omitempty"`
+	LeaseExpiresAt *time.Time `json:"leaseExpiresAt,omitempty" bson:"leaseExpiresAt,omitempty"`
+
+	// Version is bumped on every write, updates are only applied on the version they were read at.
+	Version int64 `json:"version" bson:"version"`
+}
+
+// JobStageRun is a single run of a job's stage.
+type JobStageRun struct {
+	Stage      string    `json:"stage" bson:"stage"`
+	Attempt    int       `json:"attempt" bson:"attempt"`
+	StartedAt  time.Time `json:"startedAt" bson:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt" bson:"finishedAt"`
+	DurationMs int64     `json:"durationMs" bson:"durationMs"`
+	Error      string    `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// DeadJob is a job which exhausted its attempts, it's kept in the `dead_jobs` collection until requeued.
+type DeadJob struct {
+	JobQueue `bson:",inline"`
+	DeadAt   time.Time `json:"deadAt" bson:"deadAt"`
+}
+
+// EnqueueJob inserts a new job into the job queue.
+// A job with a future `RunAt` is delayed until then, otherwise it runs as soon as a worker is free.
+func (mc *MongoDBClient) EnqueueJob(job *JobQueue) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	if job.ID.IsZero() {
+		job.ID = primitive.NewObjectID()
+	}
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = time.Now()
+	if job.RunAt.IsZero() {
+		job.RunAt = job.CreatedAt
+	}
+	if job.RunAt.After(job.CreatedAt) {
+		runAt := job.RunAt
+		job.NextRunAt = &runAt
+	}
+
+	collection := mc.Database("referrer").Collection("job_queues")
+	_, err := collection.InsertOne(ctx, job)
+	return err
+}
+
+// UpdateJobState persists the current kind, stage, status, payload and retry state of the job.
+// The update is optimistic, it only applies when the stored job is still at `job.Version`,
+// otherwise ErrJobVersionConflict is returned. On success the job's version is bumped.
+// The lease is left to the heartbeats while the job is IN_PROGRESS, and dropped otherwise.
+func (mc *MongoDBClient) UpdateJobState(job *JobQueue) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	job.UpdatedAt = time.Now()
+
+	unset := bson.M{"jobType": ""}
+	if job.Status != "IN_PROGRESS" {
+		job.LeaseOwner = ""
+		job.LeaseExpiresAt = nil
+		unset["leaseOwner"] = ""
+		unset["leaseExpiresAt"] = ""
+	}
+
+	collection := mc.Database("referrer").Collection("job_queues")
+	m, err := collection.UpdateOne(ctx,
+		bson.M{"_id": job.ID, "version": job.Version},
+		bson.M{
+			"$inc": bson.M{"version": 1},
+			"$set": bson.M{
+				"kind":      job.Kind,
+				"stage":     job.Stage,
+				"status":    job.Status,
+				"updatedAt": job.UpdatedAt,
+				"payload":   job.Payload,
+				"attempts":  job.Attempts,
+				"lastError": job.LastError,
+				"nextRunAt": job.NextRunAt,
+				"stageRuns": job.StageRuns,
+			},
+			"$unset": unset,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if m.MatchedCount == 0 {
+		return fmt.Errorf("%w: job=%s, version=%d", ErrJobVersionConflict, job.ID.Hex(), job.Version)
+	}
+	job.Version++
+	return nil
+}
+
+// ClaimPendingJob atomically moves the highest priority due PENDING job to IN_PROGRESS, leased to `workerID`
+// for `leaseDuration`, and returns it. Among the same priority, the one scheduled the earliest wins.
+// Delayed jobs, jobs waiting for their retry backoff and jobs of the `excludeUsers` are left in the queue.
+// It returns nil when there is nothing to claim.
+func (mc *MongoDBClient) ClaimPendingJob(workerID string, leaseDuration time.Duration, excludeUsers []string) (*JobQueue, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("job_queues")
+
+	// `$not: {$gt: now}` also matches the jobs without any nextRunAt.
+	filter := bson.M{
+		"status":    "PENDING",
+		"nextRunAt": bson.M{"$not": bson.M{"$gt": time.Now()}},
+	}
+	if len(excludeUsers) > 0 {
+		filter["userEmailAddress"] = bson.M{"$nin": excludeUsers}
+	}
+
+	now := time.Now()
+	var job JobQueue
+	err := collection.FindOneAndUpdate(ctx,
+		filter,
+		bson.M{
+			"$set": bson.M{
+				"status":         "IN_PROGRESS",
+				"updatedAt":      now,
+				"leaseOwner":     workerID,
+				"leaseExpiresAt": now.Add(leaseDuration),
+			},
+			"$inc": bson.M{"version": 1},
+		},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "runAt", Value: 1}, {Key: "createdAt", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RenewJobLease extends the lease of an IN_PROGRESS job held by `workerID`.
+// It doesn't bump the version, a heartbeat never conflicts with the worker's own updates.
+// It returns ErrJobLeaseLost when the job isn't leased to the worker anymore.
+func (mc *MongoDBClient) RenewJobLease(id primitive.ObjectID, workerID string, leaseDuration time.Duration) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("job_queues")
+	m, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": "IN_PROGRESS", "leaseOwner": workerID},
+		bson.M{"$set": bson.M{"leaseExpiresAt": time.Now().Add(leaseDuration)}},
+	)
+	if err != nil {
+		return err
+	}
+	if m.MatchedCount == 0 {
+		return fmt.Errorf("%w: job=%s, worker=%s", ErrJobLeaseLost, id.Hex(), workerID)
+	}
+	return nil
+}
+
+// ReapExpiredJobLeases returns the IN_PROGRESS jobs whose lease expired to PENDING, they were held by a worker which died.
+// Jobs claimed before leases existed are reaped once they haven't been updated for `leaseDuration`.
+// The version is bumped, so a late write of the former owner is rejected.
+func (mc *MongoDBClient) ReapExpiredJobLeases(leaseDuration time.Duration) (int64, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("job_queues")
+
+	now := time.Now()
+	m, err := collection.UpdateMany(ctx,
+		bson.M{
+			"status": "IN_PROGRESS",
+			"$or": bson.A{
+				bson.M{"leaseExpiresAt": bson.M{"$lt": now}},
+				bson.M{"leaseExpiresAt": bson.M{"$exists": false}, "updatedAt": bson.M{"$lt": now.Add(-leaseDuration)}},
+			},
+		},
+		bson.M{
+			"$set":   bson.M{"status": "PENDING", "updatedAt": now},
+			"$unset": bson.M{"leaseOwner": "", "leaseExpiresAt": ""},
+			"$inc":   bson.M{"version": 1},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return m.ModifiedCount, nil
+}
+
+// WatchClaimableJobs opens a change stream notifying about the jobs becoming claimable,
+// the inserted ones and the ones going back to PENDING. It requires a replica set or a sharded cluster.
+func (mc *MongoDBClient) WatchClaimableJobs(ctx context.Context) (*mongo.ChangeStream, error) {
+	collection := mc.Database("referrer").Collection("job_queues")
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{
+			"$or": bson.A{
+				bson.M{"operationType": "insert"},
+				bson.M{"operationType": "update", "updateDescription.updatedFields.status": "PENDING"},
+			},
+		}}},
+		// Only the wake-up matters, not the document.
+		bson.D{{Key: "$project", Value: bson.M{"operationType": 1}}},
+	}
+	return collection.Watch(ctx, pipeline)
+}
+
+// GetLatestJob returns the user's most recent job of the `kind`, whether it's still queued or dead.
+// It returns mongo.ErrNoDocuments when the user has none.
+func (mc *MongoDBClient) GetLatestJob(userEmail, kind string) (*JobQueue, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	filter := bson.M{"userEmailAddress": userEmail, "kind": kind}
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetProjection(bson.M{"payload": 0})
+
+	var latest *JobQueue
+	for _, name := range []string{"job_queues", "dead_jobs"} {
+		var job JobQueue
+		err := mc.Database("referrer").Collection(name).FindOne(ctx, filter, opts).Decode(&job)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil || job.CreatedAt.After(latest.CreatedAt) {
+			latest = &job
+		}
+	}
+	if latest == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+	return latest, nil
+}
+
+// HasActiveJob tells whether the user has a job of the `kind` PENDING or IN_PROGRESS.
+func (mc *MongoDBClient) HasActiveJob(userEmail, kind string) (bool, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("job_queues")
+	count, err := collection.CountDocuments(ctx, bson.M{
+		"userEmailAddress": userEmail,
+		"kind":             kind,
+		"status":           bson.M{"$in": bson.A{"PENDING", "IN_PROGRESS"}},
+	}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetUsersAtJobCapacity lists the users having at least `limit` jobs IN_PROGRESS.
+func (mc *MongoDBClient) GetUsersAtJobCapacity(limit int) ([]string, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("job_queues")
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"status": "IN_PROGRESS"}}},
+		bson.D{{Key: "$group", Value: bson.M{"_id": "$userEmailAddress", "inProgress": bson.M{"$sum": 1}}}},
+		bson.D{{Key: "$match", Value: bson.M{"inProgress": bson.M{"$gte": limit}}}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		UserEmail string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	users := make([]string, 0, len(results))
+	for _, r := range results {
+		users = append(users, r.UserEmail)
+	}
+	return users, nil
+}
+
+// BackfillLegacyJobKinds stores the kind, and the stage of their `jobType`, on the jobs queued before job kinds existed,
+// so they're found by their kind. The worker pool only maps them in memory when it claims them.
+func (mc *MongoDBClient) BackfillLegacyJobKinds() (int64, error) {
+	ctx, cancel := getContextWithTimeout(30)
+	defer cancel()
+
+	var backfilled int64
+	for _, name := range []string{"job_queues", "dead_jobs"} {
+		collection := mc.Database("referrer").Collection(name)
+		for jobType := JobType(EXTRACT_CONTENT); jobType <= EMAIL_NOTIFICATION; jobType++ {
+			m, err := collection.UpdateMany(ctx,
+				bson.M{"kind": bson.M{"$in": bson.A{nil, ""}}, "jobType": jobType},
+				bson.M{
+					"$set":   bson.M{"kind": LEGACY_JOB_KIND, "stage": jobType.String()},
+					"$unset": bson.M{"jobType": ""},
+				},
+			)
+			if err != nil {
+				return backfilled, err
+			}
+			backfilled += m.ModifiedCount
+		}
+		m, err := collection.UpdateMany(ctx,
+			bson.M{"kind": bson.M{"$in": bson.A{nil, ""}}},
+			bson.M{"$set": bson.M{"kind": LEGACY_JOB_KIND}},
+		)
+		if err != nil {
+			return backfilled, err
+		}
+		backfilled += m.ModifiedCount
+	}
+	return backfilled, nil
+}
+
+// CountUserJobsInProgress counts the user's jobs IN_PROGRESS.
+func (mc *MongoDBClient) CountUserJobsInProgress(userEmail string) (int64, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("job_queues")
+	return collection.CountDocuments(ctx, bson.M{"userEmailAddress": userEmail, "status": "IN_PROGRESS"})
+}
+
+// UnclaimJob returns a job just claimed by `workerID` to PENDING, as it was before the claim.
+// Like UpdateJobState it only applies when the queued job is still at `job.Version`.
+func (mc *MongoDBClient) UnclaimJob(job *JobQueue, workerID string) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("job_queues")
+	m, err := collection.UpdateOne(ctx,
+		bson.M{"_id": job.ID, "status": "IN_PROGRESS", "leaseOwner": workerID, "version": job.Version},
+		bson.M{
+			"$set":   bson.M{"status": "PENDING", "updatedAt": time.Now()},
+			"$unset": bson.M{"leaseOwner": "", "leaseExpiresAt": ""},
+			"$inc":   bson.M{"version": 1},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if m.MatchedCount == 0 {
+		return fmt.Errorf("%w: job=%s, version=%d", ErrJobVersionConflict, job.ID.Hex(), job.Version)
+	}
+	job.Version++
+	return nil
+}
+
+// MoveJobToDeadLetter moves the job out of the queue into the `dead_jobs` collection.
+// Like UpdateJobState it only applies when the queued job is still at `job.Version`.
+func (mc *MongoDBClient) MoveJobToDeadLetter(job *JobQueue) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	dead := DeadJob{JobQueue: *job, DeadAt: time.Now()}
+	dead.UpdatedAt = dead.DeadAt
+	dead.NextRunAt = nil
+	dead.LeaseOwner = ""
+	dead.LeaseExpiresAt = nil
+
+	deadJobs := mc.Database("referrer").Collection("dead_jobs")
+	if _, err := deadJobs.ReplaceOne(ctx, bson.M{"_id": job.ID}, dead, options.Replace().SetUpsert(true)); err != nil {
+		return err
+	}
+
+	collection := mc.Database("referrer").Collection("job_queues")
+	m, err := collection.DeleteOne(ctx, bson.M{"_id": job.ID, "version": job.Version})
+	if err != nil {
+		return err
+	}
+	if m.DeletedCount == 0 {
+		// Someone else owns the job now, it isn't dead.
+		deadJobs.DeleteOne(ctx, bson.M{"_id": job.ID})
+		return fmt.Errorf("%w: job=%s, version=%d", ErrJobVersionConflict, job.ID.Hex(), job.Version)
+	}
+	return nil
+}
+
+// ListDeadJobs lists the dead jobs, most recently dead first, optionally filtered by `kind`.
+// Only the jobs with an ID lower than the `cursor` are returned, a nil cursor starts from the latest one.
+func (mc *MongoDBClient) ListDeadJobs(kind string, cursor primitive.ObjectID, limit int) ([]*DeadJob, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("dead_jobs")
+
+	filter := bson.M{}
+	if kind != "" {
+		filter["kind"] = kind
+	}
+	if !cursor.IsZero() {
+		filter["_id"] = bson.M{"$lt": cursor}
+	}
+
+	cur, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": -1}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	jobs := []*DeadJob{}
+	if err := cur.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// GetDeadJob fetches a dead job by its ID.
+func (mc *MongoDBClient) GetDeadJob(id primitive.ObjectID) (*DeadJob, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("dead_jobs")
+
+	var job DeadJob
+	if err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RequeueDeadJob puts a dead job back into the queue as PENDING, at the stage it died in, with its attempts reset.
+func (mc *MongoDBClient) RequeueDeadJob(id primitive.ObjectID) (*JobQueue, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	dead, err := mc.GetDeadJob(id)
+	if err != nil {
+		return nil, err
+	}
+
+	job := dead.JobQueue
+	job.Status = "PENDING"
+	job.Attempts = 0
+	job.NextRunAt = nil
+	job.UpdatedAt = time.Now()
+
+	collection := mc.Database("referrer").Collection("job_queues")
+	if _, err := collection.InsertOne(ctx, job); err != nil {
+		return nil, err
+	}
+
+	deadJobs := mc.Database("referrer").Collection("dead_jobs")
+	if _, err := deadJobs.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// JobQueueDepth counts the queued jobs of a priority and status.
+// Scheduled counts the PENDING ones which aren't due yet, delayed or waiting for a retry.
+type JobQueueDepth struct {
+	Priority  int    `json:"priority" bson:"priority"`
+	Status    string `json:"status" bson:"status"`
+	Count     int64  `json:"count" bson:"count"`
+	Scheduled int64  `json:"scheduled" bson:"scheduled"`
+}
+
+// GetJobQueueDepth counts the PENDING and IN_PROGRESS jobs by priority and status.
+func (mc *MongoDBClient) GetJobQueueDepth() ([]*JobQueueDepth, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("job_queues")
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"status": bson.M{"$in": bson.A{"PENDING", "IN_PROGRESS"}}}}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"priority": bson.M{"$ifNull": bson.A{"$priority", JOB_PRIORITY_LOW}}, "status": "$status"},
+			"count": bson.M{"$sum": 1},
+			"scheduled": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$nextRunAt", time.Now()}}, 1, 0,
+			}}},
+		}}},
+		bson.D{{Key: "$project", Value: bson.M{
+			"_id":       0,
+			"priority":  "$_id.priority",
+			"status":    "$_id.status",
+			"count":     1,
+			"scheduled": 1,
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "priority", Value: -1}, {Key: "status", Value: 1}}}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	depths := []*JobQueueDepth{}
+	if err := cursor.All(ctx, &depths); err != nil {
+		return nil, err
+	}
+	return depths, nil
+}
+
+// JobStageDepth counts the queued jobs of a kind, stage and status.
+type JobStageDepth struct {
+	Kind   string `json:"kind" bson:"kind"`
+	Stage  string `json:"stage" bson:"stage"`
+	Status string `json:"status" bson:"status"`
+	Count  int64  `json:"count" bson:"count"`
+}
+
+// GetJobQueueDepthByStage counts the jobs of the queue by kind, stage and status.
+// Completed jobs pile up over time, only the PENDING and IN_PROGRESS ones are counted.
+func (mc *MongoDBClient) GetJobQueueDepthByStage() ([]*JobStageDepth, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("job_queues")
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"status": bson.M{"$in": bson.A{"PENDING", "IN_PROGRESS"}}}}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"kind": "$kind", "stage": "$stage", "status": "$status"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		bson.D{{Key: "$project", Value: bson.M{
+			"_id":    0,
+			"kind":   "$_id.kind",
+			"stage":  "$_id.stage",
+			"status": "$_id.status",
+			"count":  1,
+		}}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	depths := []*JobStageDepth{}
+	if err := cursor.All(ctx, &depths); err != nil {
+		return nil, err
+	}
+	return depths, nil
 }
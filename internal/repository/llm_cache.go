@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LLMCacheEntry is a cached model answer, keyed by the hash of the model, the prompt version and the inputs.
+// The entries expire through the TTL index on `expiresAt`.
+type LLMCacheEntry struct {
+	Key     string    `json:"key" bson:"_id"`
+	Method  string    `json:"method" bson:"method"`
+	Backend string    `json:"backend" bson:"backend"`
+	Model   string    `json:"model" bson:"model"`
+	Prompt  PromptRef `json:"prompt" bson:"prompt"`
+	Text    string    `json:"text" bson:"text"`
+	// SizeBytes is the size of the cached answer, counted against the cache size limit.
+	SizeBytes int       `json:"sizeBytes" bson:"sizeBytes"`
+	Hits      int       `json:"hits" bson:"hits"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+// GetLLMCacheEntry returns the live entry of the key, nil when there's none, and counts the hit.
+func (mc *MongoDBClient) GetLLMCacheEntry(key string) (*LLMCacheEntry, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("llm_cache")
+
+	// The TTL monitor only runs every minute, the expired entries are filtered out.
+	var entry LLMCacheEntry
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key, "expiresAt": bson.M{"$gt": time.Now()}},
+		bson.M{"$inc": bson.M{"hits": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// PutLLMCacheEntry stores the entry, replacing the one of the same key.
+func (mc *MongoDBClient) PutLLMCacheEntry(entry *LLMCacheEntry) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("llm_cache")
+	entry.SizeBytes = len(entry.Text)
+	entry.CreatedAt = time.Now()
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": entry.Key}, entry, options.Replace().SetUpsert(true))
+	return err
+}
+
+// TrimLLMCache evicts the oldest entries until the cached answers fit in `maxBytes`, and returns how many were evicted.
+func (mc *MongoDBClient) TrimLLMCache(maxBytes int64) (int64, error) {
+	ctx, cancel := getContextWithTimeout(60)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("llm_cache")
+
+	cursor, err := collection.Find(ctx, bson.M{},
+		options.Find().
+			SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+			SetProjection(bson.M{"sizeBytes": 1}),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var total int64
+	evicted := make([]string, 0)
+	for cursor.Next(ctx) {
+		var entry struct {
+			Key       string `bson:"_id"`
+			SizeBytes int64  `bson:"sizeBytes"`
+		}
+		if err := cursor.Decode(&entry); err != nil {
+			return 0, err
+		}
+		total += entry.SizeBytes
+		if total > maxBytes {
+			evicted = append(evicted, entry.Key)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return 0, err
+	}
+	if len(evicted) == 0 {
+		return 0, nil
+	}
+
+	result, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": evicted}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
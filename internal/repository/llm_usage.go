@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LLMUsageRecord is the token usage of a single LLM call, stored in the `llm_usage` collection.
+type LLMUsageRecord struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserEmail string             `json:"userEmail" bson:"userEmail"`
+	// Feature is the core method which called the model, e.g. `DraftColdEmailMessageLLM`.
+	Feature          string    `json:"feature" bson:"feature"`
+	Backend          string    `json:"backend" bson:"backend"`
+	Model            string    `json:"model" bson:"model"`
+	PromptTokens     int       `json:"promptTokens" bson:"promptTokens"`
+	CompletionTokens int       `json:"completionTokens" bson:"completionTokens"`
+	TotalTokens      int       `json:"totalTokens" bson:"totalTokens"`
+	LatencyMs        int64     `json:"latencyMs" bson:"latencyMs"`
+	EstimatedCostUSD float64   `json:"estimatedCostUsd" bson:"estimatedCostUsd"`
+	EstimatedTokens  bool      `json:"estimatedTokens,omitempty" bson:"estimatedTokens,omitempty"` // the generation stopped before its usage was reported
+	CreatedAt        time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// LLMUsageTotals sums the usage records of a group, see AggregateLLMUsage.
+type LLMUsageTotals struct {
+	// Key is the user, feature or model of the group.
+	Key              string  `json:"key" bson:"_id"`
+	Calls            int     `json:"calls" bson:"calls"`
+	PromptTokens     int64   `json:"promptTokens" bson:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens" bson:"completionTokens"`
+	TotalTokens      int64   `json:"totalTokens" bson:"totalTokens"`
+	EstimatedCostUSD float64 `json:"estimatedCostUsd" bson:"estimatedCostUsd"`
+}
+
+// LLM usage groupings of AggregateLLMUsage.
+const (
+	LLM_USAGE_BY_USER    = "userEmail"
+	LLM_USAGE_BY_FEATURE = "feature"
+	LLM_USAGE_BY_MODEL   = "model"
+)
+
+// InsertLLMUsage stores the usage record of a call.
+func (mc *MongoDBClient) InsertLLMUsage(record *LLMUsageRecord) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("llm_usage")
+	record.CreatedAt = time.Now()
+
+	result, err := collection.InsertOne(ctx, record)
+	if err != nil {
+		return err
+	}
+	record.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// SumLLMTokens returns the tokens used by the user since `monthStart`, and since `dayStart` which must come after it.
+func (mc *MongoDBClient) SumLLMTokens(userEmail string, dayStart, monthStart time.Time) (daily, monthly int64, err error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("llm_usage")
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"userEmail": userEmail, "createdAt": bson.M{"$gte": monthStart}}}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":     nil,
+			"monthly": bson.M{"$sum": "$totalTokens"},
+			"daily": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$gte": bson.A{"$createdAt", dayStart}}, "$totalTokens", 0,
+			}}},
+		}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []struct {
+		Daily   int64 `bson:"daily"`
+		Monthly int64 `bson:"monthly"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return 0, 0, err
+	}
+	if len(totals) == 0 {
+		return 0, 0, nil
+	}
+	return totals[0].Daily, totals[0].Monthly, nil
+}
+
+// AggregateLLMUsage sums the usage records since `since` by user, feature or model, the biggest consumers first.
+// The records are restricted to the user when `userEmail` is set.
+func (mc *MongoDBClient) AggregateLLMUsage(userEmail, groupBy string, since time.Time, limit int) ([]*LLMUsageTotals, error) {
+	ctx, cancel := getContextWithTimeout(30)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("llm_usage")
+	match := bson.M{"createdAt": bson.M{"$gte": since}}
+	if userEmail != "" {
+		match["userEmail"] = userEmail
+	}
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: match}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":              "$" + groupBy,
+			"calls":            bson.M{"$sum": 1},
+			"promptTokens":     bson.M{"$sum": "$promptTokens"},
+			"completionTokens": bson.M{"$sum": "$completionTokens"},
+			"totalTokens":      bson.M{"$sum": "$totalTokens"},
+			"estimatedCostUsd": bson.M{"$sum": "$estimatedCostUsd"},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "totalTokens", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	totals := make([]*LLMUsageTotals, 0)
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// ListLLMUsage lists the latest usage records of the user, newest first.
+func (mc *MongoDBClient) ListLLMUsage(userEmail string, limit int) ([]*LLMUsageRecord, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("llm_usage")
+	cursor, err := collection.Find(ctx,
+		bson.M{"userEmail": userEmail},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	records := make([]*LLMUsageRecord, 0)
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
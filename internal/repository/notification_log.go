@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Statuses of a notification log entry.
+const (
+	NOTIFICATION_SENT    = "SENT"
+	NOTIFICATION_SKIPPED = "SKIPPED"
+	NOTIFICATION_FAILED  = "FAILED"
+)
+
+// NotificationLog records a notification sent, or deliberately not sent, to a user.
+type NotificationLog struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserEmail string             `json:"userEmail" bson:"userEmail"`
+	Kind      string             `json:"kind" bson:"kind"`
+	Channel   string             `json:"channel" bson:"channel"`
+	Subject   string             `json:"subject" bson:"subject"`
+	Status    string             `json:"status" bson:"status"`
+	Reason    string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	// JobID is the job the notification is about, if any.
+	JobID     primitive.ObjectID `json:"jobId,omitempty" bson:"jobId,omitempty"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// CreateNotificationLog stores a notification log entry.
+func (mc *MongoDBClient) CreateNotificationLog(entry *NotificationLog) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	entry.CreatedAt = time.Now()
+
+	collection := mc.Database("referrer").Collection("notifications_log")
+	result, err := collection.InsertOne(ctx, entry)
+	if err != nil {
+		return err
+	}
+	entry.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// HasNotificationBeenSent tells whether the `kind` notification about the job was already sent.
+func (mc *MongoDBClient) HasNotificationBeenSent(jobID primitive.ObjectID, kind string) (bool, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("notifications_log")
+	count, err := collection.CountDocuments(ctx, bson.M{"jobId": jobID, "kind": kind, "status": NOTIFICATION_SENT})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Outreach policy actions.
+const (
+	OUTREACH_POLICY_OFF   = "OFF"
+	OUTREACH_POLICY_WARN  = "WARN"
+	OUTREACH_POLICY_BLOCK = "BLOCK"
+)
+
+// OutreachPolicy holds the per user rules guarding against duplicate outreach.
+type OutreachPolicy struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserEmail string             `json:"userEmail" bson:"userEmail"`
+
+	// Action decides what happens when a rule is violated: OFF, WARN or BLOCK.
+	Action string `json:"action" bson:"action"`
+	// RecipientCooldownDays is the number of days before the same recipient can be contacted again.
+	RecipientCooldownDays int `json:"recipientCooldownDays" bson:"recipientCooldownDays"`
+	// CompanyWeeklyLimit caps how many people at the same company domain are contacted within 7 days.
+	CompanyWeeklyLimit int `json:"companyWeeklyLimit" bson:"companyWeeklyLimit"`
+
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// DefaultOutreachPolicy is applied to users who never configured their own policy.
+func DefaultOutreachPolicy(userEmail string) *OutreachPolicy {
+	return &OutreachPolicy{
+		UserEmail:             userEmail,
+		Action:                OUTREACH_POLICY_WARN,
+		RecipientCooldownDays: 14,
+		CompanyWeeklyLimit:    5,
+	}
+}
+
+// GetOutreachPolicy fetches the user's outreach policy, falling back to the default one.
+func (mc *MongoDBClient) GetOutreachPolicy(userEmail string) (*OutreachPolicy, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("outreach_policies")
+
+	var p OutreachPolicy
+	err := collection.FindOne(ctx, bson.M{"userEmail": userEmail}).Decode(&p)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return DefaultOutreachPolicy(userEmail), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpsertOutreachPolicy creates or replaces the user's outreach policy.
+func (mc *MongoDBClient) UpsertOutreachPolicy(p *OutreachPolicy) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("outreach_policies")
+
+	p.UpdatedAt = time.Now()
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"userEmail": p.UserEmail},
+		bson.M{"$set": bson.M{
+			"action":                p.Action,
+			"recipientCooldownDays": p.RecipientCooldownDays,
+			"companyWeeklyLimit":    p.CompanyWeeklyLimit,
+			"updatedAt":             p.UpdatedAt,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// GetLastEmailToRecipient finds the latest referral email sent by `from` to `recipient` since the given time.
+// It returns nil when the recipient has not been contacted in that window.
+// Unlike GetRecentRecipientsAtDomain the drafts aren't looked at: the email drafted to a recipient is sent right after,
+// its own draft must not put the recipient in cooldown.
+func (mc *MongoDBClient) GetLastEmailToRecipient(from, recipient string, since time.Time) (*ReferralMailbox, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("referral_mailbox")
+
+	var mail ReferralMailbox
+	err := collection.FindOne(ctx, bson.M{
+		"from":      from,
+		"to":        bson.M{"$regex": "^" + regexp.QuoteMeta(recipient) + "$", "$options": "i"},
+		"createdAt": bson.M{"$gte": since},
+	}, options.FindOne().SetSort(bson.M{"createdAt": -1})).Decode(&mail)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mail, nil
+}
+
+// GetRecentRecipientsAtDomain lists the distinct addresses at `domain` which `from` has emailed
+// or drafted an email to since the given time. Both `referral_mailbox` and `ai_email_drafts` are looked at:
+// a drafted recipient counts towards the company limit, and isn't counted again once the draft is sent.
+func (mc *MongoDBClient) GetRecentRecipientsAtDomain(from, domain string, since time.Time) ([]string, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	domainRegex := bson.M{"$regex": "@" + regexp.QuoteMeta(domain) + "$", "$options": "i"}
+	seen := map[string]bool{}
+
+	mailboxRecipients, err := mc.Database("referrer").Collection("referral_mailbox").Distinct(ctx, "to", bson.M{
+		"from":      from,
+		"to":        domainRegex,
+		"createdAt": bson.M{"$gte": since},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	draftRecipients, err := mc.Database("referrer").Collection("ai_email_drafts").Distinct(ctx, "to", bson.M{
+		"userEmailAddress": from,
+		"to":               domainRegex,
+		"createdAt":        bson.M{"$gte": since},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := []string{}
+	for _, v := range append(mailboxRecipients, draftRecipients...) {
+		addr, ok := v.(string)
+		if !ok {
+			continue
+		}
+		addr = strings.ToLower(addr)
+		// `to` arrays also hold the sender itself and other domains
+		if seen[addr] || strings.EqualFold(addr, from) || !strings.HasSuffix(addr, "@"+strings.ToLower(domain)) {
+			continue
+		}
+		seen[addr] = true
+		recipients = append(recipients, addr)
+	}
+	return recipients, nil
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrPromptVersionNotFound is returned when no version of the prompt has the number.
+var ErrPromptVersionNotFound = errors.New("prompt version not found")
+
+// PromptRef identifies the prompt version which produced a generated content.
+type PromptRef struct {
+	ID      string `json:"id" bson:"id"`
+	Version int    `json:"version" bson:"version"`
+}
+
+// PromptVersion is a version of a prompt template. The built-in versions are embedded in the service,
+// the other ones are stored in the `prompt_versions` collection.
+// Version numbers are shared by all the owners of a prompt, a version is either global or owned by a user.
+type PromptVersion struct {
+	ID       primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	PromptID string             `json:"promptId" bson:"promptId"`
+	Version  int                `json:"version" bson:"version"`
+	// OwnerEmail is the user the version belongs to, empty for the global versions.
+	OwnerEmail  string `json:"ownerEmail,omitempty" bson:"ownerEmail"`
+	Description string `json:"description" bson:"description"`
+	Template    string `json:"template" bson:"template"`
+	// ForkedFrom is the version this one was forked from.
+	ForkedFrom int       `json:"forkedFrom,omitempty" bson:"forkedFrom,omitempty"`
+	Builtin    bool      `json:"builtin" bson:"-"`
+	CreatedBy  string    `json:"createdBy,omitempty" bson:"createdBy"`
+	CreatedAt  time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// PromptActivation records the version of a prompt used for an owner, the global one when the owner is empty.
+type PromptActivation struct {
+	PromptID    string    `json:"promptId" bson:"promptId"`
+	OwnerEmail  string    `json:"ownerEmail,omitempty" bson:"ownerEmail"`
+	Version     int       `json:"version" bson:"version"`
+	ActivatedBy string    `json:"activatedBy" bson:"activatedBy"`
+	ActivatedAt time.Time `json:"activatedAt" bson:"activatedAt"`
+}
+
+// CreatePromptVersion stores the version under the next free version number, never below `minVersion`.
+func (mc *MongoDBClient) CreatePromptVersion(v *PromptVersion, minVersion int) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("prompt_versions")
+	v.CreatedAt = time.Now()
+
+	// Concurrent forks may pick the same number, the unique index sorts them out.
+	for attempt := 0; attempt < 3; attempt++ {
+		var latest PromptVersion
+		err := collection.FindOne(ctx, bson.M{"promptId": v.PromptID}, options.FindOne().SetSort(bson.M{"version": -1})).Decode(&latest)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		v.Version = max(latest.Version+1, minVersion)
+
+		result, err := collection.InsertOne(ctx, v)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		v.ID = result.InsertedID.(primitive.ObjectID)
+		return nil
+	}
+	return errors.New("unable to allocate a prompt version number")
+}
+
+// GetPromptVersion fetches a stored version of the prompt.
+func (mc *MongoDBClient) GetPromptVersion(promptID string, version int) (*PromptVersion, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("prompt_versions")
+
+	var v PromptVersion
+	err := collection.FindOne(ctx, bson.M{"promptId": promptID, "version": version}).Decode(&v)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrPromptVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// ListPromptVersions lists the stored versions of the prompt, newest first.
+// Only the global versions and the ones of `ownerEmail` are listed, unless `allOwners` is set.
+func (mc *MongoDBClient) ListPromptVersions(promptID, ownerEmail string, allOwners bool) ([]*PromptVersion, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("prompt_versions")
+	filter := bson.M{"promptId": promptID}
+	if !allOwners {
+		filter["ownerEmail"] = bson.M{"$in": bson.A{"", ownerEmail}}
+	}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"version": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	versions := make([]*PromptVersion, 0)
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetPromptActivations returns the activations of the prompt for the owner and the global one, when set.
+func (mc *MongoDBClient) GetPromptActivations(promptID, ownerEmail string) (owner, global *PromptActivation, err error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("prompt_activations")
+	cursor, err := collection.Find(ctx, bson.M{"promptId": promptID, "ownerEmail": bson.M{"$in": bson.A{"", ownerEmail}}})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var activations []*PromptActivation
+	if err := cursor.All(ctx, &activations); err != nil {
+		return nil, nil, err
+	}
+	for _, a := range activations {
+		if a.OwnerEmail == "" {
+			global = a
+		} else {
+			owner = a
+		}
+	}
+	return owner, global, nil
+}
+
+// SetPromptActivation activates the version of the prompt for the owner, globally when the owner is empty.
+func (mc *MongoDBClient) SetPromptActivation(a *PromptActivation) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("prompt_activations")
+	a.ActivatedAt = time.Now()
+
+	_, err := collection.ReplaceOne(ctx,
+		bson.M{"promptId": a.PromptID, "ownerEmail": a.OwnerEmail},
+		a,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// DeletePromptActivation falls the owner back to the global version of the prompt.
+func (mc *MongoDBClient) DeletePromptActivation(promptID, ownerEmail string) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("prompt_activations")
+	_, err := collection.DeleteOne(ctx, bson.M{"promptId": promptID, "ownerEmail": ownerEmail})
+	return err
+}
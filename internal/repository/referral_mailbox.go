@@ -3,6 +3,9 @@ package repository
 import (
 	"time"
 
+	mongobson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -15,4 +18,55 @@ type ReferralMailbox struct {
 	Body             string        `json:"body" bson:"body"`
 	TailoredResumeID string        `json:"tailoredResumeId" bson:"tailoredResumeId"`
 	CreatedAt        time.Time     `json:"createdAt" bson:"createdAt"`
+	// FollowUpRemindedAt is when the sender was reminded to follow up on the email.
+	FollowUpRemindedAt *time.Time `json:"followUpRemindedAt,omitempty" bson:"followUpRemindedAt,omitempty"`
+	// Draft is the AI draft the email was written from, so its replies can be joined to the tone of the draft.
+	Draft *SentDraft `json:"draft,omitempty" bson:"draft,omitempty"`
+}
+
+// SentDraft refers to the AI draft variant a sent email was written from.
+type SentDraft struct {
+	DraftID primitive.ObjectID `json:"draftId" bson:"draftId"`
+	GroupID primitive.ObjectID `json:"groupId,omitempty" bson:"groupId,omitempty"`
+	Tone    string             `json:"tone,omitempty" bson:"tone,omitempty"`
+	Variant int                `json:"variant,omitempty" bson:"variant,omitempty"`
+}
+
+// ListEmailsDueForFollowUp returns the sent emails between `sentAfter` and `sentBefore` whose sender
+// wasn't reminded to follow up yet, oldest first.
+func (mc *MongoDBClient) ListEmailsDueForFollowUp(sentAfter, sentBefore time.Time, limit int) ([]*ReferralMailbox, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("referral_mailbox")
+	filter := mongobson.M{
+		"createdAt":          mongobson.M{"$gte": sentAfter, "$lte": sentBefore},
+		"followUpRemindedAt": mongobson.M{"$exists": false},
+	}
+	opts := options.Find().SetSort(mongobson.M{"createdAt": 1}).SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	mails := make([]*ReferralMailbox, 0)
+	if err := cursor.All(ctx, &mails); err != nil {
+		return nil, err
+	}
+	return mails, nil
+}
+
+// MarkFollowUpReminded flags the emails, by uuid, as reminded.
+func (mc *MongoDBClient) MarkFollowUpReminded(uuids []string) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("referral_mailbox")
+	_, err := collection.UpdateMany(ctx,
+		mongobson.M{"uuid": mongobson.M{"$in": uuids}},
+		mongobson.M{"$set": mongobson.M{"followUpRemindedAt": time.Now()}},
+	)
+	return err
 }
@@ -1,6 +1,14 @@
 package repository
 
-import "gopkg.in/mgo.v2/bson"
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
 
 type Skills struct {
 	ProgrammingLanguages []string `json:"programmingLanguages" bson:"programmingLanguages"`
@@ -36,17 +44,68 @@ type Achievement struct {
 	Detail string `json:"details" bson:"details"`
 }
 
+// ResumeInformation is the structured content of a user's resume, stored in the `resume_information` collection.
+// The fields tagged `llm:"-"` are filled in by the service, they're left out of the schema given to the model.
 type ResumeInformation struct {
-	ID bson.ObjectId `json:"id" bson:"_id,omitempty"`
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty" llm:"-"`
 
+	FullName         string           `json:"fullName" bson:"fullName"`
 	Email            string           `json:"email" bson:"email"`
 	Skills           Skills           `json:"skills" bson:"skills"`
 	SocialLinks      []SocialLink     `json:"socialLinks" bson:"socialLinks"`
-	WorkExperiences  []WorkExperience `json:"workExperineces" bson:"workExperiences"`
+	WorkExperiences  []WorkExperience `json:"workExperiences" bson:"workExperiences"`
 	PersonalProjects []PeronalProject `json:"personalProjects" bson:"personalProjects"`
 	Educations       []Education      `json:"educations" bson:"educations"`
 	Achievements     []Achievement    `json:"achievements" bson:"achievements"`
 
-	ProfileSummary   string `json:"profileSummary" bson:"profileSummary"`
-	ExtractedContent string `json:"extractedContent" bson:"extractedContent"`
+	ProfileSummary   string `json:"profileSummary" bson:"profileSummary" llm:"-"`
+	ExtractedContent string `json:"extractedContent" bson:"extractedContent" llm:"-"`
+
+	// EditedByUser is set once the user corrected the parsed information.
+	EditedByUser bool      `json:"editedByUser" bson:"editedByUser" llm:"-"`
+	UpdatedAt    time.Time `json:"updatedAt" bson:"updatedAt" llm:"-"`
+}
+
+// ErrResumeInformationNotFound is returned when the user's resume wasn't parsed yet.
+var ErrResumeInformationNotFound = errors.New("resume information not found")
+
+// GetResumeInformation fetches the structured resume of the user.
+func (mc *MongoDBClient) GetResumeInformation(email string) (*ResumeInformation, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("resume_information")
+
+	var info ResumeInformation
+	err := collection.FindOne(ctx, bson.M{"email": email}).Decode(&info)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrResumeInformationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// UpsertResumeInformation stores the structured resume of the user, replacing the previous one.
+func (mc *MongoDBClient) UpsertResumeInformation(info *ResumeInformation) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	info.UpdatedAt = time.Now()
+
+	collection := mc.Database("referrer").Collection("resume_information")
+	result := collection.FindOneAndReplace(ctx,
+		bson.M{"email": info.Email},
+		info,
+		options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.After).SetProjection(bson.M{"_id": 1}),
+	)
+	var stored struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := result.Decode(&stored); err != nil {
+		return err
+	}
+	info.ID = stored.ID
+	return nil
 }
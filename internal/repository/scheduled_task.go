@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrScheduledTaskNotFound is returned when no periodic task is registered under the name.
+var ErrScheduledTaskNotFound = errors.New("scheduled task not found")
+
+// Last run statuses of a scheduled task.
+const (
+	SCHEDULED_TASK_SUCCEEDED = "SUCCEEDED"
+	SCHEDULED_TASK_FAILED    = "FAILED"
+)
+
+// ScheduledTask is the shared state of a periodic task, one document per task for all the replicas.
+// The replica holding the lock is the only one running the task.
+type ScheduledTask struct {
+	Name        string    `json:"name" bson:"_id"`
+	Description string    `json:"description" bson:"description"`
+	Schedule    string    `json:"schedule" bson:"schedule"`
+	NextRunAt   time.Time `json:"nextRunAt" bson:"nextRunAt"`
+	// TriggeredBy is the admin who asked for a manual run, cleared once the run started.
+	TriggeredBy string `json:"triggeredBy,omitempty" bson:"triggeredBy,omitempty"`
+
+	LastRunAt      *time.Time `json:"lastRunAt,omitempty" bson:"lastRunAt,omitempty"`
+	LastRunBy      string     `json:"lastRunBy,omitempty" bson:"lastRunBy,omitempty"`
+	LastFinishedAt *time.Time `json:"lastFinishedAt,omitempty" bson:"lastFinishedAt,omitempty"`
+	LastDurationMs int64      `json:"lastDurationMs" bson:"lastDurationMs"`
+	LastStatus     string     `json:"lastStatus,omitempty" bson:"lastStatus,omitempty"`
+	LastError      string     `json:"lastError,omitempty" bson:"lastError,omitempty"`
+
+	LockedBy    string     `json:"lockedBy,omitempty" bson:"lockedBy,omitempty"`
+	LockedUntil *time.Time `json:"lockedUntil,omitempty" bson:"lockedUntil,omitempty"`
+	UpdatedAt   time.Time  `json:"updatedAt" bson:"updatedAt"`
+}
+
+// RegisterScheduledTask creates the task state on first start, or reschedules it when its cron expression changed.
+func (mc *MongoDBClient) RegisterScheduledTask(name, description, schedule string, nextRunAt time.Time) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("scheduled_tasks")
+	now := time.Now()
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": name, "schedule": bson.M{"$ne": schedule}},
+		bson.M{"$set": bson.M{"schedule": schedule, "nextRunAt": nextRunAt, "updatedAt": now}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = collection.UpdateOne(ctx,
+		bson.M{"_id": name},
+		bson.M{
+			"$set":         bson.M{"description": description},
+			"$setOnInsert": bson.M{"schedule": schedule, "nextRunAt": nextRunAt, "lastDurationMs": 0, "updatedAt": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// AcquireScheduledTask locks a due task for the owner until `lockFor` elapsed, and moves its next run to `nextRunAt`.
+// Returns the task as it was before the lock, or nil when the task isn't due or another replica holds it.
+func (mc *MongoDBClient) AcquireScheduledTask(name, owner string, lockFor time.Duration, nextRunAt time.Time) (*ScheduledTask, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("scheduled_tasks")
+	now := time.Now()
+	lockedUntil := now.Add(lockFor)
+
+	filter := bson.M{
+		"_id":       name,
+		"nextRunAt": bson.M{"$lte": now},
+		"$or": bson.A{
+			bson.M{"lockedUntil": bson.M{"$exists": false}},
+			bson.M{"lockedUntil": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"lockedBy":    owner,
+			"lockedUntil": lockedUntil,
+			"nextRunAt":   nextRunAt,
+			"lastRunAt":   now,
+			"lastRunBy":   owner,
+			"updatedAt":   now,
+		},
+		"$unset": bson.M{"triggeredBy": ""},
+	}
+
+	var task ScheduledTask
+	err := collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&task)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// CompleteScheduledTask records the outcome of the run and releases the lock held by the owner.
+func (mc *MongoDBClient) CompleteScheduledTask(name, owner string, startedAt time.Time, runErr error) error {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("scheduled_tasks")
+	now := time.Now()
+
+	set := bson.M{
+		"lastFinishedAt": now,
+		"lastDurationMs": now.Sub(startedAt).Milliseconds(),
+		"lastStatus":     SCHEDULED_TASK_SUCCEEDED,
+		"updatedAt":      now,
+	}
+	unset := bson.M{"lockedBy": "", "lockedUntil": "", "lastError": ""}
+	if runErr != nil {
+		set["lastStatus"] = SCHEDULED_TASK_FAILED
+		set["lastError"] = runErr.Error()
+		delete(unset, "lastError")
+	}
+
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": name, "lockedBy": owner}, bson.M{"$set": set, "$unset": unset})
+	return err
+}
+
+// ListScheduledTasks returns every registered task, by name.
+func (mc *MongoDBClient) ListScheduledTasks() ([]*ScheduledTask, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("scheduled_tasks")
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tasks := make([]*ScheduledTask, 0)
+	if err := cursor.All(ctx, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// RequestScheduledTaskRun makes the task due right away, the next scheduler tick of any replica runs it.
+func (mc *MongoDBClient) RequestScheduledTaskRun(name, requestedBy string) (*ScheduledTask, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("scheduled_tasks")
+	now := time.Now()
+
+	var task ScheduledTask
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": name},
+		bson.M{"$set": bson.M{"nextRunAt": now, "triggeredBy": requestedBy, "updatedAt": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&task)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrScheduledTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
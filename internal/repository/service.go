@@ -8,6 +8,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -131,8 +132,8 @@ func (mc *MongoDBClient) FindUserByEmailAndPassword(email, password string) (*Us
 
 }
 
-// CreateEmailInMailbox stores the email into mailbox
-func (mc *MongoDBClient) CreateEmailInMailbox(from string, to []string, subject, body, tailoredResumeId string) error {
+// CreateEmailInMailbox stores the email into mailbox, along with the AI draft it was written from when there's one.
+func (mc *MongoDBClient) CreateEmailInMailbox(from string, to []string, subject, body, tailoredResumeId string, draft *SentDraft) error {
 	// Get context
 	ctx, cancel := getContextWithTimeout(10)
 	defer cancel()
@@ -145,6 +146,7 @@ func (mc *MongoDBClient) CreateEmailInMailbox(from string, to []string, subject,
 		Body:             body,
 		TailoredResumeID: tailoredResumeId,
 		CreatedAt:        time.Now(),
+		Draft:            draft,
 	}
 
 	collection := mc.Database("referrer").Collection("referral_mailbox")
@@ -199,32 +201,63 @@ func (mc *MongoDBClient) GetLatestEmailsByFilter(filterCondn bson.M, limit, offs
 	return mails, totalCount, nil
 }
 
-func (mc *MongoDBClient) CreateAiDraftEmail(from, to, companyName, templateType, jobDescription, userProfileSummary, mailSubject, mailBody, tailoredResumeID string, jobUrls []string) (*AiDraftColdEmail, error) {
+// CreateAiDraftEmail stores an AI drafted email.
+func (mc *MongoDBClient) CreateAiDraftEmail(draftEmail *AiDraftColdEmail) error {
 	// Get context
 	ctx, cancel := getContextWithTimeout(10)
 	defer cancel()
 
-	draftEmail := &AiDraftColdEmail{
-		UserEmailAddress: from,
-		From:             from,
-		To:               to,
-		CompanyName:      companyName,
-		JobUrls:          jobUrls,
-		JobDescription:   jobDescription,
-		TemplateType:     templateType,
-		MailSubject:      mailSubject,
-		Mailbody:         mailBody,
-		TailoredResumeID: tailoredResumeID,
-		CreatedAt:        time.Now(),
-	}
+	draftEmail.CreatedAt = time.Now()
 
 	collection := mc.Database("referrer").Collection("ai_email_drafts")
-	_, err := collection.InsertOne(ctx, draftEmail)
+	result, err := collection.InsertOne(ctx, draftEmail)
 	if err != nil {
+		return err
+	}
+	draftEmail.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// GetAiDraftEmail fetches the user's draft. It returns mongo.ErrNoDocuments when the user has no such draft.
+func (mc *MongoDBClient) GetAiDraftEmail(userEmail string, draftID primitive.ObjectID) (*AiDraftColdEmail, error) {
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("ai_email_drafts")
+
+	var draft AiDraftColdEmail
+	if err := collection.FindOne(ctx, bson.M{"_id": draftID, "userEmailAddress": userEmail}).Decode(&draft); err != nil {
 		return nil, err
 	}
+	return &draft, nil
+}
+
+// MarkAiDraftChosen records that the user sent the draft. Among the variants of its group,
+// only the last one sent stays chosen. It returns mongo.ErrNoDocuments when the user has no such draft.
+func (mc *MongoDBClient) MarkAiDraftChosen(userEmail string, draftID primitive.ObjectID) error {
+	// Get context
+	ctx, cancel := getContextWithTimeout(10)
+	defer cancel()
+
+	collection := mc.Database("referrer").Collection("ai_email_drafts")
 
-	return draftEmail, nil
+	var draft AiDraftColdEmail
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": draftID, "userEmailAddress": userEmail},
+		bson.M{"$set": bson.M{"chosenAt": time.Now()}},
+	).Decode(&draft)
+	if err != nil {
+		return err
+	}
+	if draft.GroupID.IsZero() {
+		return nil
+	}
+
+	_, err = collection.UpdateMany(ctx,
+		bson.M{"groupId": draft.GroupID, "_id": bson.M{"$ne": draftID}},
+		bson.M{"$unset": bson.M{"chosenAt": ""}},
+	)
+	return err
 }
 
 // ProfileAnalytics struct is in ai_draft_email.go, but we can extend it here for now for clarity.
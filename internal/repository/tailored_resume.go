@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -15,7 +16,11 @@ type TailoredResume struct {
 	ResumeMarkdown string             `bson:"resumeMarkdown" json:"resumeMarkdown"`
 	CompanyName    string             `bson:"companyName" json:"companyName"`
 	JobRole        string             `bson:"jobRole" json:"jobRole"`
-	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+	// Prompt is the prompt version which tailored the resume.
+	Prompt PromptRef `bson:"prompt" json:"prompt"`
+	// ATSScore is the keyword match of the resume against its job description, from 0 to 100, see the ats package.
+	ATSScore  *int      `bson:"atsScore,omitempty" json:"atsScore,omitempty"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
 	// Add more metadata fields as needed
 }
 
@@ -41,21 +46,39 @@ func (mc *MongoDBClient) GetTailoredResumeByID(ctx context.Context, id primitive
 	return &tr, nil
 }
 
-// UpdateTailoredResumeMarkdown updates the resumeMarkdown of a tailored resume by ID
-func (mc *MongoDBClient) UpdateTailoredResumeMarkdown(ctx context.Context, id primitive.ObjectID, resumeMarkdown string) error {
+// UpdateTailoredResumeMarkdown updates the resumeMarkdown of a tailored resume by ID, along with its ATS score
+func (mc *MongoDBClient) UpdateTailoredResumeMarkdown(ctx context.Context, id primitive.ObjectID, resumeMarkdown string, atsScore int) error {
 	collection := mc.Database("referrer").Collection("tailored_resumes")
-	_, err := collection.UpdateOne(ctx, primitive.M{"_id": id}, primitive.M{"$set": primitive.M{"resumeMarkdown": resumeMarkdown}})
+	_, err := collection.UpdateOne(ctx, primitive.M{"_id": id}, primitive.M{"$set": primitive.M{"resumeMarkdown": resumeMarkdown, "atsScore": atsScore}})
 	return err
 }
 
-// GetLatestTailoredResumesByUser fetches the latest 10 tailored resumes for a user by userId, optionally filtered by companyName
-func (mc *MongoDBClient) GetLatestTailoredResumesByUser(ctx context.Context, userId string, companyName string) ([]*TailoredResume, error) {
+// UpdateTailoredResumeATSScore stores the ATS score of a tailored resume by ID
+func (mc *MongoDBClient) UpdateTailoredResumeATSScore(ctx context.Context, id primitive.ObjectID, atsScore int) error {
+	collection := mc.Database("referrer").Collection("tailored_resumes")
+	_, err := collection.UpdateOne(ctx, primitive.M{"_id": id}, primitive.M{"$set": primitive.M{"atsScore": atsScore}})
+	return err
+}
+
+// Orders of the tailored resumes list.
+const (
+	TAILORED_RESUMES_BY_RECENT    = "createdAt"
+	TAILORED_RESUMES_BY_ATS_SCORE = "atsScore"
+)
+
+// GetLatestTailoredResumesByUser fetches the latest 10 tailored resumes for a user by userId, optionally filtered by companyName.
+// With TAILORED_RESUMES_BY_ATS_SCORE, the best scores come first, then the unscored resumes.
+func (mc *MongoDBClient) GetLatestTailoredResumesByUser(ctx context.Context, userId string, companyName string, sortBy string) ([]*TailoredResume, error) {
 	collection := mc.Database("referrer").Collection("tailored_resumes")
 	filter := primitive.M{"userId": userId}
 	if companyName != "" {
 		filter["companyName"] = primitive.M{"$regex": companyName, "$options": "i"}
 	}
-	findOptions := options.Find().SetSort(primitive.M{"createdAt": -1}).SetLimit(10)
+	sort := bson.D{{Key: "createdAt", Value: -1}}
+	if sortBy == TAILORED_RESUMES_BY_ATS_SCORE {
+		sort = bson.D{{Key: "atsScore", Value: -1}, {Key: "createdAt", Value: -1}}
+	}
+	findOptions := options.Find().SetSort(sort).SetLimit(10)
 
 	cursor, err := collection.Find(ctx, filter, findOptions)
 	if err != nil {
@@ -1,9 +1,11 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	echojwt "github.com/labstack/echo-jwt"
@@ -11,24 +13,27 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/sounishnath003/customgo-mailer-service/internal/core"
 	"github.com/sounishnath003/customgo-mailer-service/internal/handlers"
+	"github.com/sounishnath003/customgo-mailer-service/internal/metrics"
 	"github.com/sounishnath003/customgo-mailer-service/internal/utils"
 	"golang.org/x/time/rate"
 )
 
 type Server struct {
 	co *core.Core
+	e  *echo.Echo
 }
 
 func NewServer(co *core.Core) *Server {
 	return &Server{
 		co: co,
+		e:  echo.New(),
 	}
 }
 
 // Start - run a ever blocking server spawn to run the webservice backend.
 // Make sure if you have other I/O bounded tasks, are runs on goroutines.
 func (s *Server) Start() error {
-	e := echo.New()
+	e := s.e
 
 	// Add context
 	// Declare the custom context in the route handler
@@ -43,13 +48,14 @@ func (s *Server) Start() error {
 
 	// Add middlewares
 	e.Use(middleware.RemoveTrailingSlash())
+	e.Use(metrics.EchoMiddleware)
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins:     []string{"http://localhost:4200", "http://localhost:3000"},
 		AllowMethods:     []string{echo.POST, echo.GET, echo.PATCH, echo.DELETE, echo.OPTIONS},
 		AllowCredentials: true,
-		AllowHeaders:     []string{"X-API-TrackerId", echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAuthorization, echo.HeaderContentLength},
+		AllowHeaders:     []string{"X-API-TrackerId", handlers.IdempotencyKeyHeader, echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAuthorization, echo.HeaderContentLength},
 		MaxAge:           time.Now().Add(1 * time.Hour).Second(),
 	}))
 	e.Use(echojwt.WithConfig(echojwt.Config{
@@ -58,10 +64,20 @@ func (s *Server) Start() error {
 			if c.Path() == "/api/auth/login" || c.Path() == "/api/auth/signup" || c.Path() == "" {
 				return true
 			}
+			// The admin endpoints only trust a verified token.
+			if strings.HasPrefix(c.Path(), "/api/admin/") {
+				return false
+			}
 			return true
 		},
 	}))
-	e.Use(middleware.Gzip())
+	e.Use(middleware.GzipWithConfig(middleware.GzipConfig{
+		// Event streams are flushed message by message, compressing them defeats the purpose.
+		Skipper: func(c echo.Context) bool {
+			return strings.HasPrefix(c.Path(), "/api/jobs/events") || strings.HasPrefix(c.Path(), "/api/jobs/ws") ||
+				strings.HasPrefix(c.Path(), "/api/draft-with-ai/stream")
+		},
+	}))
 	e.Use(middleware.RateLimiter(middleware.NewRateLimiterMemoryStore(rate.Limit(20))))
 
 	// Serve static files from web/dist directory as per in docker container
@@ -77,6 +93,8 @@ func (s *Server) Start() error {
 	e.Add("GET", "health", func(c echo.Context) error {
 		return c.String(http.StatusOK, "Hellow. Ok!")
 	})
+	// Prometheus metrics
+	e.Add("GET", "/metrics", echo.WrapHandler(metrics.Handler()))
 
 	// Add API routes.
 	api := e.Group("/api")
@@ -90,18 +108,51 @@ func (s *Server) Start() error {
 	api.Add("GET", "/profile/search-people", handlers.PeopleSearchHandler)
 	api.Add("GET", "/profile/analytics", handlers.ProfileAnalyticsHandler)
 	api.Add("POST", "/profile/information", handlers.ProfileInformationHandler)
+	api.Add("GET", "/profile/processing", handlers.GetProfileProcessingHandler)
+	api.Add("POST", "/profile/reprocess", handlers.ReprocessProfileHandler)
+	api.Add("GET", "/profile/structured", handlers.GetStructuredProfileHandler)
+	api.Add("PATCH", "/profile/structured", handlers.UpdateStructuredProfileHandler)
+	api.Add("GET", "/profile/outreach-policy", handlers.GetOutreachPolicyHandler)
+	api.Add("PATCH", "/profile/outreach-policy", handlers.UpdateOutreachPolicyHandler)
+	api.Add("GET", "/profile/usage", handlers.GetLLMUsageHandler)
 	// Tailor Resume endpoint
 	api.Add("POST", "/profile/tailor-resume", handlers.TailorResumeWithJobDescriptionHandler)
 	api.Add("GET", "/profile/tailored-resume/:id", handlers.GetTailoredResumeByIDHandler)
 	api.Add("PATCH", "/profile/tailored-resume", handlers.UpdateTailoredResumeHandler)
 	api.Add("POST", "/profile/export-pdf", handlers.GeneratePDFHandler)
 	api.Add("GET", "/profile/tailored-resumes", handlers.GetLatestTailoredResumesHandler)
+	api.Add("POST", "/profile/ats-score", handlers.ScoreATSMatchHandler)
 	// Draft Coldmails Ai endpoints.
-	api.Add("POST", "/draft-with-ai", handlers.DraftReferralEmailWithAiHandler)
+	api.Add("POST", "/draft-with-ai", handlers.DraftReferralEmailWithAiHandler, handlers.IdempotencyMiddleware)
+	api.Add("POST", "/draft-with-ai/stream", handlers.StreamDraftReferralEmailWithAiHandler)
 	// Email endpoints.
 	api.Add("GET", "/sent-referrals", handlers.GetReferralEmailsHandler)
-	api.Add("POST", "/send-email", handlers.SendEmailHandler)
+	api.Add("POST", "/send-email", handlers.SendEmailHandler, handlers.IdempotencyMiddleware)
+	api.Add("GET", "/send-email/jobs", handlers.ListBulkEmailJobsHandler)
 	api.Add("GET", "/send-email/jobs/:id", handlers.GetBulkEmailJobStatusHandler)
+	// Live job progress endpoints.
+	api.Add("GET", "/jobs/events", handlers.StreamJobEventsHandler)
+	api.Add("GET", "/jobs/ws", handlers.JobEventsWebSocketHandler)
+	// Prompt registry endpoints.
+	api.Add("GET", "/prompts", handlers.ListPromptsHandler)
+	api.Add("GET", "/prompts/:id/versions", handlers.ListPromptVersionsHandler)
+	api.Add("GET", "/prompts/:id/versions/:version", handlers.GetPromptVersionHandler)
+	api.Add("POST", "/prompts/:id/versions", handlers.ForkPromptVersionHandler)
+	api.Add("POST", "/prompts/:id/versions/:version/activate", handlers.ActivatePromptVersionHandler)
+	api.Add("DELETE", "/prompts/:id/activation", handlers.ResetPromptActivationHandler)
+
+	// Admin endpoints.
+	admin := api.Group("/admin", handlers.AdminOnlyMiddleware)
+	admin.Add("GET", "/jobs/queue-depth", handlers.GetJobQueueDepthHandler)
+	admin.Add("GET", "/jobs/dead", handlers.ListDeadJobsHandler)
+	admin.Add("GET", "/jobs/dead/:id", handlers.GetDeadJobHandler)
+	admin.Add("POST", "/jobs/dead/:id/requeue", handlers.RequeueDeadJobHandler)
+	admin.Add("GET", "/tasks", handlers.ListScheduledTasksHandler)
+	admin.Add("POST", "/tasks/:name/run", handlers.RunScheduledTaskHandler)
+	admin.Add("GET", "/llm-usage", handlers.AggregateLLMUsageHandler)
+	admin.Add("GET", "/prompts/:id/versions", handlers.AdminListPromptVersionsHandler)
+	admin.Add("POST", "/prompts/:id/versions", handlers.AdminForkPromptVersionHandler)
+	admin.Add("POST", "/prompts/:id/versions/:version/activate", handlers.AdminActivatePromptVersionHandler)
 
 	// Network / Contact Management endpoints
 	api.Add("POST", "/network/contacts", handlers.AddContactHandler)
@@ -113,3 +164,8 @@ func (s *Server) Start() error {
 	s.co.Lo.Info("server has been started on port ", slog.Any("API", fmt.Sprintf("http://localhost:%d", s.co.Port)))
 	return e.Start(fmt.Sprintf(":%d", s.co.Port))
 }
+
+// Shutdown gracefully stops the webservice, in-flight requests are given until `ctx` expires to complete.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.e.Shutdown(ctx)
+}
@@ -30,3 +30,33 @@ func GetNumberFromEnv(key string, fallback int) int {
 	log.Printf("reading key=%s from os environment not found. returning fallback value\n", key)
 	return fallback
 }
+
+// GetFloatFromEnv to read decimal value from environment
+func GetFloatFromEnv(key string, fallback float64) float64 {
+	if val, found := os.LookupEnv(key); found {
+		log.Printf("reading key=%s from os environment found", key)
+		num, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			log.Printf("something went wrong in reading %s key\n", key)
+			return fallback
+		}
+		return num
+	}
+	log.Printf("reading key=%s from os environment not found. returning fallback value\n", key)
+	return fallback
+}
+
+// GetOptionalBoolFromEnv to read boolean value from environment, nil when not set
+func GetOptionalBoolFromEnv(key string) *bool {
+	if val, found := os.LookupEnv(key); found {
+		log.Printf("reading key=%s from os environment found", key)
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			log.Printf("something went wrong in reading %s key\n", key)
+			return nil
+		}
+		return &b
+	}
+	log.Printf("reading key=%s from os environment not found. returning fallback value\n", key)
+	return nil
+}
@@ -0,0 +1,89 @@
+package workerpool
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sounishnath003/customgo-mailer-service/internal/metrics"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+var (
+	queueDepthDesc = prometheus.NewDesc(
+		"job_queue_depth",
+		"Jobs PENDING or IN_PROGRESS in the job queue, by kind, stage and status.",
+		[]string{"kind", "stage", "status"}, nil,
+	)
+	queueDepthByPriorityDesc = prometheus.NewDesc(
+		"job_queue_depth_by_priority",
+		"Jobs PENDING or IN_PROGRESS in the job queue, by priority and status.",
+		[]string{"priority", "status"}, nil,
+	)
+	queueScheduledByPriorityDesc = prometheus.NewDesc(
+		"job_queue_scheduled_by_priority",
+		"PENDING jobs not due yet, delayed or waiting for a retry, by priority.",
+		[]string{"priority"}, nil,
+	)
+	workersBusyDesc = prometheus.NewDesc(
+		"workerpool_busy_workers",
+		"Workers of this replica running a job.",
+		nil, nil,
+	)
+)
+
+// queueCollector reads the job queue depth from MongoDB on every scrape.
+type queueCollector struct {
+	wp *WorkerPool
+}
+
+func (qc queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+	ch <- queueDepthByPriorityDesc
+	ch <- queueScheduledByPriorityDesc
+	ch <- workersBusyDesc
+}
+
+func (qc queueCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(workersBusyDesc, prometheus.GaugeValue, float64(qc.wp.inFlight.Load()))
+
+	byStage, err := qc.wp.store.GetJobQueueDepthByStage()
+	if err != nil {
+		qc.wp.lo.Error("[WORKERPOOL]: not able to collect the queue depth:", "error", err)
+	}
+	for _, d := range byStage {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(d.Count), d.Kind, d.Stage, d.Status)
+	}
+
+	byPriority, err := qc.wp.store.GetJobQueueDepth()
+	if err != nil {
+		qc.wp.lo.Error("[WORKERPOOL]: not able to collect the queue depth by priority:", "error", err)
+	}
+	scheduled := map[int]int64{}
+	for _, d := range byPriority {
+		priority := strconv.Itoa(d.Priority)
+		ch <- prometheus.MustNewConstMetric(queueDepthByPriorityDesc, prometheus.GaugeValue, float64(d.Count), priority, d.Status)
+		scheduled[d.Priority] += d.Scheduled
+	}
+	for p, count := range scheduled {
+		ch <- prometheus.MustNewConstMetric(queueScheduledByPriorityDesc, prometheus.GaugeValue, float64(count), strconv.Itoa(p))
+	}
+}
+
+// registerQueueCollector exposes the queue depth of the pool on the metrics registry.
+func registerQueueCollector(wp *WorkerPool) {
+	err := metrics.Registry.Register(queueCollector{wp: wp})
+	if are := (prometheus.AlreadyRegisteredError{}); err != nil && !errors.As(err, &are) {
+		slog.Default().Error("[WORKERPOOL]: not able to register the queue metrics:", "error", err)
+	}
+}
+
+// observeStageRun records the duration of a stage run.
+func observeStageRun(job *repository.JobQueue, run repository.JobStageRun) {
+	outcome := "COMPLETED"
+	if run.Error != "" {
+		outcome = "FAILED"
+	}
+	metrics.JobStageDuration.WithLabelValues(job.Kind, run.Stage, outcome).Observe(run.FinishedAt.Sub(run.StartedAt).Seconds())
+}
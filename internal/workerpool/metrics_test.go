@@ -0,0 +1,121 @@
+package workerpool
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sounishnath003/customgo-mailer-service/internal/metrics"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+)
+
+func TestQueueCollectorReportsTheQueueDepth(t *testing.T) {
+	store := &fakeJobStore{
+		depthByStage: []*repository.JobStageDepth{
+			{Kind: "RESUME_PROCESSING", Stage: "EXTRACT_CONTENT", Status: "PENDING", Count: 3},
+			{Kind: "BULK_EMAIL", Stage: "SEND_EMAILS", Status: "IN_PROGRESS", Count: 1},
+		},
+		depth: []*repository.JobQueueDepth{
+			{Priority: repository.JOB_PRIORITY_HIGH, Status: "PENDING", Count: 3, Scheduled: 1},
+			{Priority: repository.JOB_PRIORITY_HIGH, Status: "IN_PROGRESS", Count: 1},
+		},
+	}
+	wp := newTestWorkerPool(store, &WorkerPoolOpts{Concurrency: 2})
+	wp.inFlight.Add(1)
+
+	expected := `
+# HELP job_queue_depth Jobs PENDING or IN_PROGRESS in the job queue, by kind, stage and status.
+# TYPE job_queue_depth gauge
+job_queue_depth{kind="BULK_EMAIL",stage="SEND_EMAILS",status="IN_PROGRESS"} 1
+job_queue_depth{kind="RESUME_PROCESSING",stage="EXTRACT_CONTENT",status="PENDING"} 3
+# HELP job_queue_depth_by_priority Jobs PENDING or IN_PROGRESS in the job queue, by priority and status.
+# TYPE job_queue_depth_by_priority gauge
+job_queue_depth_by_priority{priority="100",status="IN_PROGRESS"} 1
+job_queue_depth_by_priority{priority="100",status="PENDING"} 3
+# HELP job_queue_scheduled_by_priority PENDING jobs not due yet, delayed or waiting for a retry, by priority.
+# TYPE job_queue_scheduled_by_priority gauge
+job_queue_scheduled_by_priority{priority="100"} 1
+# HELP workerpool_busy_workers Workers of this replica running a job.
+# TYPE workerpool_busy_workers gauge
+workerpool_busy_workers 1
+`
+	if err := testutil.CollectAndCompare(queueCollector{wp: wp}, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQueueCollectorIsRegistered(t *testing.T) {
+	newTestWorkerPool(&fakeJobStore{}, &WorkerPoolOpts{Concurrency: 1})
+
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() == "workerpool_busy_workers" {
+			return
+		}
+	}
+	t.Error("expected the queue metrics on the metrics registry")
+}
+
+func TestObserveStageRunLabelsTheOutcome(t *testing.T) {
+	job := &repository.JobQueue{Kind: "METRICS_TEST"}
+	startedAt := time.Now()
+
+	observeStageRun(job, repository.JobStageRun{Stage: "RUN", StartedAt: startedAt, FinishedAt: startedAt.Add(2 * time.Second)})
+	observeStageRun(job, repository.JobStageRun{Stage: "RUN", StartedAt: startedAt, FinishedAt: startedAt.Add(time.Second)})
+	observeStageRun(job, repository.JobStageRun{Stage: "RUN", StartedAt: startedAt, FinishedAt: startedAt.Add(time.Second), Error: "timeout"})
+
+	expected := `
+# HELP job_stage_duration_seconds Duration of a single run of a job stage.
+# TYPE job_stage_duration_seconds histogram
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN",le="0.1"} 0
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN",le="0.5"} 0
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN",le="1"} 1
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN",le="2.5"} 2
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN",le="5"} 2
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN",le="10"} 2
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN",le="30"} 2
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN",le="60"} 2
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN",le="120"} 2
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN",le="300"} 2
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN",le="600"} 2
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN",le="+Inf"} 2
+job_stage_duration_seconds_sum{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN"} 3
+job_stage_duration_seconds_count{kind="METRICS_TEST",outcome="COMPLETED",stage="RUN"} 2
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="FAILED",stage="RUN",le="0.1"} 0
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="FAILED",stage="RUN",le="0.5"} 0
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="FAILED",stage="RUN",le="1"} 1
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="FAILED",stage="RUN",le="2.5"} 1
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="FAILED",stage="RUN",le="5"} 1
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="FAILED",stage="RUN",le="10"} 1
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="FAILED",stage="RUN",le="30"} 1
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="FAILED",stage="RUN",le="60"} 1
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="FAILED",stage="RUN",le="120"} 1
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="FAILED",stage="RUN",le="300"} 1
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="FAILED",stage="RUN",le="600"} 1
+job_stage_duration_seconds_bucket{kind="METRICS_TEST",outcome="FAILED",stage="RUN",le="+Inf"} 1
+job_stage_duration_seconds_sum{kind="METRICS_TEST",outcome="FAILED",stage="RUN"} 1
+job_stage_duration_seconds_count{kind="METRICS_TEST",outcome="FAILED",stage="RUN"} 1
+`
+	// other tests run jobs of their own kinds, only the series of this kind are compared
+	ofThisKind := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := metrics.Registry.Gather()
+		for _, f := range families {
+			f.Metric = slices.DeleteFunc(f.Metric, func(m *dto.Metric) bool {
+				return !slices.ContainsFunc(m.GetLabel(), func(l *dto.LabelPair) bool {
+					return l.GetName() == "kind" && l.GetValue() == "METRICS_TEST"
+				})
+			})
+		}
+		return families, err
+	})
+	if err := testutil.GatherAndCompare(ofThisKind, strings.NewReader(expected), "job_stage_duration_seconds"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,193 @@
+package workerpool
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Stage names a single step in the stage graph of a job kind.
+type Stage string
+
+// DONE is the terminal stage, a stage returning it completes the job.
+const DONE Stage = "DONE"
+
+// StageFunc runs one stage of a job against its typed payload and returns the stage to run next.
+// Changes made to the payload are persisted along with the stage transition.
+type StageFunc[P any] func(ctx context.Context, job *repository.JobQueue, payload *P) (Stage, error)
+
+// StageDefinition is a node of the stage graph: the stage handler and the stages it may move to.
+type StageDefinition[P any] struct {
+	Run  StageFunc[P]
+	Next []Stage
+}
+
+// Definition describes a job kind, the stage it starts at and its stage graph.
+type Definition[P any] struct {
+	Kind   string
+	Start  Stage
+	Stages map[Stage]StageDefinition[P]
+
+	// OnFailed is called once the job ran out of attempts, with the error of its last attempt. Optional.
+	OnFailed func(ctx context.Context, job *repository.JobQueue, payload *P, err error)
+}
+
+// jobRunner is the type erased view of a Definition, used by the worker pool.
+type jobRunner interface {
+	startStage() Stage
+	runStage(ctx context.Context, job *repository.JobQueue) (Stage, error)
+	failed(ctx context.Context, job *repository.JobQueue, err error)
+}
+
+func (def Definition[P]) startStage() Stage {
+	return def.Start
+}
+
+// runStage decodes the payload, runs the job's current stage and validates the transition it asks for.
+func (def Definition[P]) runStage(ctx context.Context, job *repository.JobQueue) (Stage, error) {
+	stage, ok := def.Stages[Stage(job.Stage)]
+	if !ok {
+		return "", fmt.Errorf("stage %q is not part of the %s stage graph", job.Stage, def.Kind)
+	}
+
+	var payload P
+	if len(job.Payload) > 0 {
+		if err := bson.Unmarshal(job.Payload, &payload); err != nil {
+			return "", fmt.Errorf("unable to decode %s payload: %w", def.Kind, err)
+		}
+	}
+
+	next, err := stage.Run(ctx, job, &payload)
+
+	// The payload is stored even when the stage fails, so partial progress isn't lost.
+	raw, mErr := bson.Marshal(payload)
+	if mErr != nil {
+		return "", fmt.Errorf("unable to encode %s payload: %w", def.Kind, mErr)
+	}
+	job.Payload = raw
+
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(stage.Next, next) {
+		return "", fmt.Errorf("invalid transition of %s job from %s to %q", def.Kind, job.Stage, next)
+	}
+	return next, nil
+}
+
+// failed hands the job over to the OnFailed hook, if any.
+func (def Definition[P]) failed(ctx context.Context, job *repository.JobQueue, err error) {
+	if def.OnFailed == nil {
+		return
+	}
+	var payload P
+	if len(job.Payload) > 0 {
+		// A payload which can't be decoded is passed on empty, the hook still learns about the failure.
+		bson.Unmarshal(job.Payload, &payload)
+	}
+	def.OnFailed(ctx, job, &payload, err)
+}
+
+// validate checks the stage graph is well formed.
+func (def Definition[P]) validate() error {
+	if def.Kind == "" {
+		return fmt.Errorf("job kind is required")
+	}
+	if _, ok := def.Stages[def.Start]; !ok {
+		return fmt.Errorf("start stage %q of %s is not defined", def.Start, def.Kind)
+	}
+	for name, stage := range def.Stages {
+		if stage.Run == nil {
+			return fmt.Errorf("stage %s of %s has no handler", name, def.Kind)
+		}
+		if len(stage.Next) == 0 {
+			return fmt.Errorf("stage %s of %s has no next stage", name, def.Kind)
+		}
+		for _, next := range stage.Next {
+			if _, ok := def.Stages[next]; !ok && next != DONE {
+				return fmt.Errorf("stage %s of %s moves to undefined stage %q", name, def.Kind, next)
+			}
+		}
+	}
+	return nil
+}
+
+// Registry holds the job kinds the worker pool knows how to process.
+type Registry struct {
+	mu    sync.RWMutex
+	kinds map[string]jobRunner
+}
+
+// NewRegistry creates an empty job kinds registry.
+func NewRegistry() *Registry {
+	return &Registry{kinds: make(map[string]jobRunner)}
+}
+
+// Register adds a job kind to the registry.
+// It panics on an invalid stage graph or a duplicate kind, as both are programming errors.
+func Register[P any](r *Registry, def Definition[P]) {
+	if err := def.validate(); err != nil {
+		panic(fmt.Sprintf("workerpool: invalid job definition: %s", err))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.kinds[def.Kind]; exists {
+		panic(fmt.Sprintf("workerpool: job kind %s is already registered", def.Kind))
+	}
+	r.kinds[def.Kind] = def
+}
+
+// lookup finds the runner of a job kind.
+func (r *Registry) lookup(kind string) (jobRunner, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	runner, ok := r.kinds[kind]
+	return runner, ok
+}
+
+// EnqueueRequest describes a new job to be submitted to the job queue.
+type EnqueueRequest[P any] struct {
+	// ID is optional, a new one is generated when left empty.
+	ID        primitive.ObjectID
+	Kind      string
+	Stage     Stage
+	UserEmail string
+	Payload   P
+
+	// Priority defaults to repository.JOB_PRIORITY_LOW, RunAt delays the job when set in the future.
+	Priority int
+	RunAt    time.Time
+}
+
+// Enqueue submits a new job with its typed payload to the job queue, for the workers to pick it up.
+func Enqueue[P any](co *core.Core, req EnqueueRequest[P]) (primitive.ObjectID, error) {
+	raw, err := bson.Marshal(req.Payload)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("unable to encode %s payload: %w", req.Kind, err)
+	}
+
+	job := &repository.JobQueue{
+		ID:               req.ID,
+		UserEmailAddress: req.UserEmail,
+		Kind:             req.Kind,
+		Stage:            string(req.Stage),
+		Status:           "PENDING",
+		Payload:          raw,
+		Priority:         req.Priority,
+		RunAt:            req.RunAt,
+	}
+	if err := co.DB.EnqueueJob(job); err != nil {
+		return primitive.NilObjectID, fmt.Errorf("unable to enqueue %s job: %w", req.Kind, err)
+	}
+	return job.ID, nil
+}
@@ -0,0 +1,89 @@
+package workerpool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type counterPayload struct {
+	Count int `bson:"count"`
+}
+
+func counterDefinition() Definition[counterPayload] {
+	return Definition[counterPayload]{
+		Kind:  "COUNTER",
+		Start: "INCREMENT",
+		Stages: map[Stage]StageDefinition[counterPayload]{
+			"INCREMENT": {
+				Next: []Stage{"INCREMENT", DONE},
+				Run: func(ctx context.Context, job *repository.JobQueue, payload *counterPayload) (Stage, error) {
+					payload.Count++
+					if payload.Count < 3 {
+						return "INCREMENT", nil
+					}
+					return DONE, nil
+				},
+			},
+		},
+	}
+}
+
+func TestRunStagePersistsTypedPayload(t *testing.T) {
+	r := NewRegistry()
+	Register(r, counterDefinition())
+
+	runner, ok := r.lookup("COUNTER")
+	if !ok {
+		t.Fatalf("COUNTER kind is not registered")
+	}
+
+	job := &repository.JobQueue{Kind: "COUNTER", Stage: string(runner.startStage())}
+	var next Stage
+	for next != DONE {
+		var err error
+		if next, err = runner.runStage(context.Background(), job); err != nil {
+			t.Fatalf("runStage failed: %v", err)
+		}
+	}
+
+	var payload counterPayload
+	if err := bson.Unmarshal(job.Payload, &payload); err != nil {
+		t.Fatalf("unable to decode payload: %v", err)
+	}
+	if payload.Count != 3 {
+		t.Errorf("expected count 3, got %d", payload.Count)
+	}
+}
+
+func TestRunStageRejectsUndeclaredTransition(t *testing.T) {
+	def := counterDefinition()
+	def.Stages["INCREMENT"] = StageDefinition[counterPayload]{
+		Next: []Stage{DONE},
+		Run: func(ctx context.Context, job *repository.JobQueue, payload *counterPayload) (Stage, error) {
+			return "INCREMENT", nil
+		},
+	}
+
+	job := &repository.JobQueue{Kind: "COUNTER", Stage: "INCREMENT"}
+	if _, err := def.runStage(context.Background(), job); err == nil {
+		t.Errorf("expected the undeclared transition to be rejected")
+	}
+}
+
+func TestRegisterPanicsOnInvalidGraph(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected Register to panic on an undefined next stage")
+		}
+	}()
+
+	def := counterDefinition()
+	def.Stages["INCREMENT"] = StageDefinition[counterPayload]{
+		Next: []Stage{"MISSING"},
+		Run:  def.Stages["INCREMENT"].Run,
+	}
+	Register(NewRegistry(), def)
+}
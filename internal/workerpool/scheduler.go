@@ -0,0 +1,206 @@
+package workerpool
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/metrics"
+)
+
+// schedulerTickInterval is how often the scheduler looks for due tasks, a manual trigger waits at most that long.
+const schedulerTickInterval = 10 * time.Second
+
+// defaultTaskTimeout bounds a task run when the task doesn't set its own timeout.
+const defaultTaskTimeout = 10 * time.Minute
+
+// taskLockGrace keeps the lock a while past the task timeout, so a slow release never lets another replica in.
+const taskLockGrace = 1 * time.Minute
+
+// PeriodicTask is a task run on a cron schedule, by a single replica at a time.
+type PeriodicTask struct {
+	// Name identifies the task across the replicas and in the admin API.
+	Name        string
+	Description string
+	// Schedule is a standard 5-field cron expression, or a descriptor like `@hourly`, in UTC.
+	Schedule string
+	// Timeout bounds a single run, defaultTaskTimeout when zero.
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+type scheduledTask struct {
+	PeriodicTask
+	schedule cron.Schedule
+}
+
+// Scheduler runs the periodic tasks. The task state and locks live in MongoDB,
+// every replica runs a scheduler and the lock decides which one runs a due task.
+type Scheduler struct {
+	owner string
+	tasks map[string]*scheduledTask
+
+	quit     chan struct{}
+	quitOnce sync.Once
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	wg sync.WaitGroup
+	lo *slog.Logger
+	co *core.Core
+}
+
+func NewScheduler(co *core.Core) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		owner:  instanceID(),
+		tasks:  make(map[string]*scheduledTask),
+		quit:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+		lo:     slog.Default(),
+		co:     co,
+	}
+}
+
+// Add registers a periodic task. It panics on an invalid cron expression or a duplicate name,
+// both are programming errors caught on start up.
+func (s *Scheduler) Add(task PeriodicTask) {
+	if _, exists := s.tasks[task.Name]; exists {
+		panic(fmt.Sprintf("periodic task %s is already registered", task.Name))
+	}
+	schedule, err := cron.ParseStandard(task.Schedule)
+	if err != nil {
+		panic(fmt.Sprintf("periodic task %s has an invalid schedule %q: %v", task.Name, task.Schedule, err))
+	}
+	if task.Timeout <= 0 {
+		task.Timeout = defaultTaskTimeout
+	}
+	s.tasks[task.Name] = &scheduledTask{PeriodicTask: task, schedule: schedule}
+}
+
+// Start registers the tasks in MongoDB and runs the due ones until Shutdown.
+func (s *Scheduler) Start() error {
+	now := time.Now().UTC()
+	for _, task := range s.tasks {
+		if err := s.co.DB.RegisterScheduledTask(task.Name, task.Description, task.Schedule, task.schedule.Next(now)); err != nil {
+			return fmt.Errorf("unable to register the periodic task %s: %w", task.Name, err)
+		}
+	}
+
+	s.wg.Add(1)
+	go s.loop()
+	s.lo.Info("[SCHEDULER]: started", "tasks", len(s.tasks), "owner", s.owner)
+	return nil
+}
+
+func (s *Scheduler) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(schedulerTickInterval)
+	defer ticker.Stop()
+
+	for {
+		s.runDueTasks()
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDueTasks starts the due tasks this replica managed to lock, in name order.
+func (s *Scheduler) runDueTasks() {
+	names := make([]string, 0, len(s.tasks))
+	for name := range s.tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		select {
+		case <-s.quit:
+			return
+		default:
+		}
+
+		task := s.tasks[name]
+		now := time.Now().UTC()
+		state, err := s.co.DB.AcquireScheduledTask(task.Name, s.owner, task.Timeout+taskLockGrace, task.schedule.Next(now))
+		if err != nil {
+			s.lo.Error("[SCHEDULER]: not able to acquire the task lock", "task", task.Name, "error", err)
+			continue
+		}
+		if state == nil {
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.run(task, state.TriggeredBy)
+		}()
+	}
+}
+
+// run runs a locked task and releases its lock with the outcome.
+func (s *Scheduler) run(task *scheduledTask, triggeredBy string) {
+	ctx, cancel := context.WithTimeout(s.ctx, task.Timeout)
+	defer cancel()
+
+	startedAt := time.Now()
+	s.lo.Info("[SCHEDULER]: running task", "task", task.Name, "triggeredBy", triggeredBy)
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("task panicked: %v", r)
+				s.lo.Error("[SCHEDULER]: task panicked", "task", task.Name, "panic", r, "stack", string(debug.Stack()))
+			}
+		}()
+		return task.Run(ctx)
+	}()
+
+	metrics.ScheduledTaskRunsTotal.WithLabelValues(task.Name, metrics.Outcome(err)).Inc()
+	metrics.Since(metrics.ScheduledTaskDuration.WithLabelValues(task.Name), startedAt)
+
+	if err != nil {
+		s.lo.Error("[SCHEDULER]: task failed", "task", task.Name, "error", err, "duration", time.Since(startedAt))
+	} else {
+		s.lo.Info("[SCHEDULER]: task completed", "task", task.Name, "duration", time.Since(startedAt))
+	}
+
+	if completeErr := s.co.DB.CompleteScheduledTask(task.Name, s.owner, startedAt, err); completeErr != nil {
+		s.lo.Error("[SCHEDULER]: not able to release the task lock", "task", task.Name, "error", completeErr)
+	}
+}
+
+// Shutdown stops scheduling new runs and waits for the running tasks.
+// Once the context is done, the running tasks are cancelled.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.quitOnce.Do(func() { close(s.quit) })
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.lo.Warn("[SCHEDULER]: shutdown deadline hit, cancelling the running tasks")
+		s.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
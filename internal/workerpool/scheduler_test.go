@@ -0,0 +1,30 @@
+package workerpool
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSchedulerAddRejectsInvalidSchedules(t *testing.T) {
+	s := NewScheduler(nil)
+	noop := func(ctx context.Context) error { return nil }
+
+	s.Add(PeriodicTask{Name: "hourly", Schedule: "0 * * * *", Run: noop})
+	if s.tasks["hourly"].Timeout != defaultTaskTimeout {
+		t.Errorf("expected the default timeout, got %s", s.tasks["hourly"].Timeout)
+	}
+
+	for _, task := range []PeriodicTask{
+		{Name: "broken", Schedule: "every hour", Run: noop},
+		{Name: "hourly", Schedule: "@hourly", Run: noop},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected Add(%s, %q) to panic", task.Name, task.Schedule)
+				}
+			}()
+			s.Add(task)
+		}()
+	}
+}
@@ -2,36 +2,169 @@ package workerpool
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/events"
+	"github.com/sounishnath003/customgo-mailer-service/internal/metrics"
 	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"gopkg.in/mgo.v2/bson"
 )
 
+// defaultLeaseDuration is used when the pool is configured without a lease duration.
+const defaultLeaseDuration = 2 * time.Minute
+
+// recheckInterval is how often the dispatcher looks for claimable jobs without being woken up,
+// it picks up the retries whose backoff elapsed.
+const recheckInterval = 15 * time.Second
+
+// DispatchMode tells how the dispatcher learns about the new jobs.
+type DispatchMode string
+
+const (
+	// DISPATCH_AUTO watches a change stream, and falls back to polling when MongoDB doesn't support them.
+	DISPATCH_AUTO DispatchMode = "AUTO"
+	// DISPATCH_CHANGE_STREAM only watches a change stream, it requires a replica set.
+	DISPATCH_CHANGE_STREAM DispatchMode = "CHANGE_STREAM"
+	// DISPATCH_POLL polls the job queue every PollInterval, for standalone MongoDB deployments.
+	DISPATCH_POLL DispatchMode = "POLL"
+)
+
+// persistAttempts is how many times a job state write failing on MongoDB is tried before the worker gives up on the job.
+const persistAttempts = 3
+
+// defaultPersistRetryDelay is the backoff after the first failed job state write, it doubles on every next attempt.
+const defaultPersistRetryDelay = 500 * time.Millisecond
+
+// legacyJobKind is the kind of the jobs queued before job kinds existed, they were all resume processing jobs.
+const legacyJobKind = repository.LEGACY_JOB_KIND
+
+// WorkerPoolOpts configures the worker pool.
+type WorkerPoolOpts struct {
+	// Concurrency is the number of jobs processed in parallel.
+	Concurrency int
+	// MaxJobsPerUser caps how many jobs of the same user are IN_PROGRESS at once, across all replicas.
+	MaxJobsPerUser int
+	// MaxAttempts is the number of runs a stage gets before its job is moved to the dead jobs.
+	MaxAttempts int
+	// RetryBaseDelay is the backoff after the first failed attempt, it doubles on every next attempt.
+	RetryBaseDelay time.Duration
+	// RetryMaxDelay caps the backoff between two attempts.
+	RetryMaxDelay time.Duration
+	// LeaseDuration is how long a claimed job stays leased to this pool without a heartbeat.
+	// Jobs of a crashed replica are handed back to the queue once their lease expired.
+	LeaseDuration time.Duration
+	// DispatchMode picks how the new jobs are noticed, DISPATCH_AUTO by default.
+	DispatchMode DispatchMode
+	// PollInterval is the polling period of DISPATCH_POLL.
+	PollInterval time.Duration
+}
+
+// jobStore is where the pool claims and stores its jobs, the MongoDB client of the core.
+type jobStore interface {
+	ClaimPendingJob(workerID string, leaseDuration time.Duration, excludeUsers []string) (*repository.JobQueue, error)
+	UnclaimJob(job *repository.JobQueue, workerID string) error
+	UpdateJobState(job *repository.JobQueue) error
+	MoveJobToDeadLetter(job *repository.JobQueue) error
+	RenewJobLease(id primitive.ObjectID, workerID string, leaseDuration time.Duration) error
+	ReapExpiredJobLeases(leaseDuration time.Duration) (int64, error)
+	GetUsersAtJobCapacity(limit int) ([]string, error)
+	CountUserJobsInProgress(userEmail string) (int64, error)
+	WatchClaimableJobs(ctx context.Context) (*mongo.ChangeStream, error)
+	GetJobQueueDepth() ([]*repository.JobQueueDepth, error)
+	GetJobQueueDepthByStage() ([]*repository.JobStageDepth, error)
+}
+
 type WorkerPool struct {
-	concurrency int
-	jobQueue    chan repository.JobQueue
+	workerID       string
+	concurrency    int
+	maxJobsPerUser int
+	maxAttempts    int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	leaseDuration  time.Duration
+	dispatchMode   DispatchMode
+	pollInterval   time.Duration
+	jobQueue       chan repository.JobQueue
+	registry       *Registry
+	store          jobStore
+
+	// persistRetryDelay is the backoff between two attempts of a failed job state write.
+	persistRetryDelay time.Duration
+
+	// inFlight counts the jobs claimed and not finished yet, the dispatcher never claims beyond the concurrency.
+	inFlight atomic.Int32
+	// wake nudges the dispatcher when jobs may be claimable, or a worker got free.
+	wake chan struct{}
+
+	// quit stops the claiming on shutdown, cancelJobs aborts the running stages once the shutdown deadline is hit.
+	quit       chan struct{}
+	quitOnce   sync.Once
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
 
 	wg sync.WaitGroup
-	mu sync.Mutex
 	lo *slog.Logger
 	co *core.Core
 }
 
-func NewWorkerPool(co *core.Core, concurrency int) *WorkerPool {
-	return &WorkerPool{
-		co: co,
-		lo: slog.Default(),
+// instanceID names this replica in the job leases and the task locks.
+func instanceID() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
 
-		concurrency: concurrency,
-		jobQueue:    make(chan repository.JobQueue, 10*concurrency),
+func NewWorkerPool(co *core.Core, opts *WorkerPoolOpts) *WorkerPool {
+	if opts.LeaseDuration <= 0 {
+		opts.LeaseDuration = defaultLeaseDuration
+	}
+	if opts.DispatchMode == "" {
+		opts.DispatchMode = DISPATCH_AUTO
 	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 1 * time.Second
+	}
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+
+	wp := &WorkerPool{
+		co:       co,
+		lo:       slog.Default(),
+		registry: NewRegistry(),
+		store:    co.DB,
+
+		workerID:       instanceID(),
+		concurrency:    opts.Concurrency,
+		maxJobsPerUser: opts.MaxJobsPerUser,
+		maxAttempts:    max(opts.MaxAttempts, 1),
+		retryBaseDelay: opts.RetryBaseDelay,
+		retryMaxDelay:  opts.RetryMaxDelay,
+		leaseDuration:  opts.LeaseDuration,
+		dispatchMode:   opts.DispatchMode,
+		pollInterval:   opts.PollInterval,
+
+		persistRetryDelay: defaultPersistRetryDelay,
+		// Jobs are only claimed for the free workers, handing them over never blocks.
+		jobQueue: make(chan repository.JobQueue, opts.Concurrency),
+		wake:     make(chan struct{}, 1),
+
+		quit:       make(chan struct{}),
+		jobsCtx:    jobsCtx,
+		cancelJobs: cancelJobs,
+	}
+	registerQueueCollector(wp)
+	return wp
+}
+
+// Registry returns the job kinds registry, job kinds must be registered before the workers start.
+func (wp *WorkerPool) Registry() *Registry {
+	return wp.registry
 }
 
 func (wp *WorkerPool) StartWorkers() {
@@ -45,114 +178,453 @@ func (wp *WorkerPool) StartWorkers() {
 func (wp *WorkerPool) worker() {
 	defer wp.wg.Done()
 	for job := range wp.jobQueue {
-		wp.processJob(job)
+		if wp.stopping() {
+			wp.releaseJob(&job)
+		} else {
+			wp.processJob(job)
+		}
+		wp.inFlight.Add(-1)
+		wp.notify()
 	}
 }
 
-func (wp *WorkerPool) processJob(job repository.JobQueue) {
-	wp.mu.Lock()
-	defer wp.mu.Unlock()
+// notify wakes the dispatcher up, without blocking when it's already due to run.
+func (wp *WorkerPool) notify() {
+	select {
+	case wp.wake <- struct{}{}:
+	default:
+	}
+}
 
+// stopping tells whether the pool is shutting down.
+func (wp *WorkerPool) stopping() bool {
+	select {
+	case <-wp.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// processJob runs the job through its stage graph until it completes or fails.
+// The job is persisted and an event is published after every stage transition.
+// Workers run their jobs in parallel, a job is only ever written through its own ID and version.
+func (wp *WorkerPool) processJob(job repository.JobQueue) {
 	currentTime := time.Now()
-	wp.lo.Info("[WORKERPOOL]: started processing new", "userEmail", job.UserEmailAddress, "job_type", job.JobType, "currentTime", currentTime)
+	normalizeLegacyJob(&job)
+	wp.lo.Info("[WORKERPOOL]: started processing new", "userEmail", job.UserEmailAddress, "kind", job.Kind, "stage", job.Stage, "currentTime", currentTime)
 
-	switch job.JobType {
-	case repository.EXTRACT_CONTENT:
-		// extract content from resume
-		content, err := wp.co.ExtractResumeContentLLM(job.Payload.ResumeURL)
-		if err != nil {
+	runner, ok := wp.registry.lookup(job.Kind)
+	if !ok {
+		err := fmt.Errorf("job kind not recognized: email=%s, kind=%s", job.UserEmailAddress, job.Kind)
+		wp.lo.Error("[WORKERPOOL]: unknown", "kind", job.Kind, "error", err)
+		// Retrying won't teach the workers a new kind.
+		job.Attempts = wp.maxAttempts - 1
+		wp.failJob(&job, nil, err)
+		return
+	}
+	if job.Stage == "" {
+		job.Stage = string(runner.startStage())
+	}
+
+	ctx, cancel := context.WithCancel(wp.jobsCtx)
+	defer cancel()
+	go wp.heartbeat(ctx, cancel, job.ID)
+
+	for job.Status == "IN_PROGRESS" {
+		stageStartedAt := time.Now()
+		next, err := runner.runStage(ctx, &job)
+		recordStageRun(&job, stageStartedAt, err)
+		if err != nil && wp.jobsCtx.Err() != nil {
+			// Aborted by the shutdown, it's not the stage's fault.
+			wp.releaseJob(&job)
 			break
 		}
-		job.Payload.ExtractedContent = content
-		wp.lo.Info("[WORKERPOOL]: completed", "userEmail", job.UserEmailAddress, "job_type", job.JobType.String(), "timeElapsed", time.Since(currentTime))
-		job.JobType = repository.GENERATE_PROFILE_SUMMARY
-	case repository.GENERATE_PROFILE_SUMMARY:
-		// generate profile summary
-		wp.lo.Info("[WORKERPOOL]:", "userEmail", job.UserEmailAddress, "job_type", job.JobType, "completedAt", time.Now())
-		summary, err := wp.co.GenerateProfileSummaryLLM(job.Payload.ExtractedContent)
 		if err != nil {
+			wp.lo.Error("[WORKERPOOL]: failed", "userEmail", job.UserEmailAddress, "kind", job.Kind, "stage", job.Stage, "attempt", job.Attempts+1, "error", err)
+			wp.failJob(&job, runner, err)
 			break
 		}
-		job.Payload.Summary = summary
-		wp.lo.Info("[WORKERPOOL]: completed", "userEmail", job.UserEmailAddress, "job_type", job.JobType.String(), "timeElapsed", time.Since(currentTime))
-		job.JobType = repository.UPDATE_RESUME_DOCUMENT
-	case repository.UPDATE_RESUME_DOCUMENT:
-		u, err := wp.co.DB.GetProfileByEmail(job.UserEmailAddress)
-		if err != nil {
-			return
+
+		wp.lo.Info("[WORKERPOOL]: completed", "userEmail", job.UserEmailAddress, "kind", job.Kind, "stage", job.Stage, "next", next, "timeElapsed", time.Since(stageStartedAt))
+
+		if next == DONE {
+			job.Status = "COMPLETED"
+			if wp.persistJob(&job) {
+				metrics.JobsFinishedTotal.WithLabelValues(job.Kind, job.Status).Inc()
+				wp.publishJobEvent(job, events.JOB_COMPLETED, nil)
+			}
+			break
+		}
+
+		job.Stage = string(next)
+		job.Attempts = 0
+		job.LastError = ""
+		if !wp.persistJob(&job) {
+			// The job is no longer ours to process.
+			break
 		}
-		u.ExtractedContent = job.Payload.ExtractedContent
-		u.ProfileSummary = job.Payload.Summary
-		if err = wp.co.DB.UpdateProfileInformation(u); err != nil {
-			wp.lo.Error("error updating the users profile content", "userEmailAddress", job.UserEmailAddress, "error", err)
+		wp.publishJobEvent(job, events.JOB_STAGE_CHANGED, nil)
+
+		if wp.stopping() {
+			// Hand the remaining stages over to another replica.
+			wp.releaseJob(&job)
 			break
 		}
-		wp.lo.Info("[WORKERPOOL]:", "userEmail", job.UserEmailAddress, "job_type", job.JobType.String(), "completedAt", time.Now())
-		job.JobType = repository.EMAIL_NOTIFICATION
-	case repository.EMAIL_NOTIFICATION:
-		// send the email to user
-		wp.lo.Info("[WORKERPOOL]:", "userEmail", job.UserEmailAddress, "job_type", job.JobType, "status", "COMPLETED", "completedAt", time.Now())
-		job.Status = "COMPLETED"
-	default:
-		wp.lo.Error("[WORKERPOOL]: unknown", "job type", job.JobType, "error", fmt.Errorf("job type not recognized: email=%s, job_type=%s\n", job.UserEmailAddress, job.JobType))
-		job.Status = "FAILED"
 	}
 
-	wp.lo.Info("[WORKERPOOL]: completed processing", "userEmail", job.UserEmailAddress, "job_type", job.JobType, "totalElapsedTime", time.Since(currentTime))
+	wp.lo.Info("[WORKERPOOL]: completed processing", "userEmail", job.UserEmailAddress, "kind", job.Kind, "status", job.Status, "totalElapsedTime", time.Since(currentTime))
+}
+
+// recordStageRun appends the run of the job's current stage to its history.
+func recordStageRun(job *repository.JobQueue, startedAt time.Time, err error) {
+	run := repository.JobStageRun{
+		Stage:      job.Stage,
+		Attempt:    job.Attempts + 1,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+	}
+	run.DurationMs = run.FinishedAt.Sub(startedAt).Milliseconds()
+	if err != nil {
+		run.Error = err.Error()
+	}
+	job.StageRuns = append(job.StageRuns, run)
+	observeStageRun(job, run)
+}
+
+// heartbeat renews the job's lease until the job is done.
+// When the lease was lost, the job was reaped and handed to another worker, the running stage is cancelled.
+func (wp *WorkerPool) heartbeat(ctx context.Context, cancel context.CancelFunc, jobID primitive.ObjectID) {
+	ticker := time.NewTicker(wp.leaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := wp.store.RenewJobLease(jobID, wp.workerID, wp.leaseDuration)
+			if errors.Is(err, repository.ErrJobLeaseLost) {
+				wp.lo.Warn("[WORKERPOOL]: job lease lost, cancelling it", "jobId", jobID.Hex(), "workerId", wp.workerID)
+				cancel()
+				return
+			}
+			if err != nil {
+				wp.lo.Error("[WORKERPOOL]: failed to renew the job lease:", "jobId", jobID.Hex(), "error", err)
+			}
+		}
+	}
+}
+
+// releaseJob hands the job back to the queue at its current stage, without counting an attempt.
+func (wp *WorkerPool) releaseJob(job *repository.JobQueue) {
+	job.Status = "PENDING"
+	if wp.persistJob(job) {
+		wp.lo.Info("[WORKERPOOL]: released", "jobId", job.ID.Hex(), "kind", job.Kind, "stage", job.Stage)
+	}
+}
 
-	// Update job status in MongoDB
-	job.UpdatedAt = time.Now()
+// failJob records the failed attempt of the job's current stage.
+// The job goes back to PENDING with an exponential backoff, or to the dead jobs once it ran out of attempts,
+// the runner's failure hook is then called.
+func (wp *WorkerPool) failJob(job *repository.JobQueue, runner jobRunner, err error) {
+	job.Attempts++
+	job.LastError = err.Error()
 
-	collection := wp.co.DB.Database("referrer").Collection("job_queues")
-	m, err := collection.UpdateOne(
-		context.TODO(),
-		bson.M{"userEmailAddress": job.UserEmailAddress, "status": "IN_PROGRESS"},
-		bson.M{"$set": bson.M{
-			"status":    job.Status,
-			"jobType":   job.JobType,
-			"updatedAt": job.UpdatedAt,
-			"payload": bson.M{
-				"resumeUrl":        job.Payload.ResumeURL,
-				"extractedContent": job.Payload.ExtractedContent,
-				"summary":          job.Payload.Summary,
-			},
-		}},
-	)
+	if job.Attempts < wp.maxAttempts {
+		nextRunAt := time.Now().Add(retryBackoff(wp.retryBaseDelay, wp.retryMaxDelay, job.Attempts))
+		job.Status = "PENDING"
+		job.NextRunAt = &nextRunAt
+		if wp.persistJob(job) {
+			wp.lo.Info("[WORKERPOOL]: retry scheduled", "jobId", job.ID.Hex(), "stage", job.Stage, "attempts", job.Attempts, "nextRunAt", nextRunAt)
+			metrics.JobRetriesTotal.WithLabelValues(job.Kind, job.Stage).Inc()
+			wp.publishJobEvent(*job, events.JOB_RETRY_SCHEDULED, err)
+		}
+		return
+	}
 
-	if m.MatchedCount == 0 || err != nil {
-		wp.lo.Error("[WORKERPOOL]: failed to update job status in MongoDB:", "job", job, "error", err)
+	job.Status = "FAILED"
+	job.NextRunAt = nil
+	moveErr := wp.store.MoveJobToDeadLetter(job)
+	if errors.Is(moveErr, repository.ErrJobVersionConflict) {
+		wp.lo.Warn("[WORKERPOOL]: job was modified concurrently, dropping it", "jobId", job.ID.Hex(), "version", job.Version)
+		return
 	}
+	if moveErr != nil {
+		// Keep the job as FAILED in the queue rather than losing track of it.
+		wp.lo.Error("[WORKERPOOL]: failed to move the job to the dead jobs:", "jobId", job.ID.Hex(), "error", moveErr)
+		if !wp.persistJob(job) {
+			return
+		}
+	}
+	metrics.JobsFinishedTotal.WithLabelValues(job.Kind, job.Status).Inc()
+	wp.publishJobEvent(*job, events.JOB_FAILED, err)
 
-	// If job is not completed, push it back to the job queue for the next stages
-	if job.Status != "COMPLETED" && job.Status != "FAILED" {
-		wp.jobQueue <- job
+	if runner != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+		defer cancel()
+		runner.failed(ctx, job, err)
 	}
+}
 
+// retryBackoff returns the delay before the next run of a job which failed `attempts` times.
+func retryBackoff(base, maxDelay time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
 }
 
+// normalizeLegacyJob maps the jobs queued with the former `jobType` enum onto their kind and stage.
+func normalizeLegacyJob(job *repository.JobQueue) {
+	if job.Kind != "" {
+		return
+	}
+	job.Kind = legacyJobKind
+	if job.JobType != 0 {
+		job.Stage = job.JobType.String()
+		job.JobType = 0
+	}
+}
+
+// persistJob stores the current state of the job in MongoDB, a failed write is retried with a backoff.
+// It returns false when the job was modified by someone else meanwhile, or when its state couldn't be stored:
+// the worker must then drop the job, so no stage runs from a state which isn't stored. The job goes on
+// from its stored state once the lease reaper handed it back to the queue.
+// A write applied whose answer was lost is retried on a version already bumped, it's dropped as a conflict.
+func (wp *WorkerPool) persistJob(job *repository.JobQueue) bool {
+	delay := wp.persistRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := wp.store.UpdateJobState(job)
+		if err == nil {
+			return true
+		}
+		if errors.Is(err, repository.ErrJobVersionConflict) {
+			wp.lo.Warn("[WORKERPOOL]: job was modified concurrently, dropping it", "jobId", job.ID.Hex(), "version", job.Version)
+			return false
+		}
+		if attempt == persistAttempts {
+			wp.lo.Error("[WORKERPOOL]: failed to update job status in MongoDB, dropping it:", "jobId", job.ID.Hex(), "attempts", attempt, "error", err)
+			return false
+		}
+		wp.lo.Warn("[WORKERPOOL]: failed to update job status in MongoDB, retrying:", "jobId", job.ID.Hex(), "attempt", attempt, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// publishJobEvent pushes the job's current state onto the core event bus.
+func (wp *WorkerPool) publishJobEvent(job repository.JobQueue, eventType events.EventType, err error) {
+	ev := events.Event{
+		Type:      eventType,
+		UserEmail: job.UserEmailAddress,
+		JobID:     job.ID.Hex(),
+		JobKind:   job.Kind,
+		Stage:     job.Stage,
+		Status:    job.Status,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	wp.co.Events.Publish(ev)
+}
+
+// ListenForThePendingJobs dispatches the PENDING jobs to the workers, claiming as many as there are free workers.
+// The dispatcher is woken up by a change stream on the job queue or by polling, as per the dispatch mode,
+// and whenever a worker gets free. It returns once the pool is shut down, closing the job queue.
 func (wp *WorkerPool) ListenForThePendingJobs() {
-	collection := wp.co.DB.Database("referrer").Collection("job_queues")
+	defer close(wp.jobQueue)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-wp.quit
+		cancel()
+	}()
+
+	if wp.dispatchMode == DISPATCH_POLL {
+		go wp.pollForJobs(ctx)
+	} else {
+		go wp.watchForJobs(ctx)
+	}
+
+	ticker := time.NewTicker(recheckInterval)
+	defer ticker.Stop()
 
 	for {
-		var job repository.JobQueue
-		err := collection.FindOneAndUpdate(
-			context.TODO(),
-			bson.M{"status": "PENDING"},
-			bson.M{"$set": bson.M{"status": "IN_PROGRESS", "updatedAt": time.Now()}},
-			options.FindOneAndUpdate().SetReturnDocument(options.After),
-		).Decode(&job)
+		wp.dispatch()
 
-		if err == nil {
-			wp.jobQueue <- job
-		} else if err != mongo.ErrNoDocuments {
+		select {
+		case <-wp.quit:
+			return
+		case <-wp.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch claims PENDING jobs until every worker is busy or nothing is left to claim.
+// Users already running `maxJobsPerUser` jobs are skipped until one of their jobs finishes.
+func (wp *WorkerPool) dispatch() {
+	var busyUsers []string
+	for int(wp.inFlight.Load()) < wp.concurrency && !wp.stopping() {
+		if wp.maxJobsPerUser > 0 {
+			users, err := wp.store.GetUsersAtJobCapacity(wp.maxJobsPerUser)
+			if err != nil {
+				wp.lo.Error("[WORKERPOOL]: not able to count in progress jobs per user:", "error", err)
+			}
+			busyUsers = append(busyUsers, users...)
+		}
+
+		job, err := wp.store.ClaimPendingJob(wp.workerID, wp.leaseDuration, busyUsers)
+		if err != nil {
 			wp.lo.Error("[WORKERPOOL]: not able to pull pending jobs from job-queues:", "error", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+		if !wp.withinUserCapacity(job) {
+			busyUsers = append(busyUsers, job.UserEmailAddress)
+			continue
+		}
+
+		wp.inFlight.Add(1)
+		wp.jobQueue <- *job
+	}
+}
+
+// withinUserCapacity checks the per-user limit once the job is claimed, and gives the job back when the claim exceeds it.
+// Another dispatcher, or replica, may have claimed a job of the same user since the users at capacity were listed:
+// the claim is visible to whichever of the two counts last, so at most one of them can keep the extra job.
+// Both may give it back, it's then claimed again by a later dispatch.
+func (wp *WorkerPool) withinUserCapacity(job *repository.JobQueue) bool {
+	if wp.maxJobsPerUser <= 0 {
+		return true
+	}
+	inProgress, err := wp.store.CountUserJobsInProgress(job.UserEmailAddress)
+	if err != nil {
+		wp.lo.Error("[WORKERPOOL]: not able to count in progress jobs of the user:", "userEmail", job.UserEmailAddress, "error", err)
+	}
+	if err == nil && inProgress <= int64(wp.maxJobsPerUser) {
+		return true
+	}
+	if err := wp.store.UnclaimJob(job, wp.workerID); err != nil {
+		// The lease expires and the reaper returns the job to PENDING.
+		wp.lo.Error("[WORKERPOOL]: not able to give back the job over the per user limit:", "jobId", job.ID.Hex(), "error", err)
+	}
+	return false
+}
+
+// pollForJobs wakes the dispatcher up every poll interval, until `ctx` is done.
+func (wp *WorkerPool) pollForJobs(ctx context.Context) {
+	wp.lo.Info("[WORKERPOOL]: polling for pending jobs", "interval", wp.pollInterval)
+
+	ticker := time.NewTicker(wp.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			wp.notify()
+		}
+	}
+}
+
+// watchForJobs wakes the dispatcher up on every change stream event, until `ctx` is done.
+// A broken stream is reopened. When the stream can't even be opened in DISPATCH_AUTO,
+// MongoDB is assumed to be standalone and it falls back to polling.
+func (wp *WorkerPool) watchForJobs(ctx context.Context) {
+	watched := false
+	for ctx.Err() == nil {
+		stream, err := wp.store.WatchClaimableJobs(ctx)
+		if err != nil {
+			if !watched && wp.dispatchMode == DISPATCH_AUTO {
+				wp.lo.Warn("[WORKERPOOL]: change streams unavailable, falling back to polling", "error", err)
+				wp.pollForJobs(ctx)
+				return
+			}
+			wp.lo.Error("[WORKERPOOL]: not able to watch job-queues:", "error", err)
+			sleepContext(ctx, 5*time.Second)
+			continue
+		}
+
+		if !watched {
+			wp.lo.Info("[WORKERPOOL]: watching job-queues change stream for pending jobs")
 		}
+		watched = true
+		// Catch up on what was inserted while the stream was down.
+		wp.notify()
 
-		time.Sleep(1 * time.Second)
+		for stream.Next(ctx) {
+			wp.notify()
+		}
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			wp.lo.Error("[WORKERPOOL]: job-queues change stream broken, reopening it:", "error", err)
+			sleepContext(ctx, 1*time.Second)
+		}
+		stream.Close(context.Background())
+	}
+}
+
+// sleepContext sleeps for `d`, or until `ctx` is done.
+func sleepContext(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// ReapExpiredLeases periodically returns the jobs of dead workers to the queue, until the pool is shut down.
+func (wp *WorkerPool) ReapExpiredLeases() {
+	ticker := time.NewTicker(wp.leaseDuration / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wp.quit:
+			return
+		case <-ticker.C:
+			reaped, err := wp.store.ReapExpiredJobLeases(wp.leaseDuration)
+			if err != nil {
+				wp.lo.Error("[WORKERPOOL]: not able to reap expired job leases:", "error", err)
+				continue
+			}
+			if reaped > 0 {
+				wp.lo.Warn("[WORKERPOOL]: returned jobs with an expired lease to the queue", "count", reaped)
+			}
+		}
 	}
 }
 
+// Wait blocks until every worker has exited.
 func (wp *WorkerPool) Wait() {
-	defer close(wp.jobQueue)
 	wp.wg.Wait()
 }
+
+// Shutdown stops claiming new jobs and waits for the workers to finish the stage they're running,
+// their jobs are then released to the queue. When `ctx` expires first, the running stages are
+// cancelled and their jobs released as well.
+func (wp *WorkerPool) Shutdown(ctx context.Context) error {
+	wp.quitOnce.Do(func() { close(wp.quit) })
+
+	done := make(chan struct{})
+	go func() {
+		wp.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		wp.cancelJobs()
+		return nil
+	case <-ctx.Done():
+		wp.lo.Warn("[WORKERPOOL]: shutdown deadline hit, cancelling the running jobs")
+		wp.cancelJobs()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,447 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sounishnath003/customgo-mailer-service/internal/core"
+	"github.com/sounishnath003/customgo-mailer-service/internal/events"
+	"github.com/sounishnath003/customgo-mailer-service/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRetryBackoffDoublesUpToTheCap(t *testing.T) {
+	base, maxDelay := 30*time.Second, 5*time.Minute
+
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, expected := range want {
+		if got := retryBackoff(base, maxDelay, i+1); got != expected {
+			t.Errorf("attempt %d: expected backoff %v, got %v", i+1, expected, got)
+		}
+	}
+}
+
+// fakeJobStore is an in-memory job queue, claiming and updating the jobs like the MongoDB one.
+type fakeJobStore struct {
+	mu   sync.Mutex
+	jobs []*repository.JobQueue
+
+	// updateErrs are returned by the next UpdateJobState calls, in order.
+	updateErrs []error
+	updates    int
+	unclaimed  int
+	// staleCapacity lists no user at capacity, like a listing made before the claim of another replica.
+	staleCapacity bool
+
+	renewErr error
+	renewals int
+	reaps    int
+
+	depth        []*repository.JobQueueDepth
+	depthByStage []*repository.JobStageDepth
+}
+
+func (s *fakeJobStore) stored(id primitive.ObjectID) *repository.JobQueue {
+	for _, job := range s.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+func (s *fakeJobStore) ClaimPendingJob(workerID string, leaseDuration time.Duration, excludeUsers []string) (*repository.JobQueue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, job := range s.jobs {
+		if job.Status != "PENDING" || slices.Contains(excludeUsers, job.UserEmailAddress) {
+			continue
+		}
+		expiresAt := time.Now().Add(leaseDuration)
+		job.Status = "IN_PROGRESS"
+		job.LeaseOwner = workerID
+		job.LeaseExpiresAt = &expiresAt
+		job.Version++
+		claimed := *job
+		return &claimed, nil
+	}
+	return nil, nil
+}
+
+func (s *fakeJobStore) UnclaimJob(job *repository.JobQueue, workerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.stored(job.ID)
+	if stored == nil || stored.Status != "IN_PROGRESS" || stored.LeaseOwner != workerID || stored.Version != job.Version {
+		return repository.ErrJobVersionConflict
+	}
+	stored.Status = "PENDING"
+	stored.LeaseOwner = ""
+	stored.LeaseExpiresAt = nil
+	stored.Version++
+	job.Version++
+	s.unclaimed++
+	return nil
+}
+
+func (s *fakeJobStore) UpdateJobState(job *repository.JobQueue) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updates++
+	if len(s.updateErrs) > 0 {
+		err := s.updateErrs[0]
+		s.updateErrs = s.updateErrs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	stored := s.stored(job.ID)
+	if stored == nil || stored.Version != job.Version {
+		return repository.ErrJobVersionConflict
+	}
+	leaseOwner, leaseExpiresAt := stored.LeaseOwner, stored.LeaseExpiresAt
+	*stored = *job
+	if job.Status == "IN_PROGRESS" {
+		stored.LeaseOwner, stored.LeaseExpiresAt = leaseOwner, leaseExpiresAt
+	} else {
+		stored.LeaseOwner, stored.LeaseExpiresAt = "", nil
+	}
+	stored.Version++
+	job.Version++
+	return nil
+}
+
+func (s *fakeJobStore) MoveJobToDeadLetter(job *repository.JobQueue) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.stored(job.ID)
+	if stored == nil || stored.Version != job.Version {
+		return repository.ErrJobVersionConflict
+	}
+	s.jobs = slices.DeleteFunc(s.jobs, func(j *repository.JobQueue) bool { return j == stored })
+	return nil
+}
+
+func (s *fakeJobStore) RenewJobLease(id primitive.ObjectID, workerID string, leaseDuration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.renewals++
+	if s.renewErr != nil {
+		return s.renewErr
+	}
+	stored := s.stored(id)
+	if stored == nil || stored.Status != "IN_PROGRESS" || stored.LeaseOwner != workerID {
+		return repository.ErrJobLeaseLost
+	}
+	expiresAt := time.Now().Add(leaseDuration)
+	stored.LeaseExpiresAt = &expiresAt
+	return nil
+}
+
+func (s *fakeJobStore) ReapExpiredJobLeases(leaseDuration time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reaps++
+	var reaped int64
+	for _, job := range s.jobs {
+		if job.Status == "IN_PROGRESS" && job.LeaseExpiresAt != nil && job.LeaseExpiresAt.Before(time.Now()) {
+			job.Status = "PENDING"
+			job.LeaseOwner = ""
+			job.LeaseExpiresAt = nil
+			job.Version++
+			reaped++
+		}
+	}
+	return reaped, nil
+}
+
+func (s *fakeJobStore) GetUsersAtJobCapacity(limit int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.staleCapacity {
+		return nil, nil
+	}
+	inProgress := map[string]int{}
+	var users []string
+	for _, job := range s.jobs {
+		if job.Status != "IN_PROGRESS" {
+			continue
+		}
+		inProgress[job.UserEmailAddress]++
+		if inProgress[job.UserEmailAddress] == limit {
+			users = append(users, job.UserEmailAddress)
+		}
+	}
+	return users, nil
+}
+
+func (s *fakeJobStore) CountUserJobsInProgress(userEmail string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, job := range s.jobs {
+		if job.UserEmailAddress == userEmail && job.Status == "IN_PROGRESS" {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *fakeJobStore) WatchClaimableJobs(ctx context.Context) (*mongo.ChangeStream, error) {
+	return nil, errors.New("change streams are not supported")
+}
+
+func (s *fakeJobStore) GetJobQueueDepth() ([]*repository.JobQueueDepth, error) {
+	return s.depth, nil
+}
+
+func (s *fakeJobStore) GetJobQueueDepthByStage() ([]*repository.JobStageDepth, error) {
+	return s.depthByStage, nil
+}
+
+// pendingJob is a new job of the user, as enqueued.
+func pendingJob(userEmail string) *repository.JobQueue {
+	return &repository.JobQueue{ID: primitive.NewObjectID(), UserEmailAddress: userEmail, Kind: "COUNTER", Stage: "INCREMENT", Status: "PENDING"}
+}
+
+// newTestWorkerPool is a pool working on the store, without MongoDB.
+func newTestWorkerPool(store *fakeJobStore, opts *WorkerPoolOpts) *WorkerPool {
+	wp := NewWorkerPool(&core.Core{Events: events.NewBus()}, opts)
+	wp.store = store
+	wp.persistRetryDelay = time.Millisecond
+	return wp
+}
+
+func TestPersistJobRetriesAFailedWrite(t *testing.T) {
+	job := pendingJob("jane@example.com")
+	store := &fakeJobStore{jobs: []*repository.JobQueue{job}, updateErrs: []error{errors.New("connection reset")}}
+	wp := newTestWorkerPool(store, &WorkerPoolOpts{Concurrency: 1})
+
+	update := *job
+	update.Status = "COMPLETED"
+	if !wp.persistJob(&update) {
+		t.Fatal("expected the job to be stored on the second attempt")
+	}
+	if store.updates != 2 || job.Status != "COMPLETED" {
+		t.Errorf("expected 2 writes storing the COMPLETED job, got %d writes and %s", store.updates, job.Status)
+	}
+}
+
+func TestPersistJobDropsTheJobItCannotStore(t *testing.T) {
+	for name, updateErrs := range map[string][]error{
+		"write failing":   {errors.New("timeout"), errors.New("timeout"), errors.New("timeout")},
+		"version changed": {repository.ErrJobVersionConflict},
+	} {
+		job := pendingJob("jane@example.com")
+		store := &fakeJobStore{jobs: []*repository.JobQueue{job}, updateErrs: updateErrs}
+		wp := newTestWorkerPool(store, &WorkerPoolOpts{Concurrency: 1})
+
+		update := *job
+		update.Status = "COMPLETED"
+		if wp.persistJob(&update) {
+			t.Errorf("%s: expected the job to be dropped", name)
+		}
+		if store.updates != len(updateErrs) || job.Status != "PENDING" {
+			t.Errorf("%s: expected %d writes leaving the job PENDING, got %d writes and %s", name, len(updateErrs), store.updates, job.Status)
+		}
+	}
+}
+
+func TestProcessJobStopsAtTheStageTransitionItCannotStore(t *testing.T) {
+	job := pendingJob("jane@example.com")
+	writeErr := errors.New("timeout")
+	store := &fakeJobStore{jobs: []*repository.JobQueue{job}, updateErrs: []error{writeErr, writeErr, writeErr}}
+	wp := newTestWorkerPool(store, &WorkerPoolOpts{Concurrency: 1})
+	Register(wp.Registry(), counterDefinition())
+
+	claimed, _ := store.ClaimPendingJob(wp.workerID, time.Minute, nil)
+	wp.processJob(*claimed)
+
+	// the next INCREMENT must not run from the count which was never stored
+	var payload counterPayload
+	if len(job.Payload) > 0 {
+		bson.Unmarshal(job.Payload, &payload)
+	}
+	if store.updates != persistAttempts || job.Status != "IN_PROGRESS" || payload.Count != 0 {
+		t.Errorf("expected %d writes leaving the job IN_PROGRESS at count 0, got %d writes, %s at count %d", persistAttempts, store.updates, job.Status, payload.Count)
+	}
+}
+
+func TestDispatchGivesBackTheJobOverThePerUserLimit(t *testing.T) {
+	running := pendingJob("jane@example.com")
+	running.Status = "IN_PROGRESS"
+	queued := pendingJob("jane@example.com")
+	// the users at capacity were listed before the other replica claimed the running job
+	store := &fakeJobStore{jobs: []*repository.JobQueue{running, queued}, staleCapacity: true}
+	wp := newTestWorkerPool(store, &WorkerPoolOpts{Concurrency: 2, MaxJobsPerUser: 1})
+
+	wp.dispatch()
+
+	if wp.inFlight.Load() != 0 || len(wp.jobQueue) != 0 {
+		t.Errorf("expected no job dispatched, got %d in flight", wp.inFlight.Load())
+	}
+	if store.unclaimed != 1 || queued.Status != "PENDING" || queued.LeaseOwner != "" {
+		t.Errorf("expected the claimed job to be given back, got %d given back and %s leased to %q", store.unclaimed, queued.Status, queued.LeaseOwner)
+	}
+}
+
+func TestHeartbeatCancelsTheJobWhoseLeaseWasLost(t *testing.T) {
+	job := pendingJob("jane@example.com")
+	store := &fakeJobStore{jobs: []*repository.JobQueue{job}}
+	wp := newTestWorkerPool(store, &WorkerPoolOpts{Concurrency: 1, LeaseDuration: 30 * time.Millisecond})
+	claimed, _ := store.ClaimPendingJob(wp.workerID, wp.leaseDuration, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		wp.heartbeat(ctx, cancel, claimed.ID)
+		close(done)
+	}()
+
+	// the lease is renewed while the job stays leased to this worker
+	time.Sleep(5 * wp.leaseDuration)
+	store.mu.Lock()
+	renewals, leasedUntil := store.renewals, *job.LeaseExpiresAt
+	// the job is reaped and handed to another worker
+	job.LeaseOwner = "another-worker"
+	store.mu.Unlock()
+
+	if renewals == 0 || !leasedUntil.After(time.Now()) {
+		t.Errorf("expected the lease to be renewed, got %d renewals and a lease until %v", renewals, leasedUntil)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the heartbeat to stop once the lease was lost")
+	}
+	if ctx.Err() == nil {
+		t.Error("expected the running stage to be cancelled")
+	}
+}
+
+func TestHeartbeatKeepsTheJobOnAFailedRenewal(t *testing.T) {
+	job := pendingJob("jane@example.com")
+	store := &fakeJobStore{jobs: []*repository.JobQueue{job}, renewErr: errors.New("connection reset")}
+	wp := newTestWorkerPool(store, &WorkerPoolOpts{Concurrency: 1, LeaseDuration: 30 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		wp.heartbeat(ctx, func() { t.Error("expected the job not to be cancelled on a failed renewal") }, job.ID)
+		close(done)
+	}()
+
+	time.Sleep(5 * wp.leaseDuration)
+	cancel()
+	<-done
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.renewals < 2 {
+		t.Errorf("expected the renewal to be tried again, got %d renewals", store.renewals)
+	}
+}
+
+func TestReapExpiredLeasesReturnsTheJobsOfDeadWorkers(t *testing.T) {
+	expired := pendingJob("jane@example.com")
+	leased := pendingJob("john@example.com")
+	store := &fakeJobStore{jobs: []*repository.JobQueue{expired, leased}}
+	wp := newTestWorkerPool(store, &WorkerPoolOpts{Concurrency: 1, LeaseDuration: 40 * time.Millisecond})
+
+	// a dead worker's lease is already over, a live one's is far ahead
+	store.ClaimPendingJob("dead-worker", -time.Minute, nil)
+	store.ClaimPendingJob("live-worker", time.Hour, nil)
+
+	done := make(chan struct{})
+	go func() {
+		wp.ReapExpiredLeases()
+		close(done)
+	}()
+	time.Sleep(3 * wp.leaseDuration)
+	wp.Shutdown(context.Background())
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the reaper to stop on shutdown")
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.reaps == 0 {
+		t.Fatal("expected the expired leases to be reaped")
+	}
+	if expired.Status != "PENDING" || expired.LeaseOwner != "" {
+		t.Errorf("expected the dead worker's job back in the queue, got %s leased to %q", expired.Status, expired.LeaseOwner)
+	}
+	if leased.Status != "IN_PROGRESS" || leased.LeaseOwner != "live-worker" {
+		t.Errorf("expected the live worker to keep its job, got %s leased to %q", leased.Status, leased.LeaseOwner)
+	}
+}
+
+func TestDispatchClaimsUpToTheConcurrency(t *testing.T) {
+	store := &fakeJobStore{}
+	for range 5 {
+		store.jobs = append(store.jobs, pendingJob("jane@example.com"))
+	}
+	wp := newTestWorkerPool(store, &WorkerPoolOpts{Concurrency: 3})
+
+	wp.dispatch()
+	if wp.inFlight.Load() != 3 || len(wp.jobQueue) != 3 {
+		t.Fatalf("expected 3 jobs claimed for the 3 workers, got %d in flight and %d queued", wp.inFlight.Load(), len(wp.jobQueue))
+	}
+
+	// a finished job frees a single worker
+	<-wp.jobQueue
+	wp.inFlight.Add(-1)
+	wp.dispatch()
+	if wp.inFlight.Load() != 3 || len(wp.jobQueue) != 3 {
+		t.Errorf("expected a single job claimed for the freed worker, got %d in flight and %d queued", wp.inFlight.Load(), len(wp.jobQueue))
+	}
+
+	var pending int
+	for _, job := range store.jobs {
+		if job.Status == "PENDING" {
+			pending++
+		}
+	}
+	if pending != 1 {
+		t.Errorf("expected 1 job left in the queue, got %d", pending)
+	}
+}
+
+func TestDispatchSkipsTheUsersAtCapacity(t *testing.T) {
+	running := pendingJob("jane@example.com")
+	running.Status = "IN_PROGRESS"
+	janes := pendingJob("jane@example.com")
+	johns := pendingJob("john@example.com")
+	store := &fakeJobStore{jobs: []*repository.JobQueue{running, janes, johns}}
+	wp := newTestWorkerPool(store, &WorkerPoolOpts{Concurrency: 2, MaxJobsPerUser: 1})
+
+	wp.dispatch()
+
+	if wp.inFlight.Load() != 1 || len(wp.jobQueue) != 1 {
+		t.Fatalf("expected a single job dispatched, got %d in flight", wp.inFlight.Load())
+	}
+	if job := <-wp.jobQueue; job.ID != johns.ID {
+		t.Errorf("expected the job of john@example.com, got the one of %s", job.UserEmailAddress)
+	}
+	if janes.Status != "PENDING" || store.unclaimed != 0 {
+		t.Errorf("expected the job of jane@example.com never claimed, got %s and %d given back", janes.Status, store.unclaimed)
+	}
+}
@@ -29,10 +29,6 @@ export class HomeComponent {
       label: "Ask for Job Opportunities",
       shortDesc: "Craft an email asking job opportunities at companies."
     },
-    {
-      label: "Send Congratulations",
-      shortDesc: "Craft send congratulations roles customized email."
-    },
     {
       label: "Draft with AI",
       shortDesc: "Use Generative AI to draft an customized message"